@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/iainlane/who-dances-what/internal/solver"
+)
+
+func coverage(logger *logrus.Entry) *cli.Command {
+	return &cli.Command{
+		Name:  "coverage",
+		Usage: "Report how many active dancers can dance each position of the active dances, and which dances depend on one person",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "problems",
+				Usage: "Only show dances which can't be danced, or which depend on one person",
+			},
+		},
+		Action: func(c *cli.Context) error { return doCoverage(c, logger) },
+	}
+}
+
+func doCoverage(c *cli.Context, logger *logrus.Entry) error {
+	m, err := model.NewModel(c.String("db"), logger)
+	if err != nil {
+		return err
+	}
+
+	everyone, err := m.FetchDancers()
+	if err != nil {
+		return err
+	}
+
+	var active []*model.Dancer
+	for _, dancer := range everyone {
+		if dancer.Active {
+			active = append(active, dancer)
+		}
+	}
+
+	dances, positions, err := m.FetchDancerPositionsForDancers(active)
+	if err != nil {
+		return err
+	}
+
+	var activeDances []*model.Dance
+	for _, dance := range dances {
+		if dance.Active {
+			activeDances = append(activeDances, dance)
+		}
+	}
+
+	fmt.Print(formatCoverage(solver.Coverage(activeDances, positions), c.Bool("problems")))
+
+	return nil
+}
+
+// hasProblem says whether a dance can't be danced, or there's somebody it
+// can't be danced without.
+func hasProblem(dc solver.DanceCoverage) bool {
+	return !dc.Danceable || len(dc.Essential) > 0
+}
+
+// formatCoverage lists who can dance each position, flagging positions with
+// one dancer or none. The dances nobody can dance at the moment are listed
+// again at the end.
+func formatCoverage(coverage []solver.DanceCoverage, problemsOnly bool) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+
+	var undanceable []string
+	for _, dc := range coverage {
+		if !dc.Danceable {
+			undanceable = append(undanceable, dc.Dance.Name)
+		}
+
+		if problemsOnly && !hasProblem(dc) {
+			continue
+		}
+
+		fmt.Fprintln(w, dc.Dance.Name)
+
+		for _, pc := range dc.Positions {
+			row := []string{"  " + pc.Position.Name}
+			for _, preference := range []model.DancePreference{model.PreferenceFavourite, model.PreferenceYes, model.PreferenceMaybe} {
+				row = append(row, fmt.Sprintf("%d %s", len(pc.Dancers[preference]), preference))
+			}
+
+			switch pc.BusFactor() {
+			case 0:
+				row = append(row, "! nobody")
+			case 1:
+				for _, dancers := range pc.Dancers {
+					if len(dancers) > 0 {
+						row = append(row, "! only "+dancers[0].Name)
+					}
+				}
+			}
+
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+
+		if len(dc.Essential) > 0 {
+			names := make([]string, 0, len(dc.Essential))
+			for _, dancer := range dc.Essential {
+				names = append(names, dancer.Name)
+			}
+			fmt.Fprintf(w, "  ! Can't be danced without %s\n", strings.Join(names, ", "))
+		}
+		if !dc.Danceable {
+			fmt.Fprintln(w, "  ! Can't be danced by the current members")
+		}
+	}
+
+	w.Flush()
+
+	if len(undanceable) > 0 {
+		fmt.Fprintf(&sb, "\nCan't be danced by the current members: %s\n", strings.Join(undanceable, ", "))
+	}
+
+	return sb.String()
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/sirupsen/logrus"
 	log "github.com/sirupsen/logrus"
@@ -15,6 +18,37 @@ import (
 type danceSetGenerator struct {
 	logger      *logrus.Entry
 	dancerNames []string
+	constraints solver.Constraints
+
+	// these need the dances from the database before they can be turned into
+	// constraints
+	include    []string
+	exclude    []string
+	pins       []string
+	dancerMins []string
+	dancerMaxs []string
+	weights    []string
+
+	eventName        string
+	minPerDancer     int
+	maxPerDancer     int
+	softDancerLimits bool
+
+	alternatives  int
+	minDifference int
+	difference    solver.AlternativeDifference
+
+	explain  bool
+	withSubs bool
+	output   string
+
+	savePath   string
+	repairPath string
+	checkPath  string
+	htmlPath   string
+	cards      bool
+
+	solver solver.Solver
 }
 
 func danceSet(logger *log.Entry) *cli.Command {
@@ -25,6 +59,100 @@ func danceSet(logger *log.Entry) *cli.Command {
 	return &cli.Command{
 		Name:  "dance-set",
 		Usage: "Generate a dance set given a list of dancers",
+		Flags: append(append(setLengthFlags(), solverFlags()...),
+			&cli.StringFlag{
+				Name:  "event",
+				Usage: "Generate the set for the dancers attending this event, as well as any given as arguments",
+			},
+			&cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "Always include this dance in the set",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "Never include this dance in the set",
+			},
+			&cli.StringSliceFlag{
+				Name:  "pin",
+				Usage: "Fix a dancer to a position, as `DANCER=DANCE:POSITION`. POSITION can be the position's number or name",
+			},
+			&cli.IntFlag{
+				Name:  "min-per-dancer",
+				Usage: "Give every dancer at least this many dances",
+			},
+			&cli.IntFlag{
+				Name:  "max-per-dancer",
+				Usage: "Give every dancer at most this many dances",
+			},
+			&cli.StringSliceFlag{
+				Name:  "dancer-min",
+				Usage: "Give a dancer at least this many dances, as `DANCER=N`",
+			},
+			&cli.StringSliceFlag{
+				Name:  "dancer-max",
+				Usage: "Give a dancer at most this many dances, as `DANCER=N`",
+			},
+			&cli.StringSliceFlag{
+				Name:  "weight",
+				Usage: "Give a dancer a bigger or smaller share of the dances than everybody else, as `DANCER=W`. 2 is twice as many, 0.5 half",
+			},
+			&cli.BoolFlag{
+				Name:  "soft-dancer-limits",
+				Usage: "Treat the per-dancer limits given on the command line as preferences, which can be broken if there's no other way to make a set",
+			},
+			&cli.IntFlag{
+				Name:  "alternatives",
+				Value: 1,
+				Usage: "Generate up to this many different sets, best first, and show them side by side",
+			},
+			&cli.IntFlag{
+				Name:  "min-difference",
+				Value: 1,
+				Usage: "How different each alternative set has to be from the others",
+			},
+			&cli.StringFlag{
+				Name:  "difference",
+				Value: solver.AlternativeDifferenceDances.String(),
+				Usage: "What to count when comparing alternative sets: `dances` or assignments",
+			},
+			&cli.BoolFlag{
+				Name:  "progress",
+				Usage: "Show each better set as the solver finds it",
+			},
+			&cli.BoolFlag{
+				Name:  "explain",
+				Usage: "Explain why each dance which isn't in the set was left out, and who could change that",
+			},
+			&cli.BoolFlag{
+				Name:  "with-subs",
+				Usage: "List who could step into each position, best first",
+			},
+			&cli.StringFlag{
+				Name:      "save",
+				Usage:     "Save the set to `FILE`, so it can be repaired later",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "repair",
+				Usage:     "Repair the set saved in `FILE` for the dancers given, changing as little as possible",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:      "check",
+				Usage:     "Check the set saved in `FILE`, which might have been edited by hand, against the dancers and constraints given instead of making a new one",
+				TakesFile: true,
+			},
+			outputFlag(),
+			&cli.StringFlag{
+				Name:      "html",
+				Usage:     "Write a printable set sheet to `FILE`, with each dance's note and who's playing",
+				TakesFile: true,
+			},
+			&cli.BoolFlag{
+				Name:  "cards",
+				Usage: "Add a card for each dancer to the --html sheet, listing their dances and positions",
+			},
+		),
 		Before: func(c *cli.Context) error {
 			return generator.handleCommandLineParameters(c)
 		},
@@ -35,14 +163,505 @@ func danceSet(logger *log.Entry) *cli.Command {
 func (g *danceSetGenerator) handleCommandLineParameters(c *cli.Context) error {
 	// dancer names should be in a positional argument
 	dancerNames := c.Args().Slice()
+	g.eventName = c.String("event")
 
-	// check that the slice is not empty
-	if len(dancerNames) == 0 {
+	// check that we have somebody to dance
+	if len(dancerNames) == 0 && g.eventName == "" {
 		return cli.Exit("No dancers specified", 1)
 	}
 
 	g.dancerNames = dancerNames
 
+	minDances, maxDances, err := parseSetLength(c)
+	if err != nil {
+		return err
+	}
+
+	g.constraints.MinDances = minDances
+	g.constraints.MaxDances = maxDances
+
+	g.include = c.StringSlice("include")
+	g.exclude = c.StringSlice("exclude")
+	g.pins = c.StringSlice("pin")
+
+	g.minPerDancer = c.Int("min-per-dancer")
+	g.maxPerDancer = c.Int("max-per-dancer")
+	g.dancerMins = c.StringSlice("dancer-min")
+	g.dancerMaxs = c.StringSlice("dancer-max")
+	g.weights = c.StringSlice("weight")
+	g.softDancerLimits = c.Bool("soft-dancer-limits")
+
+	g.alternatives = c.Int("alternatives")
+	if g.alternatives < 1 {
+		return cli.Exit("--alternatives must be at least 1", 1)
+	}
+
+	g.minDifference = c.Int("min-difference")
+	if g.minDifference < 1 {
+		return cli.Exit("--min-difference must be at least 1", 1)
+	}
+
+	g.explain = c.Bool("explain")
+	if g.explain && g.alternatives > 1 {
+		return cli.Exit("--explain can't be used with --alternatives", 1)
+	}
+
+	g.withSubs = c.Bool("with-subs")
+	if g.withSubs && g.alternatives > 1 {
+		return cli.Exit("--with-subs can't be used with --alternatives", 1)
+	}
+
+	g.savePath = c.String("save")
+	g.repairPath = c.String("repair")
+	if g.savePath != "" && g.alternatives > 1 {
+		return cli.Exit("--save can't be used with --alternatives", 1)
+	}
+
+	g.htmlPath = c.String("html")
+	g.cards = c.Bool("cards")
+	if g.htmlPath != "" && g.alternatives > 1 {
+		return cli.Exit("--html can't be used with --alternatives", 1)
+	}
+	if g.cards && g.htmlPath == "" {
+		return cli.Exit("--cards needs --html", 1)
+	}
+
+	g.checkPath = c.String("check")
+	if g.checkPath != "" && (g.savePath != "" || g.repairPath != "" || g.alternatives > 1 || g.explain || g.htmlPath != "") {
+		return cli.Exit("--check can't be used with --save, --repair, --alternatives, --explain or --html", 1)
+	}
+
+	g.output, err = parseOutput(c)
+	if err != nil {
+		return err
+	}
+	if g.output != outputText && (g.alternatives > 1 || g.explain || g.checkPath != "") {
+		return cli.Exit("--output can't be used with --alternatives, --explain or --check", 1)
+	}
+
+	var options solver.Options
+	if c.Bool("progress") {
+		options.Progress = printProgress
+	}
+
+	g.solver, err = newSolverFromFlags(c, options)
+	if err != nil {
+		return err
+	}
+
+	switch difference := c.String("difference"); difference {
+	case solver.AlternativeDifferenceDances.String():
+		g.difference = solver.AlternativeDifferenceDances
+	case solver.AlternativeDifferenceAssignments.String():
+		g.difference = solver.AlternativeDifferenceAssignments
+	default:
+		return cli.Exit(fmt.Sprintf("unknown --difference %q, expected dances or assignments", difference), 1)
+	}
+
+	return nil
+}
+
+// setLengthFlags bound the number of dances in a set.
+func setLengthFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:  "dances",
+			Usage: "Generate a set of exactly this many dances",
+		},
+		&cli.IntFlag{
+			Name:  "min-dances",
+			Usage: "Generate a set of at least this many dances",
+		},
+		&cli.IntFlag{
+			Name:  "max-dances",
+			Usage: "Generate a set of at most this many dances, or 0 for no limit",
+		},
+	}
+}
+
+// parseSetLength reads `setLengthFlags`.
+func parseSetLength(c *cli.Context) (int, int, error) {
+	minDances := c.Int("min-dances")
+	maxDances := c.Int("max-dances")
+
+	if c.IsSet("dances") {
+		if c.IsSet("min-dances") || c.IsSet("max-dances") {
+			return 0, 0, cli.Exit("--dances can't be used with --min-dances or --max-dances", 1)
+		}
+
+		minDances = c.Int("dances")
+		maxDances = minDances
+
+		// a maximum of 0 would mean there's no limit, which isn't what
+		// anybody asking for exactly 0 dances wants
+		if minDances == 0 {
+			return 0, 0, cli.Exit("--dances must be at least 1", 1)
+		}
+	}
+
+	if minDances < 0 || maxDances < 0 {
+		return 0, 0, cli.Exit("The number of dances can't be negative", 1)
+	}
+
+	if maxDances > 0 && minDances > maxDances {
+		return 0, 0, cli.Exit(fmt.Sprintf("--min-dances (%d) is more than --max-dances (%d)", minDances, maxDances), 1)
+	}
+
+	return minDances, maxDances, nil
+}
+
+// solverFlags pick which solver backend to use, and whether it writes out its
+// model for debugging.
+func solverFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "solver",
+			Value: solver.DefaultBackend,
+			Usage: fmt.Sprintf("Which solver to use: one of %s", strings.Join(solver.Backends(), ", ")),
+		},
+		&cli.StringFlag{
+			Name:      "dump-model",
+			Usage:     "Write the model the solver builds to `FILE`, so it can be replayed with solve-dump",
+			TakesFile: true,
+		},
+		&cli.StringFlag{
+			Name:      "dump-response",
+			Usage:     "Write the solver's response to `FILE`",
+			TakesFile: true,
+		},
+		dumpFormatFlag(),
+	}
+}
+
+// dumpFormatFlag picks the format of dumped models and responses.
+func dumpFormatFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "dump-format",
+		Value: solver.DumpFormatText.String(),
+		Usage: "Write dumps as `text` or binary protobuf",
+	}
+}
+
+func parseDumpFormat(c *cli.Context) (solver.DumpFormat, error) {
+	switch format := c.String("dump-format"); format {
+	case solver.DumpFormatText.String():
+		return solver.DumpFormatText, nil
+	case solver.DumpFormatBinary.String():
+		return solver.DumpFormatBinary, nil
+	default:
+		return 0, cli.Exit(fmt.Sprintf("unknown --dump-format %q, expected text or binary", format), 1)
+	}
+}
+
+// newSolverFromFlags makes the solver asked for with `solverFlags`, adding to
+// `options`.
+func newSolverFromFlags(c *cli.Context, options solver.Options) (solver.Solver, error) {
+	if c.IsSet("dump-model") || c.IsSet("dump-response") {
+		format, err := parseDumpFormat(c)
+		if err != nil {
+			return nil, err
+		}
+
+		options.Dump = &solver.Dump{
+			ModelPath:    c.String("dump-model"),
+			ResponsePath: c.String("dump-response"),
+			Format:       format,
+		}
+	}
+
+	s, err := solver.New(c.String("solver"), options)
+	if err != nil {
+		return nil, cli.Exit(err.Error(), 1)
+	}
+
+	return s, nil
+}
+
+// printProgress shows the solver's progress on stderr, so it doesn't get mixed
+// up with the set.
+func printProgress(result solver.SolveResult) {
+	fmt.Fprintf(os.Stderr, "Found a set of %d dances (objective %d)\n", result.Set.NumDancesDanced(), result.Objective)
+}
+
+// warnIfCancelled says on stderr when a set might not be the best there is,
+// because solving was stopped early.
+func warnIfCancelled(result solver.SolveResult) {
+	if result.Status == solver.SolverStatusCancelled {
+		fmt.Fprintln(os.Stderr, "Stopped early, so there might be better sets than this")
+	}
+}
+
+// findDancer looks up a dancer by name, ignoring case.
+func findDancer(dancers []*model.Dancer, name string) (*model.Dancer, error) {
+	for _, dancer := range dancers {
+		if strings.EqualFold(dancer.Name, name) {
+			return dancer, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%q isn't one of the dancers", name)
+}
+
+// parseDancerCount parses `DANCER=N`.
+func parseDancerCount(s string, dancers []*model.Dancer) (*model.Dancer, int, error) {
+	name, count, ok := strings.Cut(s, "=")
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid dancer limit %q, expected DANCER=N", s)
+	}
+
+	dancer, err := findDancer(dancers, name)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid dancer limit %q: %w", s, err)
+	}
+
+	return dancer, n, nil
+}
+
+// fetchDancers gets the dancers named on the command line and those attending
+// the event, if there is one.
+func fetchDancers(m *model.Model, dancerNames []string, eventName string) ([]*model.Dancer, *model.Event, error) {
+	var dancers []*model.Dancer
+	var event *model.Event
+
+	if len(dancerNames) > 0 {
+		named, err := m.FetchDancersByName(dancerNames)
+		if err != nil {
+			return nil, nil, err
+		}
+		dancers = append(dancers, named...)
+	}
+
+	if eventName != "" {
+		var err error
+		event, err = m.FetchEventByName(eventName)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		seen := make(map[int]struct{}, len(dancers))
+		for _, dancer := range dancers {
+			seen[dancer.ID] = struct{}{}
+		}
+
+		for _, dancer := range event.Dancers() {
+			if _, ok := seen[dancer.ID]; ok {
+				continue
+			}
+			dancers = append(dancers, dancer)
+		}
+	}
+
+	return dancers, event, nil
+}
+
+// resolveDancerLimits works out how many dances each dancer should do. Limits
+// for everybody are overridden by those recorded for the event, which are in
+// turn overridden by limits for individual dancers on the command line.
+func (g *danceSetGenerator) resolveDancerLimits(dancers []*model.Dancer, event *model.Event) error {
+	limits := make(map[*model.Dancer]solver.DancerLimit)
+	byID := make(map[int]*model.Dancer, len(dancers))
+
+	for _, dancer := range dancers {
+		byID[dancer.ID] = dancer
+
+		limit := solver.DancerLimit{
+			MinDances: g.minPerDancer,
+			MaxDances: g.maxPerDancer,
+			Soft:      g.softDancerLimits,
+		}
+		if !limit.IsZero() {
+			limits[dancer] = limit
+		}
+	}
+
+	if event != nil {
+		for _, attendance := range event.Attendances {
+			dancer, ok := byID[attendance.DancerID]
+			if !ok || (attendance.MinDances == 0 && attendance.MaxDances == 0) {
+				continue
+			}
+
+			limit := limits[dancer]
+			if attendance.MinDances > 0 {
+				limit.MinDances = attendance.MinDances
+			}
+			if attendance.MaxDances > 0 {
+				limit.MaxDances = attendance.MaxDances
+			}
+			limit.Soft = attendance.SoftLimits
+			limits[dancer] = limit
+		}
+	}
+
+	for _, s := range g.dancerMins {
+		dancer, n, err := parseDancerCount(s, dancers)
+		if err != nil {
+			return err
+		}
+
+		limit := limits[dancer]
+		limit.MinDances = n
+		limit.Soft = g.softDancerLimits
+		limits[dancer] = limit
+	}
+
+	for _, s := range g.dancerMaxs {
+		dancer, n, err := parseDancerCount(s, dancers)
+		if err != nil {
+			return err
+		}
+
+		limit := limits[dancer]
+		limit.MaxDances = n
+		limit.Soft = g.softDancerLimits
+		limits[dancer] = limit
+	}
+
+	if len(limits) > 0 {
+		g.constraints.DancerLimits = limits
+	}
+
+	return nil
+}
+
+// resolveDancerWeights works out each dancer's share of the dances. Weights
+// recorded for the event are overridden by those on the command line.
+func (g *danceSetGenerator) resolveDancerWeights(dancers []*model.Dancer, event *model.Event) error {
+	weights := make(map[*model.Dancer]float64)
+
+	if event != nil {
+		byID := make(map[int]*model.Dancer, len(dancers))
+		for _, dancer := range dancers {
+			byID[dancer.ID] = dancer
+		}
+
+		for _, attendance := range event.Attendances {
+			if dancer, ok := byID[attendance.DancerID]; ok && attendance.Weight != 0 {
+				weights[dancer] = attendance.Weight
+			}
+		}
+	}
+
+	for _, s := range g.weights {
+		name, weight, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("invalid weight %q, expected DANCER=W", s)
+		}
+
+		dancer, err := findDancer(dancers, name)
+		if err != nil {
+			return err
+		}
+
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return fmt.Errorf("invalid weight %q: %w", s, err)
+		}
+		weights[dancer] = w
+	}
+
+	if len(weights) > 0 {
+		g.constraints.Weights = weights
+	}
+
+	return nil
+}
+
+// findDance looks up a dance by name, ignoring case.
+func findDance(dances []*model.Dance, name string) (*model.Dance, error) {
+	for _, dance := range dances {
+		if strings.EqualFold(dance.Name, name) {
+			return dance, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown dance %q", name)
+}
+
+// findPosition looks up a position in a dance by its number or, failing that,
+// its name.
+func findPosition(dance *model.Dance, name string) (*model.Position, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		for _, position := range dance.Positions {
+			if position.PositionID == id {
+				return position, nil
+			}
+		}
+	}
+
+	for _, position := range dance.Positions {
+		if strings.EqualFold(position.Name, name) {
+			return position, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%q has no position %q", dance.Name, name)
+}
+
+// parsePin parses a pin in the form `DANCER=DANCE:POSITION`. The dancer has to
+// be one of the dancers we're making the set for.
+func parsePin(pin string, dances []*model.Dance, dancers []*model.Dancer) (solver.Pin, error) {
+	dancerName, rest, ok := strings.Cut(pin, "=")
+	if !ok {
+		return solver.Pin{}, fmt.Errorf("invalid pin %q, expected DANCER=DANCE:POSITION", pin)
+	}
+
+	idx := strings.LastIndex(rest, ":")
+	if idx == -1 {
+		return solver.Pin{}, fmt.Errorf("invalid pin %q, expected DANCER=DANCE:POSITION", pin)
+	}
+	danceName, positionName := rest[:idx], rest[idx+1:]
+
+	dancer, err := findDancer(dancers, dancerName)
+	if err != nil {
+		return solver.Pin{}, fmt.Errorf("can't pin %s: %w", pin, err)
+	}
+
+	dance, err := findDance(dances, danceName)
+	if err != nil {
+		return solver.Pin{}, err
+	}
+
+	position, err := findPosition(dance, positionName)
+	if err != nil {
+		return solver.Pin{}, err
+	}
+
+	return solver.Pin{Dancer: dancer, Dance: dance, Position: position}, nil
+}
+
+// resolveConstraints turns the dance and dancer names given on the command
+// line into the solver's constraints.
+func (g *danceSetGenerator) resolveConstraints(dances []*model.Dance, dancers []*model.Dancer) error {
+	for _, name := range g.include {
+		dance, err := findDance(dances, name)
+		if err != nil {
+			return err
+		}
+		g.constraints.Include = append(g.constraints.Include, dance)
+	}
+
+	for _, name := range g.exclude {
+		dance, err := findDance(dances, name)
+		if err != nil {
+			return err
+		}
+		g.constraints.Exclude = append(g.constraints.Exclude, dance)
+	}
+
+	for _, p := range g.pins {
+		pin, err := parsePin(p, dances, dancers)
+		if err != nil {
+			return err
+		}
+		g.constraints.Pins = append(g.constraints.Pins, pin)
+	}
+
 	return nil
 }
 
@@ -52,7 +671,7 @@ func (g *danceSetGenerator) doGenerateDanceSet(c *cli.Context) error {
 		return err
 	}
 
-	dancers, err := m.FetchDancersByName(g.dancerNames)
+	dancers, event, err := fetchDancers(m, g.dancerNames, g.eventName)
 	if err != nil {
 		return err
 	}
@@ -62,12 +681,281 @@ func (g *danceSetGenerator) doGenerateDanceSet(c *cli.Context) error {
 		return err
 	}
 
-	set := solver.Solve(g.logger, positions)
-	if set.NumDancesDanced() == 0 {
+	if err := g.resolveConstraints(dances, dancers); err != nil {
+		return err
+	}
+
+	if err := g.resolveDancerLimits(dancers, event); err != nil {
+		return err
+	}
+
+	if err := g.resolveDancerWeights(dancers, event); err != nil {
+		return err
+	}
+
+	pairs, err := m.FetchDancerPairs(dancers)
+	if err != nil {
+		return err
+	}
+	g.constraints.Pairs = solver.PairsFromModel(pairs)
+
+	callers, err := m.FetchCallers(dancers, dances)
+	if err != nil {
+		return err
+	}
+	g.constraints.Callers = solver.CallersFromModel(callers)
+
+	if g.checkPath != "" {
+		return g.checkSet(dances, dancers, positions)
+	}
+
+	var previous *model.AssignmentSet
+	if g.repairPath != "" {
+		previous, err = loadSet(g.repairPath, dances, dancers)
+		if err != nil {
+			return err
+		}
+		g.constraints.Repair = previous
+	}
+
+	if g.alternatives > 1 {
+		results, err := g.solver.SolveAlternatives(c.Context, g.logger, positions, g.constraints, g.alternatives, g.minDifference, g.difference)
+		if err != nil {
+			return err
+		}
+
+		if len(results) == 0 || results[0].Set.NumDancesDanced() == 0 {
+			if c.Context.Err() != nil {
+				fmt.Println("Stopped before finding a set")
+			} else {
+				fmt.Println("Can't dance any dances")
+			}
+			return nil
+		}
+
+		fmt.Print(formatAlternatives(dances, results))
+		warnIfCancelled(results[len(results)-1])
+
+		return nil
+	}
+
+	result, err := g.solver.Solve(c.Context, g.logger, positions, g.constraints)
+	if err != nil {
+		return err
+	}
+
+	set := result.Set
+	if g.withSubs {
+		set = set.WithSubstitutes(solver.FindSubstitutes(set, positions, g.constraints))
+	}
+
+	if g.output != outputText {
+		saved := set.Saved().WithPreferences(positions)
+		saved.Status = strings.ToLower(result.Status.String())
+		if err := writeSet(os.Stdout, g.output, saved); err != nil {
+			return err
+		}
+		warnIfCancelled(result)
+
+		if set.NumDancesDanced() == 0 && result.Status == solver.SolverStatusCancelled {
+			return nil
+		}
+		return g.writeFiles(dances, dancers, event, set)
+	}
+
+	switch {
+	case set.NumDancesDanced() > 0:
+		fmt.Print(g.formatSet(dances, set))
+		warnIfCancelled(result)
+	case result.Status == solver.SolverStatusCancelled:
+		fmt.Println("Stopped before finding a set")
+		return nil
+	default:
 		fmt.Println("Can't dance any dances")
+	}
+
+	if previous != nil {
+		changes := formatChanges(dances, *previous, set)
+		fmt.Println()
+		if changes == "" {
+			fmt.Println("No changes")
+		} else {
+			fmt.Println("Changes:")
+			fmt.Print(changes)
+		}
+	}
+
+	if err := g.writeFiles(dances, dancers, event, set); err != nil {
+		return err
+	}
+
+	if g.explain {
+		explanations, err := g.explainSet(m, dances, dancers, positions, set, result.Status)
+		if err != nil {
+			return err
+		}
+
+		if len(explanations) > 0 {
+			fmt.Println()
+			fmt.Println("Not danced:")
+			for _, explanation := range explanations {
+				fmt.Println(explanation)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkSet prints the set saved in `--check` and anything wrong with it, such
+// as somebody in a position they've said "no" to, or who isn't here.
+func (g *danceSetGenerator) checkSet(dances []*model.Dance, dancers []*model.Dancer, positions []*model.DancerPosition) error {
+	set, err := loadSet(g.checkPath, dances, dancers)
+	if err != nil {
+		return err
+	}
+	if g.withSubs {
+		*set = set.WithSubstitutes(solver.FindSubstitutes(*set, positions, g.constraints))
+	}
+
+	fmt.Print(g.formatSet(dances, *set))
+	fmt.Println()
+
+	violations := solver.Validate(*set, positions, g.constraints)
+	if len(violations) == 0 {
+		fmt.Println("No problems")
 		return nil
 	}
 
+	fmt.Println("Problems:")
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+
+	return cli.Exit(fmt.Sprintf("%d problem(s) with the set", len(violations)), 1)
+}
+
+// loadSet reads a set saved with `--save`.
+func loadSet(path string, dances []*model.Dance, dancers []*model.Dancer) (*model.AssignmentSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	set, err := model.LoadAssignmentSet(f, dances, dancers)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &set, nil
+}
+
+// writeFiles saves the set if `--save` was given, and writes the set sheet if
+// `--html` was.
+func (g *danceSetGenerator) writeFiles(dances []*model.Dance, dancers []*model.Dancer, event *model.Event, set model.AssignmentSet) error {
+	if g.savePath != "" {
+		if err := saveSet(g.savePath, set); err != nil {
+			return err
+		}
+	}
+
+	if g.htmlPath != "" {
+		return saveHTML(g.htmlPath, newHTMLSet(dances, dancers, event, set, g.cards))
+	}
+
+	return nil
+}
+
+func saveSet(path string, set model.AssignmentSet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := set.Save(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// formatChanges lists what's different in `set` compared to `previous`, dance
+// by dance, so the changes can be passed on to whoever needs to know.
+func formatChanges(dances []*model.Dance, previous model.AssignmentSet, set model.AssignmentSet) string {
+	var sb strings.Builder
+
+	for _, dance := range dances {
+		wasDanced, isDanced := dance.IsDanced(previous), dance.IsDanced(set)
+
+		switch {
+		case wasDanced && !isDanced:
+			fmt.Fprintf(&sb, "%s: dropped\n", dance.Name)
+			continue
+		case !wasDanced && isDanced:
+			fmt.Fprintf(&sb, "%s: added\n", dance.Name)
+			continue
+		case !wasDanced && !isDanced:
+			continue
+		}
+
+		for _, position := range dance.Positions {
+			before, after := previous.DancerFor(dance, position), set.DancerFor(dance, position)
+			if before != nil && after != nil && before.ID == after.ID {
+				continue
+			}
+
+			beforeName := "nobody"
+			if before != nil {
+				beforeName = before.Name
+			}
+			fmt.Fprintf(&sb, "%s: %s: %s -> %s\n", dance.Name, position.Name, beforeName, after.Name)
+		}
+	}
+
+	return sb.String()
+}
+
+// explainSet explains why dances aren't in the set, including which of the
+// active dancers who aren't here could make a difference.
+func (g *danceSetGenerator) explainSet(
+	m *model.Model,
+	dances []*model.Dance,
+	dancers []*model.Dancer,
+	positions []*model.DancerPosition,
+	set model.AssignmentSet,
+	status solver.SolverStatus,
+) ([]solver.Explanation, error) {
+	everyone, err := m.FetchDancers()
+	if err != nil {
+		return nil, err
+	}
+
+	here := make(map[int]struct{}, len(dancers))
+	for _, dancer := range dancers {
+		here[dancer.ID] = struct{}{}
+	}
+
+	var absent []*model.Dancer
+	for _, dancer := range everyone {
+		if _, ok := here[dancer.ID]; !ok && dancer.Active {
+			absent = append(absent, dancer)
+		}
+	}
+
+	var candidates []*model.DancerPosition
+	if len(absent) > 0 {
+		_, candidates, err = m.FetchDancerPositionsForDancers(absent)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return solver.Explain(dances, positions, set, status, g.constraints, candidates), nil
+}
+
+func (g *danceSetGenerator) formatSet(dances []*model.Dance, set model.AssignmentSet) string {
 	var sb strings.Builder
 
 	for _, dance := range dances {
@@ -84,10 +972,104 @@ func (g *danceSetGenerator) doGenerateDanceSet(c *cli.Context) error {
 			sb.WriteString(": ")
 			sb.WriteString(set.DancerFor(dance, position).Name)
 			sb.WriteString("\n")
+
+			if set.HasSubstitutes() {
+				sb.WriteString("  subs: ")
+				sb.WriteString(formatSubstitutes(set.SubstitutesFor(dance, position)))
+				sb.WriteString("\n")
+			}
+		}
+
+		if caller := set.CallerFor(dance); caller != nil {
+			sb.WriteString("Caller: ")
+			sb.WriteString(caller.Name)
+			sb.WriteString("\n")
 		}
 	}
 
-	fmt.Print(sb.String())
+	return sb.String()
+}
 
-	return nil
+// formatSubstitutes lists substitutes in order, with how much they like the
+// position and how many dances they're doing already.
+func formatSubstitutes(substitutes []model.Substitute) string {
+	if len(substitutes) == 0 {
+		return "nobody"
+	}
+
+	s := make([]string, 0, len(substitutes))
+	for _, substitute := range substitutes {
+		s = append(s, fmt.Sprintf("%s (%s, %d dances)", substitute.Dancer.Name, substitute.Preference, substitute.Dances))
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// formatAlternatives lays the sets out side by side, one column per set, so
+// they can be compared.
+func formatAlternatives(dances []*model.Dance, results []solver.SolveResult) string {
+	headers := make([]string, 0, len(results))
+	sets := make([]model.AssignmentSet, 0, len(results))
+	for i, result := range results {
+		headers = append(headers, fmt.Sprintf("Set %d (score %d)", i+1, result.Objective))
+		sets = append(sets, result.Set)
+	}
+
+	return formatColumns(dances, headers, sets)
+}
+
+// formatColumns lays sets out side by side, one column per set under the given
+// headers. Dances which aren't in any of the sets are left out.
+func formatColumns(dances []*model.Dance, headers []string, sets []model.AssignmentSet) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "\t"+strings.Join(headers, "\t"))
+
+	for _, dance := range dances {
+		danced := false
+		row := []string{dance.Name}
+		for _, set := range sets {
+			mark := "-"
+			if dance.IsDanced(set) {
+				mark = "✓"
+				danced = true
+			}
+			row = append(row, mark)
+		}
+
+		if !danced {
+			continue
+		}
+
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+
+		for _, position := range dance.Positions {
+			row := []string{"  " + position.Name}
+			for _, set := range sets {
+				name := ""
+				if dance.IsDanced(set) {
+					name = set.DancerFor(dance, position).Name
+				}
+				row = append(row, name)
+			}
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+
+		if dance.Caller != model.CallerNone {
+			row := []string{"  Caller"}
+			for _, set := range sets {
+				name := ""
+				if caller := set.CallerFor(dance); caller != nil {
+					name = caller.Name
+				}
+				row = append(row, name)
+			}
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+	}
+
+	w.Flush()
+
+	return sb.String()
 }
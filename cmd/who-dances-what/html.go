@@ -0,0 +1,195 @@
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+//go:embed set.html.tmpl
+var setTemplateText string
+
+var setTemplate = template.Must(template.New("set").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(setTemplateText))
+
+// htmlSet is what the set sheet template is given.
+type htmlSet struct {
+	Title     string
+	Date      string
+	Musicians []string
+	Dances    []htmlDance
+	// Cards has one card per dancer, if they were asked for.
+	Cards []htmlCard
+}
+
+type htmlDance struct {
+	Order  int
+	Name   string
+	Note   string
+	Caller string
+	// Rows lay the positions out as they are in the set, with each position
+	// next to the one facing it, or two by two if they don't face anybody.
+	Rows [][]htmlPosition
+}
+
+type htmlPosition struct {
+	Name   string
+	Dancer string
+}
+
+type htmlCard struct {
+	Dancer string
+	Dances []htmlCardDance
+}
+
+type htmlCardDance struct {
+	Order    int
+	Dance    string
+	Position string
+}
+
+// positionRows pairs each of a dance's positions with the one facing it, in
+// the order the positions come in the dance. Positions which don't face
+// anybody are paired with the next one which doesn't either, so a dance
+// without facing positions is laid out two by two, as 1 and 2, 3 and 4 and so
+// on, as it would be in a set.
+func positionRows(dance *model.Dance) [][]*model.Position {
+	byID := make(map[int]*model.Position, len(dance.Positions))
+	for _, position := range dance.Positions {
+		byID[position.PositionID] = position
+	}
+
+	placed := make(map[int]struct{}, len(dance.Positions))
+	isPlaced := func(position *model.Position) bool {
+		_, ok := placed[position.PositionID]
+		return ok
+	}
+
+	var rows [][]*model.Position
+	for i, position := range dance.Positions {
+		if isPlaced(position) {
+			continue
+		}
+		placed[position.PositionID] = struct{}{}
+
+		partner := byID[position.FacingID]
+		if partner == nil {
+			// somebody facing this position
+			for _, next := range dance.Positions[i+1:] {
+				if !isPlaced(next) && next.FacingID == position.PositionID {
+					partner = next
+					break
+				}
+			}
+		}
+		if partner == nil {
+			for _, next := range dance.Positions[i+1:] {
+				if !isPlaced(next) && byID[next.FacingID] == nil {
+					partner = next
+					break
+				}
+			}
+		}
+
+		row := []*model.Position{position}
+		if partner != nil && !isPlaced(partner) {
+			placed[partner.PositionID] = struct{}{}
+			row = append(row, partner)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// newHTMLSet gathers up what's on the set sheet. The musicians are whoever is
+// here who plays, and `event` gives the title and date if there is one.
+func newHTMLSet(dances []*model.Dance, dancers []*model.Dancer, event *model.Event, set model.AssignmentSet, cards bool) htmlSet {
+	sheet := htmlSet{Title: "Dance set"}
+	if event != nil {
+		sheet.Title = event.Name
+		if !event.Date.IsZero() {
+			sheet.Date = event.Date.Format("Monday 2 January 2006")
+		}
+	}
+
+	for _, dancer := range dancers {
+		if dancer.Type == model.RoleMusician || dancer.Type == model.RoleBoth {
+			sheet.Musicians = append(sheet.Musicians, dancer.Name)
+		}
+	}
+
+	byDancer := make(map[int]*htmlCard)
+	card := func(dancer *model.Dancer) *htmlCard {
+		c, ok := byDancer[dancer.ID]
+		if !ok {
+			c = &htmlCard{Dancer: dancer.Name}
+			byDancer[dancer.ID] = c
+		}
+		return c
+	}
+
+	order := 0
+	for _, dance := range dances {
+		if !dance.IsDanced(set) {
+			continue
+		}
+		order++
+
+		hd := htmlDance{Order: order, Name: dance.Name, Note: dance.Note}
+		for _, row := range positionRows(dance) {
+			var hrow []htmlPosition
+			for _, position := range row {
+				dancer := set.DancerFor(dance, position)
+				hrow = append(hrow, htmlPosition{Name: position.Name, Dancer: dancer.Name})
+
+				c := card(dancer)
+				c.Dances = append(c.Dances, htmlCardDance{Order: order, Dance: dance.Name, Position: position.Name})
+			}
+			hd.Rows = append(hd.Rows, hrow)
+		}
+
+		if caller := set.CallerFor(dance); caller != nil {
+			hd.Caller = caller.Name
+
+			c := card(caller)
+			c.Dances = append(c.Dances, htmlCardDance{Order: order, Dance: dance.Name, Position: "Caller"})
+		}
+
+		sheet.Dances = append(sheet.Dances, hd)
+	}
+
+	if cards {
+		for _, c := range byDancer {
+			sort.SliceStable(c.Dances, func(i, j int) bool { return c.Dances[i].Order < c.Dances[j].Order })
+			sheet.Cards = append(sheet.Cards, *c)
+		}
+		sort.Slice(sheet.Cards, func(i, j int) bool { return sheet.Cards[i].Dancer < sheet.Cards[j].Dancer })
+	}
+
+	return sheet
+}
+
+func writeHTML(w io.Writer, sheet htmlSet) error {
+	return setTemplate.Execute(w, sheet)
+}
+
+func saveHTML(path string, sheet htmlSet) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := writeHTML(f, sheet); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// newTestDance makes a dance with positions named after their IDs, each
+// facing the position given in `facing`, if any.
+func newTestDance(id int, name string, n int, facing map[int]int) *model.Dance {
+	dance := &model.Dance{ID: id, Name: name, Active: true}
+	for p := 1; p <= n; p++ {
+		dance.Positions = append(dance.Positions, &model.Position{
+			PositionID: p,
+			Name:       string(rune('0' + p)),
+			DanceID:    id,
+			Dance:      dance,
+			FacingID:   facing[p],
+		})
+	}
+
+	return dance
+}
+
+func TestPositionRows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		positions int
+		facing    map[int]int
+		want      [][]string
+	}{
+		{"facing", 4, map[int]int{1: 3, 3: 1, 2: 4, 4: 2}, [][]string{{"1", "3"}, {"2", "4"}}},
+		{"facing one way", 2, map[int]int{2: 1}, [][]string{{"1", "2"}}},
+		{"facing back one way", 3, map[int]int{3: 1}, [][]string{{"1", "3"}, {"2"}}},
+		{"no facing", 6, nil, [][]string{{"1", "2"}, {"3", "4"}, {"5", "6"}}},
+		{"no facing, odd", 3, nil, [][]string{{"1", "2"}, {"3"}}},
+		{"solo", 1, nil, [][]string{{"1"}}},
+		{"some facing", 5, map[int]int{2: 4, 4: 2}, [][]string{{"1", "3"}, {"2", "4"}, {"5"}}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got [][]string
+			for _, row := range positionRows(newTestDance(1, "Dance", tt.positions, tt.facing)) {
+				var names []string
+				for _, position := range row {
+					names = append(names, position.Name)
+				}
+				got = append(got, names)
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// newTestHTMLSet has a set of two dances, one with facing positions and one
+// without, and a dance which isn't danced between them.
+func newTestHTMLSet(cards bool) htmlSet {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true, Type: model.RoleDancer}
+	bob := &model.Dancer{ID: 2, Name: "Bob <Robert>", Active: true, Type: model.RoleBoth}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true, Type: model.RoleDancer}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: true, Type: model.RoleDancer}
+	erin := &model.Dancer{ID: 5, Name: "Erin", Active: true, Type: model.RoleMusician}
+
+	beans := newTestDance(1, "Bean Setting", 4, nil)
+	beans.Note = "Sticks & bells"
+	skipped := newTestDance(2, "Not Danced", 1, nil)
+	jig := newTestDance(3, "Fool's Jig", 2, map[int]int{1: 2, 2: 1})
+
+	set := model.NewAssignmentSet(
+		model.Assignments{
+			beans: {beans.Positions[0]: alice, beans.Positions[1]: bob, beans.Positions[2]: carol, beans.Positions[3]: dave},
+			jig:   {jig.Positions[0]: carol, jig.Positions[1]: alice},
+		},
+		model.DancesDanced{beans: {}, jig: {}},
+	).WithCallers(model.Callers{jig: bob})
+
+	event := &model.Event{ID: 1, Name: "Ale", Date: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC)}
+
+	return newHTMLSet([]*model.Dance{beans, skipped, jig}, []*model.Dancer{alice, bob, carol, dave, erin}, event, set, cards)
+}
+
+func TestNewHTMLSet(t *testing.T) {
+	t.Parallel()
+
+	sheet := newTestHTMLSet(true)
+
+	require.Equal(t, "Ale", sheet.Title)
+	require.Equal(t, "Wednesday 1 May 2024", sheet.Date)
+	require.Equal(t, []string{"Bob <Robert>", "Erin"}, sheet.Musicians)
+
+	require.Equal(t, []htmlDance{
+		{
+			Order: 1,
+			Name:  "Bean Setting",
+			Note:  "Sticks & bells",
+			// no facing positions, so two by two
+			Rows: [][]htmlPosition{
+				{{"1", "Alice"}, {"2", "Bob <Robert>"}},
+				{{"3", "Carol"}, {"4", "Dave"}},
+			},
+		},
+		{
+			Order:  2,
+			Name:   "Fool's Jig",
+			Caller: "Bob <Robert>",
+			Rows:   [][]htmlPosition{{{"1", "Carol"}, {"2", "Alice"}}},
+		},
+	}, sheet.Dances)
+
+	require.Equal(t, []htmlCard{
+		{Dancer: "Alice", Dances: []htmlCardDance{{1, "Bean Setting", "1"}, {2, "Fool's Jig", "2"}}},
+		{Dancer: "Bob <Robert>", Dances: []htmlCardDance{{1, "Bean Setting", "2"}, {2, "Fool's Jig", "Caller"}}},
+		{Dancer: "Carol", Dances: []htmlCardDance{{1, "Bean Setting", "3"}, {2, "Fool's Jig", "1"}}},
+		{Dancer: "Dave", Dances: []htmlCardDance{{1, "Bean Setting", "4"}}},
+	}, sheet.Cards)
+
+	t.Run("without cards or an event", func(t *testing.T) {
+		t.Parallel()
+
+		sheet := newHTMLSet(nil, nil, nil, model.NewAssignmentSet(model.Assignments{}, model.DancesDanced{}), false)
+		require.Equal(t, htmlSet{Title: "Dance set"}, sheet)
+	})
+}
+
+func TestWriteHTML(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeHTML(&buf, newTestHTMLSet(true)))
+	requireGolden(t, "set.html", buf.Bytes())
+}
@@ -48,11 +48,19 @@ func doListDances(c *cli.Context, logger *logrus.Entry) error {
 		return err
 	}
 
+	dancesByID := make(map[int]*model.Dance, len(dances))
+	for _, dance := range dances {
+		dancesByID[dance.ID] = dance
+	}
+
 	var sb strings.Builder
 
 	for _, dance := range dances {
 		sb.WriteString("Dance: ")
 		sb.WriteString(dance.Name)
+		if full, ok := dancesByID[dance.VariantOf]; ok {
+			fmt.Fprintf(&sb, " (%d person version of %s)", len(dance.Positions), full.Name)
+		}
 		sb.WriteString("\n")
 
 		for _, position := range dance.Positions {
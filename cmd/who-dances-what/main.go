@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
@@ -34,10 +36,26 @@ func main() {
 			listDances(logger.WithField("command", "list-dances")),
 			listActiveDancers(logger.WithField("command", "list-active-dancers")),
 			danceSet(logger.WithField("command", "dance-set")),
+			season(logger.WithField("command", "season")),
+			whatIf(logger.WithField("command", "what-if")),
+			coverage(logger.WithField("command", "coverage")),
+			recommendTraining(logger.WithField("command", "recommend-training")),
+			tui(logger.WithField("command", "tui")),
+			solveDump(logger.WithField("command", "solve-dump")),
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	// Ctrl-C stops the solver, which then gives the best set it's found so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// ...and a second Ctrl-C exits straight away, if that's taking too long
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := app.RunContext(ctx, os.Args); err != nil {
 		logger.Fatal(err)
 	}
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// The formats sets can be written in. Everything but text follows the schema
+// of model.SavedSet.
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputCSV      = "csv"
+	outputMarkdown = "markdown"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputYAML, outputCSV, outputMarkdown}
+
+// outputFlag picks how sets are written out.
+func outputFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "output",
+		Value: outputText,
+		Usage: fmt.Sprintf("Write the set as %s. Apart from text, these have the same fields as sets saved with --save, plus the solver's status and each dancer's preference for their position. CSV has one row per position, with the columns %s", strings.Join(outputFormats, ", "), strings.Join(csvHeader, ",")),
+	}
+}
+
+func parseOutput(c *cli.Context) (string, error) {
+	format := c.String("output")
+	for _, f := range outputFormats {
+		if f == format {
+			return format, nil
+		}
+	}
+
+	return "", cli.Exit(fmt.Sprintf("unknown --output %q, expected one of %s", format, strings.Join(outputFormats, ", ")), 1)
+}
+
+// writeSet writes `saved` to `w` in one of the formats other than text.
+func writeSet(w io.Writer, format string, saved model.SavedSet) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(saved)
+	case outputYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(saved); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case outputCSV:
+		return writeSetCSV(w, saved)
+	case outputMarkdown:
+		_, err := io.WriteString(w, formatSetMarkdown(saved))
+		return err
+	default:
+		return fmt.Errorf("can't write a set as %q", format)
+	}
+}
+
+var csvHeader = []string{"order", "dance_id", "dance", "position_id", "position", "dancer_id", "dancer", "preference", "caller", "status"}
+
+// writeSetCSV writes one row per position, so the set can go straight into a
+// spreadsheet.
+func writeSetCSV(w io.Writer, saved model.SavedSet) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, dance := range saved.Dances {
+		for _, position := range dance.Positions {
+			row := []string{
+				strconv.Itoa(dance.Order),
+				strconv.Itoa(dance.ID),
+				dance.Name,
+				strconv.Itoa(position.ID),
+				position.Name,
+				strconv.Itoa(position.DancerID),
+				position.Dancer,
+				position.Preference,
+				dance.Caller,
+				saved.Status,
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// formatSetMarkdown lays the set out as a table, which chat apps show nicely.
+func formatSetMarkdown(saved model.SavedSet) string {
+	var sb strings.Builder
+
+	if saved.Status != "" {
+		fmt.Fprintf(&sb, "Status: %s\n\n", saved.Status)
+	}
+
+	sb.WriteString("| # | Dance | Position | Dancer | Preference |\n")
+	sb.WriteString("|---|---|---|---|---|\n")
+
+	for _, dance := range saved.Dances {
+		for _, position := range dance.Positions {
+			fmt.Fprintf(&sb, "| %d | %s | %s | %s | %s |\n",
+				dance.Order, markdownEscape(dance.Name), markdownEscape(position.Name), markdownEscape(position.Dancer), position.Preference)
+		}
+
+		if dance.Caller != "" {
+			fmt.Fprintf(&sb, "| %d | %s | Caller | %s | |\n", dance.Order, markdownEscape(dance.Name), markdownEscape(dance.Caller))
+		}
+	}
+
+	return sb.String()
+}
+
+// markdownEscape stops names breaking the table.
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with what the tests produce")
+
+// requireGolden compares `got` with testdata/`name`, or writes it there with
+// -update.
+func requireGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(want), string(got))
+}
+
+// newTestSavedSet is a set of two dances, with names which need escaping in
+// CSV and markdown, a caller, a substitute, and somebody without a
+// preference for their position.
+func newTestSavedSet() model.SavedSet {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob \"Bobby\" Smith", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+
+	newDance := func(id int, name string, positions ...string) *model.Dance {
+		dance := &model.Dance{ID: id, Name: name, Active: true}
+		for i, position := range positions {
+			dance.Positions = append(dance.Positions, &model.Position{PositionID: i + 1, Name: position, DanceID: id, Dance: dance})
+		}
+		return dance
+	}
+
+	beans := newDance(1, "Bean Setting", "1", "2")
+	jig := newDance(2, "Jig | Solo, Again", "Solo")
+
+	dp := func(dancer *model.Dancer, dance *model.Dance, position int, preference model.DancePreference) *model.DancerPosition {
+		return &model.DancerPosition{
+			DancerID:   dancer.ID,
+			DanceID:    dance.ID,
+			PositionID: position,
+			Dancer:     dancer,
+			Dance:      dance,
+			Position:   dance.Positions[position-1],
+			Preference: preference,
+		}
+	}
+
+	dps := []*model.DancerPosition{
+		dp(alice, beans, 1, model.PreferenceFavourite),
+		dp(bob, beans, 2, model.PreferenceMaybe),
+		dp(carol, beans, 2, model.PreferenceYes),
+		// nothing for Carol's solo
+	}
+
+	set := model.NewAssignmentSet(
+		model.Assignments{
+			beans: {beans.Positions[0]: alice, beans.Positions[1]: bob},
+			jig:   {jig.Positions[0]: carol},
+		},
+		model.DancesDanced{beans: {}, jig: {}},
+	).WithCallers(model.Callers{jig: alice}).WithSubstitutes(model.Substitutes{
+		beans: {beans.Positions[1]: {{Dancer: carol, Preference: model.PreferenceYes, Dances: 1}}},
+	})
+
+	saved := set.Saved().WithPreferences(dps)
+	saved.Status = "optimal"
+
+	return saved
+}
+
+func TestWriteSet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format string
+		golden string
+	}{
+		{outputJSON, "set.json"},
+		{outputYAML, "set.yaml"},
+		{outputCSV, "set.csv"},
+		{outputMarkdown, "set.md"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.format, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			require.NoError(t, writeSet(&buf, tt.format, newTestSavedSet()))
+			requireGolden(t, tt.golden, buf.Bytes())
+		})
+	}
+
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+
+		// text is written by dance-set itself, not writeSet
+		require.EqualError(t, writeSet(&bytes.Buffer{}, outputText, newTestSavedSet()), `can't write a set as "text"`)
+	})
+}
+
+func TestWithPreferences(t *testing.T) {
+	t.Parallel()
+
+	saved := newTestSavedSet()
+
+	var preferences [][]string
+	for _, dance := range saved.Dances {
+		var ps []string
+		for _, position := range dance.Positions {
+			ps = append(ps, position.Preference)
+		}
+		preferences = append(preferences, ps)
+	}
+
+	require.Equal(t, [][]string{{"favourite", "maybe"}, {""}}, preferences)
+}
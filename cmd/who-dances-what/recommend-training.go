@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/iainlane/who-dances-what/internal/solver"
+)
+
+func recommendTraining(logger *logrus.Entry) *cli.Command {
+	return &cli.Command{
+		Name:  "recommend-training",
+		Usage: "Suggest who should learn which position, to unlock the most dances for the people who usually turn up",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "events",
+				Usage: "How many past events to take the usual turnout from. If there aren't any, everybody who's active is used",
+				Value: 10,
+			},
+			&cli.IntFlag{
+				Name:  "top",
+				Usage: "How many suggestions to show. 0 shows them all",
+				Value: 10,
+			},
+		},
+		Action: func(c *cli.Context) error { return doRecommendTraining(c, logger) },
+	}
+}
+
+func doRecommendTraining(c *cli.Context, logger *logrus.Entry) error {
+	m, err := model.NewModel(c.String("db"), logger)
+	if err != nil {
+		return err
+	}
+
+	everyone, err := m.FetchDancers()
+	if err != nil {
+		return err
+	}
+
+	var active []*model.Dancer
+	byID := make(map[int]*model.Dancer)
+	for _, dancer := range everyone {
+		if dancer.Active {
+			active = append(active, dancer)
+			byID[dancer.ID] = dancer
+		}
+	}
+
+	events, err := m.FetchEventsBefore(time.Now(), c.Int("events"))
+	if err != nil {
+		return err
+	}
+
+	// the turnouts use the dancers fetched above, so they match the ones in
+	// the preferences. Anybody who's stopped coming is left out.
+	var turnouts [][]*model.Dancer
+	for _, event := range events {
+		var turnout []*model.Dancer
+		for _, attendance := range event.Attendances {
+			if dancer, ok := byID[attendance.DancerID]; ok {
+				turnout = append(turnout, dancer)
+			}
+		}
+		turnouts = append(turnouts, turnout)
+	}
+
+	if len(turnouts) == 0 {
+		logger.Info("No past events, so using everybody who's active")
+		turnouts = [][]*model.Dancer{active}
+	}
+
+	dances, positions, err := m.FetchDancerPositionsForDancers(active)
+	if err != nil {
+		return err
+	}
+
+	var activeDances []*model.Dance
+	for _, dance := range dances {
+		if dance.Active {
+			activeDances = append(activeDances, dance)
+		}
+	}
+
+	callers, err := m.FetchCallers(active, activeDances)
+	if err != nil {
+		return err
+	}
+
+	trainings := solver.RecommendTraining(activeDances, positions, turnouts, solver.Constraints{
+		Callers: solver.CallersFromModel(callers),
+	})
+
+	if len(trainings) == 0 {
+		fmt.Println("Nobody learning one position would unlock any dances")
+		return nil
+	}
+
+	if top := c.Int("top"); top > 0 && len(trainings) > top {
+		trainings = trainings[:top]
+	}
+
+	for i, training := range trainings {
+		fmt.Printf("%d. %s\n", i+1, formatTraining(training, len(turnouts)))
+	}
+
+	return nil
+}
+
+// formatTraining says who should learn what, what it unlocks, and for how many
+// of the `turnouts`.
+func formatTraining(training solver.Training, turnouts int) string {
+	names := make([]string, 0, len(training.Dances))
+	for _, dance := range training.Dances {
+		names = append(names, dance.Name)
+	}
+
+	s := fmt.Sprintf("%s: learn %s, unlocking %s", training.Dancer.Name, training.Position.Name, strings.Join(names, ", "))
+
+	if turnouts > 1 {
+		s += fmt.Sprintf(" at %d of %d events", training.Turnouts, turnouts)
+	}
+
+	return s
+}
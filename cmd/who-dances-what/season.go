@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/iainlane/who-dances-what/internal/solver"
+)
+
+func season(logger *logrus.Entry) *cli.Command {
+	return &cli.Command{
+		Name:      "season",
+		Usage:     "Generate the sets for several events at once, sharing the dances out over all of them",
+		ArgsUsage: "EVENT...",
+		Flags:     append(setLengthFlags(), solverFlags()...),
+		Action:    func(c *cli.Context) error { return doSeason(c, logger) },
+	}
+}
+
+// fetchSeason fetches the events with the given names, in date order.
+func fetchSeason(m *model.Model, names []string) ([]*model.Event, error) {
+	events := make([]*model.Event, 0, len(names))
+	for _, name := range names {
+		event, err := m.FetchEventByName(name)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Date.Before(events[j].Date)
+	})
+
+	return events, nil
+}
+
+func doSeason(c *cli.Context, logger *logrus.Entry) error {
+	names := c.Args().Slice()
+	if len(names) == 0 {
+		return cli.Exit("No events specified", 1)
+	}
+
+	minDances, maxDances, err := parseSetLength(c)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSolverFromFlags(c, solver.Options{})
+	if err != nil {
+		return err
+	}
+
+	m, err := model.NewModel(c.String("db"), logger)
+	if err != nil {
+		return err
+	}
+
+	events, err := fetchSeason(m, names)
+	if err != nil {
+		return err
+	}
+
+	// everybody's fetched together, so each dance and dancer is the same
+	// across all of the events
+	var dancers []*model.Dancer
+	seen := make(map[int]struct{})
+	for _, event := range events {
+		for _, dancer := range event.Dancers() {
+			if _, ok := seen[dancer.ID]; ok {
+				continue
+			}
+			seen[dancer.ID] = struct{}{}
+			dancers = append(dancers, dancer)
+		}
+	}
+
+	dances, positions, err := m.FetchDancerPositionsForDancers(dancers)
+	if err != nil {
+		return err
+	}
+
+	// dances which need a caller can only be danced at events where somebody
+	// who can call them is
+	callers, err := m.FetchCallers(dancers, dances)
+	if err != nil {
+		return err
+	}
+
+	seasonEvents := make([]solver.SeasonEvent, 0, len(events))
+	for _, event := range events {
+		attending := make(map[int]struct{}, len(event.Attendances))
+		for _, attendance := range event.Attendances {
+			attending[attendance.DancerID] = struct{}{}
+		}
+
+		seasonEvent := solver.SeasonEvent{Name: event.Name}
+		for _, dp := range positions {
+			if _, ok := attending[dp.DancerID]; ok {
+				seasonEvent.DancerPositions = append(seasonEvent.DancerPositions, dp)
+			}
+		}
+		seasonEvents = append(seasonEvents, seasonEvent)
+	}
+
+	results, err := s.SolveSeason(c.Context, logger, seasonEvents, solver.Constraints{
+		MinDances: minDances,
+		MaxDances: maxDances,
+		Callers:   solver.CallersFromModel(callers),
+	})
+	if err != nil {
+		return err
+	}
+
+	// every event has at least one dance, if there's a solution at all
+	if len(results) == 0 || results[0].Set.NumDancesDanced() == 0 {
+		if c.Context.Err() != nil {
+			fmt.Println("Stopped before finding the sets")
+		} else {
+			fmt.Println("Can't make a set for every event")
+		}
+		return nil
+	}
+
+	headers := make([]string, 0, len(events))
+	sets := make([]model.AssignmentSet, 0, len(results))
+	for i, result := range results {
+		event := events[i]
+		header := event.Name
+		if !event.Date.IsZero() {
+			header = fmt.Sprintf("%s (%s)", event.Name, event.Date.Format("2006-01-02"))
+		}
+		headers = append(headers, header)
+		sets = append(sets, result.Set)
+	}
+
+	fmt.Print(formatColumns(dances, headers, sets))
+	warnIfCancelled(results[0])
+
+	return nil
+}
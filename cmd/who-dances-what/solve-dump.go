@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/iainlane/who-dances-what/internal/solver"
+)
+
+func solveDump(logger *logrus.Entry) *cli.Command {
+	return &cli.Command{
+		Name:      "solve-dump",
+		Usage:     "Solve a model written out with --dump-model again, to reproduce a set",
+		ArgsUsage: "MODEL",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:      "dump-response",
+				Usage:     "Write the solver's response to `FILE`",
+				TakesFile: true,
+			},
+			dumpFormatFlag(),
+		},
+		Action: func(c *cli.Context) error { return doSolveDump(c, logger) },
+	}
+}
+
+func doSolveDump(c *cli.Context, logger *logrus.Entry) error {
+	if c.NArg() != 1 {
+		return cli.Exit("Expected one model to solve", 1)
+	}
+
+	format, err := parseDumpFormat(c)
+	if err != nil {
+		return err
+	}
+
+	result, err := solver.ReplayDump(logger, c.Args().First(), c.String("dump-response"), format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Status: %s\n", result.Status)
+	fmt.Printf("Objective: %d\n", result.Objective)
+	fmt.Println()
+	fmt.Println(result.Stats)
+
+	return nil
+}
@@ -0,0 +1,782 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/iainlane/who-dances-what/internal/solver"
+)
+
+func tui(logger *logrus.Entry) *cli.Command {
+	return &cli.Command{
+		Name:  "tui",
+		Usage: "Build a set interactively: tick off who's here, solve, and move people around by hand",
+		Flags: append(append(setLengthFlags(), solverFlags()...),
+			&cli.StringFlag{
+				Name:  "event",
+				Usage: "Start with the dancers attending this event ticked off",
+			},
+		),
+		Action: func(c *cli.Context) error { return doTUI(c, logger) },
+	}
+}
+
+// The panes keys are sent to.
+const (
+	tuiPaneDancers = iota
+	tuiPaneSet
+)
+
+// What the main loop has to do after a key.
+type tuiAction int
+
+const (
+	tuiNothing tuiAction = iota
+	tuiQuit
+	tuiSolve
+)
+
+// tuiPrompt asks for a line of text at the bottom of the screen.
+type tuiPrompt struct {
+	label string
+	input string
+	// done is given what was typed, and returns what to say about it.
+	done func(string) string
+}
+
+// tuiSlot is one position in the set, which can be moved between.
+type tuiSlot struct {
+	dance    *model.Dance
+	position *model.Position
+}
+
+// tuiState is everything on the screen. Everybody who's active is fetched up
+// front, and whoever isn't ticked off is left out when working out what can
+// be danced.
+type tuiState struct {
+	dances  []*model.Dance
+	dancers []*model.Dancer
+	dps     []*model.DancerPosition
+	pairs   []*model.DancerPair
+	callers []*model.DancerCaller
+
+	minDances int
+	maxDances int
+
+	present   map[int]struct{}
+	danceable []*model.Dance
+
+	set        *model.AssignmentSet
+	status     solver.SolverStatus
+	violations []solver.Violation
+
+	pane         int
+	dancerCursor int
+	slotCursor   int
+
+	message string
+	prompt  *tuiPrompt
+}
+
+func doTUI(c *cli.Context, logger *logrus.Entry) error {
+	minDances, maxDances, err := parseSetLength(c)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSolverFromFlags(c, solver.Options{})
+	if err != nil {
+		return err
+	}
+
+	m, err := model.NewModel(c.String("db"), logger)
+	if err != nil {
+		return err
+	}
+
+	everyone, err := m.FetchDancers()
+	if err != nil {
+		return err
+	}
+
+	st := &tuiState{
+		minDances: minDances,
+		maxDances: maxDances,
+		present:   make(map[int]struct{}),
+	}
+	for _, dancer := range everyone {
+		if dancer.Active {
+			st.dancers = append(st.dancers, dancer)
+		}
+	}
+
+	st.dances, st.dps, err = m.FetchDancerPositionsForDancers(st.dancers)
+	if err != nil {
+		return err
+	}
+
+	st.pairs, err = m.FetchDancerPairs(st.dancers)
+	if err != nil {
+		return err
+	}
+
+	st.callers, err = m.FetchCallers(st.dancers, st.dances)
+	if err != nil {
+		return err
+	}
+
+	if name := c.String("event"); name != "" {
+		event, err := m.FetchEventByName(name)
+		if err != nil {
+			return err
+		}
+		for _, attendance := range event.Attendances {
+			st.present[attendance.DancerID] = struct{}{}
+		}
+	}
+
+	st.refresh()
+
+	// raw mode, so keys are read as they're pressed and aren't echoed
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	// anything logged would be drawn over the screen
+	logOutput := logger.Logger.Out
+	logger.Logger.SetOutput(io.Discard)
+	defer logger.Logger.SetOutput(logOutput)
+
+	out := bufio.NewWriter(os.Stdout)
+	// switch to the alternate screen and hide the cursor, then put them back
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	keys := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go readKeys(os.Stdin, keys, done)
+
+	type solved struct {
+		result solver.SolveResult
+		err    error
+	}
+	results := make(chan solved, 1)
+
+	// solve runs the solver in the background, so it can be stopped
+	solve := func() context.CancelFunc {
+		ctx, cancel := context.WithCancel(c.Context)
+		dps, constraints := st.presentDPs(), st.constraints()
+		go func() {
+			result, err := s.Solve(ctx, logger, dps, constraints)
+			results <- solved{result, err}
+		}()
+
+		return cancel
+	}
+
+	var cancel context.CancelFunc
+	defer func() {
+		if cancel != nil {
+			cancel()
+		}
+	}()
+
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			return err
+		}
+		st.render(out, width, height)
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		select {
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			// while solving, keys can only stop it
+			if cancel != nil {
+				if key == "esc" || key == "ctrl-c" {
+					cancel()
+					st.message = "Stopping..."
+				}
+				continue
+			}
+
+			switch st.handleKey(key) {
+			case tuiQuit:
+				return nil
+			case tuiSolve:
+				cancel = solve()
+				st.message = "Solving... (Esc to stop)"
+			}
+
+		case r := <-results:
+			cancel()
+			cancel = nil
+			st.solved(r.result, r.err)
+		}
+	}
+}
+
+// readKeys sends each key pressed to `keys`, until there's nothing more to
+// read or `done` is closed. A read which is already waiting for a key can't be
+// interrupted, so it stops after the next key instead of blocking on sending
+// it.
+func readKeys(r io.Reader, keys chan<- string, done <-chan struct{}) {
+	defer close(keys)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for _, key := range parseKeys(buf[:n]) {
+			select {
+			case keys <- key:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// parseKeys turns what the terminal sent into key names: "up", "enter" and so
+// on for special keys, or the character typed.
+func parseKeys(b []byte) []string {
+	var keys []string
+	for i := 0; i < len(b); {
+		switch {
+		case b[i] == 0x1b && i+2 < len(b) && b[i+1] == '[':
+			switch b[i+2] {
+			case 'A':
+				keys = append(keys, "up")
+			case 'B':
+				keys = append(keys, "down")
+			case 'C':
+				keys = append(keys, "right")
+			case 'D':
+				keys = append(keys, "left")
+			}
+			i += 3
+		case b[i] == 0x1b:
+			keys = append(keys, "esc")
+			i++
+		case b[i] == '\t':
+			keys = append(keys, "tab")
+			i++
+		case b[i] == '\r' || b[i] == '\n':
+			keys = append(keys, "enter")
+			i++
+		case b[i] == 0x7f || b[i] == 0x08:
+			keys = append(keys, "backspace")
+			i++
+		case b[i] == 0x03:
+			keys = append(keys, "ctrl-c")
+			i++
+		default:
+			r, size := utf8.DecodeRune(b[i:])
+			keys = append(keys, string(r))
+			i += size
+		}
+	}
+
+	return keys
+}
+
+// presentDancers returns the dancers who are ticked off.
+func (st *tuiState) presentDancers() []*model.Dancer {
+	var dancers []*model.Dancer
+	for _, dancer := range st.dancers {
+		if _, ok := st.present[dancer.ID]; ok {
+			dancers = append(dancers, dancer)
+		}
+	}
+
+	return dancers
+}
+
+// presentDPs returns the preferences of the dancers who are ticked off.
+func (st *tuiState) presentDPs() []*model.DancerPosition {
+	var dps []*model.DancerPosition
+	for _, dp := range st.dps {
+		if _, ok := st.present[dp.DancerID]; ok {
+			dps = append(dps, dp)
+		}
+	}
+
+	return dps
+}
+
+// constraints are the pair rules and callers for whoever is ticked off.
+func (st *tuiState) constraints() solver.Constraints {
+	var pairs []*model.DancerPair
+	for _, pair := range st.pairs {
+		_, dancer := st.present[pair.DancerID]
+		_, other := st.present[pair.OtherID]
+		if dancer && other {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	var callers []*model.DancerCaller
+	for _, caller := range st.callers {
+		if _, ok := st.present[caller.DancerID]; ok {
+			callers = append(callers, caller)
+		}
+	}
+
+	return solver.Constraints{
+		MinDances: st.minDances,
+		MaxDances: st.maxDances,
+		Pairs:     solver.PairsFromModel(pairs),
+		Callers:   solver.CallersFromModel(callers),
+	}
+}
+
+// refresh works out what can be danced by whoever is here, and what's wrong
+// with the set, after anything changes.
+func (st *tuiState) refresh() {
+	dps, constraints := st.presentDPs(), st.constraints()
+
+	st.danceable = solver.Danceable(dps, constraints)
+
+	st.violations = nil
+	if st.set == nil {
+		return
+	}
+
+	st.violations = solver.Validate(*st.set, dps, constraints)
+}
+
+// solved takes the set the solver found.
+func (st *tuiState) solved(result solver.SolveResult, err error) {
+	switch {
+	case err != nil:
+		st.message = err.Error()
+		return
+	case result.Set.NumDancesDanced() == 0 && result.Status == solver.SolverStatusCancelled:
+		st.message = "Stopped before finding a set"
+		return
+	case result.Set.NumDancesDanced() == 0:
+		st.message = "Can't dance any dances"
+		return
+	}
+
+	st.set = &result.Set
+	st.status = result.Status
+	st.slotCursor = 0
+	st.pane = tuiPaneSet
+	st.refresh()
+
+	st.message = fmt.Sprintf("Found a set of %d dances (%s)", result.Set.NumDancesDanced(), strings.ToLower(result.Status.String()))
+}
+
+// slots are the positions in the set, in the order they're shown.
+func (st *tuiState) slots() []tuiSlot {
+	if st.set == nil {
+		return nil
+	}
+
+	var slots []tuiSlot
+	for _, dance := range st.set.Dances() {
+		for _, position := range dance.Positions {
+			slots = append(slots, tuiSlot{dance, position})
+		}
+	}
+
+	return slots
+}
+
+// candidates are the dancers here who could dance `slot`, best first.
+func (st *tuiState) candidates(slot tuiSlot) []*model.DancerPosition {
+	var candidates []*model.DancerPosition
+	for _, dp := range st.presentDPs() {
+		if dp.DanceID == slot.dance.ID && dp.PositionID == slot.position.PositionID && dp.Preference != model.PreferenceNo {
+			candidates = append(candidates, dp)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Preference != candidates[j].Preference {
+			return candidates[i].Preference > candidates[j].Preference
+		}
+		return candidates[i].Dancer.Name < candidates[j].Dancer.Name
+	})
+
+	return candidates
+}
+
+// preference returns how much `dancer` likes `slot`.
+func (st *tuiState) preference(dancer *model.Dancer, slot tuiSlot) model.DancePreference {
+	for _, dp := range st.dps {
+		if dp.DancerID == dancer.ID && dp.DanceID == slot.dance.ID && dp.PositionID == slot.position.PositionID {
+			return dp.Preference
+		}
+	}
+
+	return model.PreferenceNo
+}
+
+// swap moves the next (or previous, if `step` is -1) dancer who'll dance it
+// into the position under the cursor.
+func (st *tuiState) swap(step int) {
+	slots := st.slots()
+	if len(slots) == 0 {
+		return
+	}
+	slot := slots[st.slotCursor]
+
+	candidates := st.candidates(slot)
+	if len(candidates) == 0 {
+		st.message = fmt.Sprintf("Nobody here will dance %s in %s", slot.position.Name, slot.dance.Name)
+		return
+	}
+
+	current := st.set.DancerFor(slot.dance, slot.position)
+	next := 0
+	for i, dp := range candidates {
+		if current != nil && dp.DancerID == current.ID {
+			next = (i + step + len(candidates)) % len(candidates)
+			break
+		}
+	}
+
+	dp := candidates[next]
+	set := st.set.WithDancer(slot.dance, slot.position, dp.Dancer)
+	st.set = &set
+	st.refresh()
+
+	st.message = fmt.Sprintf("%s is dancing %s in %s (%s)", dp.Dancer.Name, slot.position.Name, slot.dance.Name, dp.Preference)
+}
+
+// handleKey does whatever `key` does, and says if the main loop has anything
+// to do.
+func (st *tuiState) handleKey(key string) tuiAction {
+	if st.prompt != nil {
+		switch key {
+		case "enter":
+			st.message = st.prompt.done(strings.TrimSpace(st.prompt.input))
+			st.prompt = nil
+		case "esc", "ctrl-c":
+			st.prompt = nil
+			st.message = ""
+		case "backspace":
+			if _, size := utf8.DecodeLastRuneInString(st.prompt.input); size > 0 {
+				st.prompt.input = st.prompt.input[:len(st.prompt.input)-size]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				st.prompt.input += key
+			}
+		}
+		return tuiNothing
+	}
+
+	st.message = ""
+
+	switch key {
+	case "q", "ctrl-c":
+		return tuiQuit
+	case "s":
+		if len(st.present) == 0 {
+			st.message = "Nobody is here"
+			return tuiNothing
+		}
+		return tuiSolve
+	case "tab":
+		if st.pane == tuiPaneDancers && st.set != nil {
+			st.pane = tuiPaneSet
+		} else {
+			st.pane = tuiPaneDancers
+		}
+	case "w":
+		if st.set == nil {
+			st.message = "There's no set to save yet"
+			return tuiNothing
+		}
+		st.prompt = &tuiPrompt{label: "Save to: ", done: st.save}
+	case "x":
+		if st.set == nil {
+			st.message = "There's no set to export yet"
+			return tuiNothing
+		}
+		st.prompt = &tuiPrompt{
+			label: fmt.Sprintf("Export as FORMAT FILE (%s or html): ", strings.Join(outputFormats[1:], ", ")),
+			done:  st.export,
+		}
+	}
+
+	if st.pane == tuiPaneDancers {
+		switch key {
+		case "up", "k":
+			st.dancerCursor = max(st.dancerCursor-1, 0)
+		case "down", "j":
+			st.dancerCursor = min(st.dancerCursor+1, len(st.dancers)-1)
+		case " ", "enter":
+			if len(st.dancers) == 0 {
+				break
+			}
+			dancer := st.dancers[st.dancerCursor]
+			if _, ok := st.present[dancer.ID]; ok {
+				delete(st.present, dancer.ID)
+			} else {
+				st.present[dancer.ID] = struct{}{}
+			}
+			st.refresh()
+		case "a":
+			if len(st.present) == len(st.dancers) {
+				st.present = make(map[int]struct{})
+			} else {
+				for _, dancer := range st.dancers {
+					st.present[dancer.ID] = struct{}{}
+				}
+			}
+			st.refresh()
+		}
+
+		return tuiNothing
+	}
+
+	switch key {
+	case "up", "k":
+		st.slotCursor = max(st.slotCursor-1, 0)
+	case "down", "j":
+		st.slotCursor = min(st.slotCursor+1, len(st.slots())-1)
+	case "right", "l", " ", "enter":
+		st.swap(1)
+	case "left", "h":
+		st.swap(-1)
+	}
+
+	return tuiNothing
+}
+
+// save writes the set to `path`, in the same way as dance-set --save.
+func (st *tuiState) save(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	if err := saveSet(path, *st.set); err != nil {
+		return err.Error()
+	}
+
+	return "Saved to " + path
+}
+
+// export writes the set out as `FORMAT FILE`, in one of dance-set's --output
+// formats or as a set sheet.
+func (st *tuiState) export(input string) string {
+	format, path, ok := strings.Cut(input, " ")
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return "Expected FORMAT FILE"
+	}
+
+	if format == "html" {
+		if err := saveHTML(path, newHTMLSet(st.dances, st.presentDancers(), nil, *st.set, true)); err != nil {
+			return err.Error()
+		}
+		return "Exported to " + path
+	}
+
+	if format == outputText || !slices.Contains(outputFormats, format) {
+		return fmt.Sprintf("Can't export as %q", format)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err.Error()
+	}
+
+	saved := st.set.Saved().WithPreferences(st.presentDPs())
+	saved.Status = strings.ToLower(st.status.String())
+	if err := writeSet(f, format, saved); err != nil {
+		f.Close()
+		return err.Error()
+	}
+	if err := f.Close(); err != nil {
+		return err.Error()
+	}
+
+	return "Exported to " + path
+}
+
+// cell fits `s` into exactly `width` columns.
+func cell(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+
+	if n := utf8.RuneCountInString(s); n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+
+	runes := []rune(s)
+
+	return string(runes[:width-1]) + "…"
+}
+
+// scroll returns the first of `n` lines to show in `height` rows, so that
+// line `cursor` is on the screen.
+func scroll(cursor, n, height int) int {
+	if height <= 0 || n <= height {
+		return 0
+	}
+
+	return min(max(cursor-height+1, 0), n-height)
+}
+
+// tuiLine is a line in one of the columns.
+type tuiLine struct {
+	text        string
+	highlighted bool
+}
+
+// render draws the whole screen: who's here, what they could dance and the
+// set side by side, with any problems with the set underneath.
+func (st *tuiState) render(w io.Writer, width, height int) {
+	const reverse, normal = "\x1b[7m", "\x1b[0m"
+
+	bodyHeight := max(height-6, 1)
+
+	dancers := make([]tuiLine, 0, len(st.dancers))
+	for i, dancer := range st.dancers {
+		mark := "[ ]"
+		if _, ok := st.present[dancer.ID]; ok {
+			mark = "[x]"
+		}
+		dancers = append(dancers, tuiLine{mark + " " + dancer.Name, st.pane == tuiPaneDancers && i == st.dancerCursor})
+	}
+
+	danceable := make([]tuiLine, 0, len(st.danceable))
+	for _, dance := range st.danceable {
+		danceable = append(danceable, tuiLine{text: dance.Name})
+	}
+
+	problems := make(map[tuiSlot]struct{})
+	for _, violation := range st.violations {
+		if violation.Dance != nil && violation.Position != nil {
+			problems[tuiSlot{violation.Dance, violation.Position}] = struct{}{}
+		}
+	}
+
+	var set []tuiLine
+	cursorLine := 0
+	slot := 0
+	if st.set != nil {
+		for _, dance := range st.set.Dances() {
+			set = append(set, tuiLine{text: dance.Name})
+			for _, position := range dance.Positions {
+				s := tuiSlot{dance, position}
+				mark := " "
+				if _, ok := problems[s]; ok {
+					mark = "!"
+				}
+
+				text := fmt.Sprintf("%s %s: nobody", mark, position.Name)
+				if dancer := st.set.DancerFor(dance, position); dancer != nil {
+					text = fmt.Sprintf("%s %s: %s (%s)", mark, position.Name, dancer.Name, st.preference(dancer, s))
+				}
+
+				if slot == st.slotCursor {
+					cursorLine = len(set)
+				}
+				set = append(set, tuiLine{text, st.pane == tuiPaneSet && slot == st.slotCursor})
+				slot++
+			}
+			if caller := st.set.CallerFor(dance); caller != nil {
+				set = append(set, tuiLine{text: "  Caller: " + caller.Name})
+			}
+		}
+	}
+
+	columns := []struct {
+		title  string
+		lines  []tuiLine
+		cursor int
+		width  int
+	}{
+		{"Dancers", dancers, st.dancerCursor, width / 4},
+		{"Danceable", danceable, 0, width / 4},
+		{"Set", set, cursorLine, width - 2*(width/4)},
+	}
+
+	fmt.Fprint(w, "\x1b[H")
+
+	header := fmt.Sprintf("who-dances-what: %d of %d here, %d dances could be danced", len(st.present), len(st.dancers), len(st.danceable))
+	fmt.Fprint(w, cell(header, width)+"\r\n")
+
+	titles := make([]string, 0, len(columns))
+	for _, column := range columns {
+		titles = append(titles, cell(column.title, column.width))
+	}
+	fmt.Fprint(w, strings.Join(titles, "")+"\r\n")
+
+	offsets := make([]int, len(columns))
+	for i, column := range columns {
+		offsets[i] = scroll(column.cursor, len(column.lines), bodyHeight)
+	}
+
+	for row := 0; row < bodyHeight; row++ {
+		for i, column := range columns {
+			line := tuiLine{}
+			if n := offsets[i] + row; n < len(column.lines) {
+				line = column.lines[n]
+			}
+
+			text := cell(line.text, column.width-1) + " "
+			if line.highlighted {
+				text = reverse + cell(line.text, column.width-1) + normal + " "
+			}
+			fmt.Fprint(w, text)
+		}
+		fmt.Fprint(w, "\r\n")
+	}
+
+	// up to two problems, then how many more there are
+	for i := 0; i < 2; i++ {
+		line := ""
+		switch {
+		case i < len(st.violations) && (i == 0 || len(st.violations) <= 2):
+			line = "! " + st.violations[i].String()
+		case i == 1 && len(st.violations) > 2:
+			line = fmt.Sprintf("! and %d more problem(s)", len(st.violations)-1)
+		}
+		fmt.Fprint(w, cell(line, width)+"\r\n")
+	}
+
+	if st.prompt != nil {
+		fmt.Fprint(w, cell(st.prompt.label+st.prompt.input+"_", width)+"\r\n")
+	} else {
+		fmt.Fprint(w, cell(st.message, width)+"\r\n")
+	}
+
+	help := "space: here/not here  a: everybody  s: solve  tab: switch  ←/→: swap dancer  w: save  x: export  q: quit"
+	fmt.Fprint(w, cell(help, width)+"\x1b[J")
+}
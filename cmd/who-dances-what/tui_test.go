@@ -0,0 +1,366 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/iainlane/who-dances-what/internal/solver"
+)
+
+func TestParseKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"arrows", "\x1b[A\x1b[B\x1b[C\x1b[D", []string{"up", "down", "right", "left"}},
+		{"esc on its own", "\x1b", []string{"esc"}},
+		{"esc then a key", "\x1bq", []string{"esc", "q"}},
+		{"unknown escape", "\x1b[Zx", []string{"x"}},
+		{"special keys", "\t\r\n\x7f\x08\x03", []string{"tab", "enter", "enter", "backspace", "backspace", "ctrl-c"}},
+		{"characters", "a é", []string{"a", " ", "é"}},
+		{"nothing", "", nil},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, parseKeys([]byte(tt.in)))
+		})
+	}
+}
+
+func TestReadKeys(t *testing.T) {
+	t.Parallel()
+
+	t.Run("until the end", func(t *testing.T) {
+		keys := make(chan string)
+		go readKeys(strings.NewReader("ab\x1b[A"), keys, make(chan struct{}))
+
+		var got []string
+		for key := range keys {
+			got = append(got, key)
+		}
+		require.Equal(t, []string{"a", "b", "up"}, got)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		// nobody is reading the keys, so it would block forever without
+		// `done`
+		keys := make(chan string)
+		done := make(chan struct{})
+		finished := make(chan struct{})
+		go func() {
+			readKeys(strings.NewReader("abc"), keys, done)
+			close(finished)
+		}()
+
+		close(done)
+		<-finished
+
+		_, ok := <-keys
+		require.False(t, ok)
+	})
+}
+
+func TestCell(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"abc", 5, "abc  "},
+		{"abc", 3, "abc"},
+		{"abcdef", 4, "abc…"},
+		{"héllo wörld", 6, "héllo…"},
+		{"abc", 1, "…"},
+		{"abc", 0, ""},
+		{"abc", -1, ""},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, cell(tt.s, tt.width), "cell(%q, %d)", tt.s, tt.width)
+	}
+}
+
+func TestScroll(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		cursor, n, height int
+		want              int
+	}{
+		{"everything fits", 9, 10, 10, 0},
+		{"cursor on the first screen", 3, 20, 5, 0},
+		{"cursor on the last line of the screen", 4, 20, 5, 0},
+		{"cursor past the screen", 9, 20, 5, 5},
+		{"cursor at the end", 19, 20, 5, 15},
+		{"no room", 3, 20, 0, 0},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, scroll(tt.cursor, tt.n, tt.height), tt.name)
+	}
+}
+
+// newTestTUI has Alice, Bob and Carol, with Alice and Bob here, and a set
+// with Alice dancing both positions of "Bean Setting".
+func newTestTUI() *tuiState {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+
+	dance := &model.Dance{ID: 1, Name: "Bean Setting", Active: true}
+	for p := 1; p <= 2; p++ {
+		dance.Positions = append(dance.Positions, &model.Position{PositionID: p, Name: string(rune('0' + p)), DanceID: dance.ID, Dance: dance})
+	}
+
+	dp := func(dancer *model.Dancer, position int, preference model.DancePreference) *model.DancerPosition {
+		return &model.DancerPosition{
+			DancerID:   dancer.ID,
+			DanceID:    dance.ID,
+			PositionID: position,
+			Dancer:     dancer,
+			Dance:      dance,
+			Position:   dance.Positions[position-1],
+			Preference: preference,
+		}
+	}
+
+	set := model.NewAssignmentSet(
+		model.Assignments{dance: {dance.Positions[0]: alice, dance.Positions[1]: alice}},
+		model.DancesDanced{dance: {}},
+	)
+
+	st := &tuiState{
+		dances:  []*model.Dance{dance},
+		dancers: []*model.Dancer{alice, bob, carol},
+		dps: []*model.DancerPosition{
+			dp(alice, 1, model.PreferenceYes),
+			dp(alice, 2, model.PreferenceYes),
+			dp(bob, 1, model.PreferenceFavourite),
+			dp(bob, 2, model.PreferenceNo),
+			dp(carol, 1, model.PreferenceYes),
+			dp(carol, 2, model.PreferenceYes),
+		},
+		present: map[int]struct{}{alice.ID: {}, bob.ID: {}},
+		set:     &set,
+		status:  solver.SolverStatusOptimal,
+	}
+	st.refresh()
+
+	return st
+}
+
+func TestHandleKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		noSet  bool
+		keys   []string
+		action tuiAction
+		check  func(t *testing.T, st *tuiState)
+	}{
+		{
+			name:   "quit",
+			keys:   []string{"q"},
+			action: tuiQuit,
+		},
+		{
+			name:   "solve",
+			keys:   []string{"s"},
+			action: tuiSolve,
+		},
+		{
+			name: "solve with nobody here",
+			keys: []string{"a", "a", "s"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Empty(t, st.present)
+				require.Equal(t, "Nobody is here", st.message)
+			},
+		},
+		{
+			name: "everybody here",
+			keys: []string{"a"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Len(t, st.present, 3)
+			},
+		},
+		{
+			name: "tick somebody off",
+			keys: []string{"down", "down", "down", " "},
+			check: func(t *testing.T, st *tuiState) {
+				// the cursor stops at Carol
+				require.Equal(t, 2, st.dancerCursor)
+				require.Contains(t, st.present, 3)
+			},
+		},
+		{
+			name: "untick somebody",
+			keys: []string{"enter"},
+			check: func(t *testing.T, st *tuiState) {
+				require.NotContains(t, st.present, 1)
+				// so Alice isn't here, but is in the set
+				require.Equal(t, solver.ViolationInactive, st.violations[0].Kind)
+			},
+		},
+		{
+			name: "switch panes",
+			keys: []string{"tab", "down", "tab", "tab"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Equal(t, tuiPaneSet, st.pane)
+				require.Equal(t, 1, st.slotCursor)
+				require.Equal(t, 0, st.dancerCursor)
+			},
+		},
+		{
+			name:  "switch panes without a set",
+			noSet: true,
+			keys:  []string{"tab"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Equal(t, tuiPaneDancers, st.pane)
+			},
+		},
+		{
+			name: "swap in the set",
+			keys: []string{"tab", "right"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Equal(t, "Bob", st.set.DancerFor(st.dances[0], st.dances[0].Positions[0]).Name)
+			},
+		},
+		{
+			name:  "save without a set",
+			noSet: true,
+			keys:  []string{"w"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Nil(t, st.prompt)
+				require.Equal(t, "There's no set to save yet", st.message)
+			},
+		},
+		{
+			name: "typing into a prompt",
+			keys: []string{"x", "c", "s", "v", "backspace", "q"},
+			check: func(t *testing.T, st *tuiState) {
+				require.NotNil(t, st.prompt)
+				require.Equal(t, "csq", st.prompt.input)
+			},
+		},
+		{
+			name: "cancelling a prompt",
+			keys: []string{"w", "a", "esc"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Nil(t, st.prompt)
+				require.Len(t, st.present, 2)
+			},
+		},
+		{
+			name: "bad export",
+			keys: []string{"x", "t", "x", "t", "enter"},
+			check: func(t *testing.T, st *tuiState) {
+				require.Nil(t, st.prompt)
+				require.Equal(t, "Expected FORMAT FILE", st.message)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			st := newTestTUI()
+			if tt.noSet {
+				st.set = nil
+			}
+
+			action := tuiNothing
+			for _, key := range tt.keys {
+				action = st.handleKey(key)
+			}
+
+			require.Equal(t, tt.action, action)
+			if tt.check != nil {
+				tt.check(t, st)
+			}
+		})
+	}
+}
+
+func TestSwap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		slot    int
+		steps   []int
+		want    string
+		message string
+	}{
+		// Bob's favourite comes first, then Alice; Carol isn't here
+		{"next", 0, []int{1}, "Bob", "Bob is dancing 1 in Bean Setting (favourite)"},
+		{"next wraps around", 0, []int{1, 1}, "Alice", "Alice is dancing 1 in Bean Setting (yes)"},
+		{"previous", 0, []int{-1}, "Bob", "Bob is dancing 1 in Bean Setting (favourite)"},
+		{"there and back", 0, []int{1, -1}, "Alice", "Alice is dancing 1 in Bean Setting (yes)"},
+		// Bob has said no to 2
+		{"only one choice", 1, []int{1}, "Alice", "Alice is dancing 2 in Bean Setting (yes)"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			st := newTestTUI()
+			st.slotCursor = tt.slot
+			for _, step := range tt.steps {
+				st.swap(step)
+			}
+
+			slot := st.slots()[tt.slot]
+			require.Equal(t, tt.want, st.set.DancerFor(slot.dance, slot.position).Name)
+			require.Equal(t, tt.message, st.message)
+		})
+	}
+
+	t.Run("nobody can dance it", func(t *testing.T) {
+		t.Parallel()
+
+		st := newTestTUI()
+		st.present = map[int]struct{}{2: {}}
+		st.slotCursor = 1
+		st.swap(1)
+
+		require.Equal(t, "Nobody here will dance 2 in Bean Setting", st.message)
+		require.Equal(t, "Alice", st.set.DancerFor(st.dances[0], st.dances[0].Positions[1]).Name)
+	})
+
+	t.Run("fixes the set", func(t *testing.T) {
+		t.Parallel()
+
+		// Alice is dancing both positions to start with
+		st := newTestTUI()
+		require.Equal(t, []solver.ViolationKind{solver.ViolationDancerTwice}, violationKinds(st.violations))
+
+		st.swap(1)
+		require.Empty(t, st.violations)
+	})
+}
+
+func violationKinds(violations []solver.Violation) []solver.ViolationKind {
+	var kinds []solver.ViolationKind
+	for _, violation := range violations {
+		kinds = append(kinds, violation.Kind)
+	}
+
+	return kinds
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/iainlane/who-dances-what/internal/solver"
+)
+
+func whatIf(logger *logrus.Entry) *cli.Command {
+	return &cli.Command{
+		Name:      "what-if",
+		Usage:     "Rank the active dancers who aren't coming by how much difference they'd make if they came",
+		ArgsUsage: "DANCER...",
+		Flags: append(append(setLengthFlags(), solverFlags()...),
+			&cli.StringFlag{
+				Name:  "event",
+				Usage: "Start from the dancers attending this event, as well as any given as arguments",
+			},
+			&cli.BoolFlag{
+				Name:  "solve",
+				Usage: "Solve the set with each dancer, rather than estimating which dances they'd unlock. This is slower, but also says how much better the set would be",
+			},
+		),
+		Action: func(c *cli.Context) error { return doWhatIf(c, logger) },
+	}
+}
+
+func doWhatIf(c *cli.Context, logger *logrus.Entry) error {
+	dancerNames := c.Args().Slice()
+	eventName := c.String("event")
+	if len(dancerNames) == 0 && eventName == "" {
+		return cli.Exit("No dancers specified", 1)
+	}
+
+	minDances, maxDances, err := parseSetLength(c)
+	if err != nil {
+		return err
+	}
+
+	m, err := model.NewModel(c.String("db"), logger)
+	if err != nil {
+		return err
+	}
+
+	dancers, _, err := fetchDancers(m, dancerNames, eventName)
+	if err != nil {
+		return err
+	}
+
+	everyone, err := m.FetchDancers()
+	if err != nil {
+		return err
+	}
+
+	here := make(map[int]struct{}, len(dancers))
+	for _, dancer := range dancers {
+		here[dancer.ID] = struct{}{}
+	}
+
+	// everybody's fetched together, so the dances are the same for the
+	// dancers who are coming and those who might
+	all := append([]*model.Dancer(nil), dancers...)
+	for _, dancer := range everyone {
+		if _, ok := here[dancer.ID]; !ok && dancer.Active {
+			all = append(all, dancer)
+		}
+	}
+
+	dances, positions, err := m.FetchDancerPositionsForDancers(all)
+	if err != nil {
+		return err
+	}
+
+	var attending, candidates []*model.DancerPosition
+	for _, dp := range positions {
+		if _, ok := here[dp.DancerID]; ok {
+			attending = append(attending, dp)
+		} else {
+			candidates = append(candidates, dp)
+		}
+	}
+
+	pairs, err := m.FetchDancerPairs(all)
+	if err != nil {
+		return err
+	}
+
+	callers, err := m.FetchCallers(all, dances)
+	if err != nil {
+		return err
+	}
+
+	constraints := solver.Constraints{
+		MinDances: minDances,
+		MaxDances: maxDances,
+		Pairs:     solver.PairsFromModel(pairs),
+		Callers:   solver.CallersFromModel(callers),
+	}
+
+	solve := c.Bool("solve")
+
+	var unlocks []solver.Unlock
+	if solve {
+		s, err := newSolverFromFlags(c, solver.Options{})
+		if err != nil {
+			return err
+		}
+
+		unlocks, err = solver.SolveWhatIf(c.Context, logger, s, attending, candidates, constraints)
+		if err != nil {
+			return err
+		}
+	} else {
+		unlocks = solver.WhatIf(attending, candidates, constraints)
+	}
+
+	if len(unlocks) == 0 {
+		fmt.Println("Everybody is already coming")
+		return nil
+	}
+
+	for _, unlock := range unlocks {
+		fmt.Println(formatUnlock(unlock, solve))
+	}
+
+	return nil
+}
+
+// formatUnlock says what a dancer would add, including what the solver found
+// if `solved`.
+func formatUnlock(unlock solver.Unlock, solved bool) string {
+	var sb strings.Builder
+
+	sb.WriteString(unlock.Dancer.Name)
+	sb.WriteString(": ")
+
+	if solved {
+		fmt.Fprintf(&sb, "%+d dance(s), score %+d, ", unlock.ExtraDances, unlock.Gain)
+	}
+
+	if len(unlock.Dances) == 0 {
+		sb.WriteString("unlocks nothing")
+		return sb.String()
+	}
+
+	names := make([]string, 0, len(unlock.Dances))
+	for _, dance := range unlock.Dances {
+		names = append(names, dance.Name)
+	}
+	fmt.Fprintf(&sb, "unlocks %s", strings.Join(names, ", "))
+
+	return sb.String()
+}
@@ -1,8 +1,15 @@
+#include <cstring>
+#include <fstream>
+#include <numeric>
 #include <ranges>
+#include <sstream>
+
+#include "google/protobuf/text_format.h"
 
 #include "ortools/sat/cp_model.h"
 #include "ortools/sat/cp_model.pb.h"
 #include "ortools/sat/cp_model_solver.h"
+#include "ortools/util/time_limit.h"
 #include "ortools/util/sorted_interval_list.h"
 
 #include "dance_solver.hpp"
@@ -22,10 +29,46 @@ public:
         const std::vector<Dancer> &dancers,
         const std::vector<Dance> &dances,
         const std::vector<DancerPosition> &dancer_positions);
+    // for when the model is shared with other events in a season
+    DanceSolverImpl(
+        logger *logger,
+        const std::vector<Dancer> &dancers,
+        const std::vector<Dance> &dances,
+        const std::vector<DancerPosition> &dancer_positions,
+        CpModelBuilder &cp_model);
+    void SetNumDances(int min_dances, int max_dances);
+    void IncludeDance(int dance_id);
+    void ExcludeDance(int dance_id);
+    void PinDancer(int dancer_id, int dance_id, int position_id);
+    void SetDancerLimits(int dancer_id, DancerLimit limit);
+    void SetDancerWeight(int dancer_id, int weight);
+    void AddPair(int dancer_id, int other_id, PairRule rule);
+    void SetFacingPositions(int dance_id, int position_id, int other_position_id);
+    void SetDanceCaller(int dance_id, CallerRule rule);
+    void AddCaller(int dancer_id, int dance_id);
+    void SetDanceVariant(int dance_id, int full_dance_id);
+    void SetPreviousAssignment(int dancer_id, int dance_id, int position_id);
+    void SetDump(const DumpOptions &dump);
+    void SetSolutionObserver(SolutionObserver observer);
+    void SetNumWorkers(int num_workers);
+    void Stop();
     const DanceSolution GetPossibleDances();
+    const std::vector<DanceSolution> GetAlternativeDances(
+        int num_alternatives,
+        int min_difference,
+        AlternativeDifference difference);
 
 private:
+    friend class SeasonSolver;
+
+    const LinearExpr BuildModel();
     void CreateVariablesAndConstraints();
+    const CpSolverResponse SolveModel();
+    void ExcludeSimilarSolutions(
+        const CpSolverResponse &response,
+        int min_difference,
+        AlternativeDifference difference,
+        int solution_number);
     void ProcessDancerPositions(
         const std::vector<DancerPosition> &dancer_positions);
     void ProcessDance(
@@ -48,18 +91,63 @@ private:
         const DancerPreferenceMap &dancer_preference_map,
         const BoolVar &dance_is_danced,
         const BoolVar &dancer_is_assigned);
+    void ApplyDancerLimits(int dancer_id, const IntVar &dance_count_for_dancer);
+    void ApplyPairs();
+    void ApplyCallers();
+    void ApplyVariants();
     const LinearExpr CreateObjective();
-    const DanceSolution GetSolution(const CpSolverResponse &response);
+    const DanceSolution GetSolution(const CpSolverResponse &response, bool stopped = false);
 
     logger *logger_;
 
-    CpModelBuilder cp_model_;
+    // used unless the model is shared with other events
+    CpModelBuilder own_model_;
+    CpModelBuilder &cp_model_;
+    bool model_built_ = false;
 
     std::vector<Dancer> dancers_;
     std::vector<Dance> dances_;
 
     const std::vector<DancerPosition> dancer_positions_;
 
+    // bounds on the length of the set, 0 means unbounded
+    int min_set_length_ = 0;
+    int max_set_length_ = 0;
+
+    // dances which must or mustn't be danced
+    std::set<int> included_dances_;
+    std::set<int> excluded_dances_;
+    // dance id -> position id -> dancer id
+    std::map<int, std::map<int, int>> pinned_dancers_;
+    // dancer id -> limit
+    std::map<int, DancerLimit> dancer_limits_;
+    // dancer id -> weight, in DANCER_WEIGHT_UNITS. missing means an even share
+    std::map<int, int> dancer_weights_;
+
+    struct PairConstraint
+    {
+        int dancer_id;
+        int other_id;
+        PairRule rule;
+    };
+    std::vector<PairConstraint> pairs_;
+    // dance id -> pairs of facing position ids
+    std::map<int, std::vector<std::pair<int, int>>> facing_positions_;
+    // dance id -> who calls it, and the dancers who can
+    std::map<int, CallerRule> caller_rules_;
+    std::map<int, std::set<int>> callers_;
+    // variant dance id -> the dance it's a version of
+    std::map<int, int> variants_;
+    // the set being repaired: dance id -> position id -> dancer id
+    std::map<int, std::map<int, int>> previous_assignments_;
+
+    DumpOptions dump_;
+    SolutionObserver solution_observer_;
+    // 0 lets CP-SAT choose
+    int num_workers_ = 0;
+    // set from another thread to stop solving
+    std::atomic<bool> stop_ = false;
+
     DancePositionDancerPreferenceMap dancer_position_preference_map_;
     std::map<int, std::set<int>> dance_dancers_;
 
@@ -67,11 +155,31 @@ private:
     std::map<int, std::map<int, IntVar>> dancer_position_map_;
     std::map<int, std::vector<BoolVar>> dances_by_dancer_;
     std::vector<IntVar> dancer_counts_;
+    // dancer id -> number of dances they're doing
+    std::map<int, IntVar> dancer_count_vars_;
     std::map<int, BoolVar> dance_is_danced_vars_;
+    // every dancer/dance/position combination
+    std::vector<BoolVar> dancer_is_assigned_vars_;
+    // dance id -> position id -> dancer id -> whether they're dancing it
+    std::map<int, std::map<int, std::map<int, BoolVar>>> dancer_is_assigned_map_;
+    // dance id -> dancer id -> whether they're calling it
+    std::map<int, std::map<int, BoolVar>> dance_caller_vars_;
 
     std::vector<BoolVar> maybes_;
     std::vector<BoolVar> yeses_;
     std::vector<BoolVar> favourites_;
+    // dancer id -> favourite positions they're dancing
+    std::map<int, std::vector<BoolVar>> favourites_by_dancer_;
+
+    // how far soft dancer limits are missed by
+    std::vector<IntVar> dancer_limit_misses_;
+
+    // how many dancers short of the largest version the versions danced are
+    LinearExpr variant_shortfall_;
+
+    // assignments which are the same as / different from the previous set
+    std::vector<BoolVar> kept_assignments_;
+    std::vector<BoolVar> new_assignments_;
 
     IntVar min_dances_;
     IntVar max_dances_;
@@ -97,6 +205,86 @@ DanceSolver::DanceSolver(
 
 DanceSolver::~DanceSolver() = default;
 
+__attribute__((visibility("default"))) void DanceSolver::SetNumDances(int min_dances, int max_dances)
+{
+    pimpl_->SetNumDances(min_dances, max_dances);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::IncludeDance(DanceID dance_id)
+{
+    pimpl_->IncludeDance(dance_id);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::ExcludeDance(DanceID dance_id)
+{
+    pimpl_->ExcludeDance(dance_id);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::PinDancer(DancerID dancer_id, DanceID dance_id, PositionID position_id)
+{
+    pimpl_->PinDancer(dancer_id, dance_id, position_id);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetDancerLimits(DancerID dancer_id, DancerLimit limit)
+{
+    pimpl_->SetDancerLimits(dancer_id, limit);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetDancerWeight(DancerID dancer_id, int weight)
+{
+    pimpl_->SetDancerWeight(dancer_id, weight);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::AddPair(DancerID dancer_id, DancerID other_id, PairRule rule)
+{
+    pimpl_->AddPair(dancer_id, other_id, rule);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetFacingPositions(DanceID dance_id, PositionID position_id, PositionID other_position_id)
+{
+    pimpl_->SetFacingPositions(dance_id, position_id, other_position_id);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetDanceCaller(DanceID dance_id, CallerRule rule)
+{
+    pimpl_->SetDanceCaller(dance_id, rule);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::AddCaller(DancerID dancer_id, DanceID dance_id)
+{
+    pimpl_->AddCaller(dancer_id, dance_id);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetDanceVariant(DanceID dance_id, DanceID full_dance_id)
+{
+    pimpl_->SetDanceVariant(dance_id, full_dance_id);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetPreviousAssignment(DancerID dancer_id, DanceID dance_id, PositionID position_id)
+{
+    pimpl_->SetPreviousAssignment(dancer_id, dance_id, position_id);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetDump(const DumpOptions &dump)
+{
+    pimpl_->SetDump(dump);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetSolutionObserver(SolutionObserver observer)
+{
+    pimpl_->SetSolutionObserver(observer);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::SetNumWorkers(int num_workers)
+{
+    pimpl_->SetNumWorkers(num_workers);
+}
+
+__attribute__((visibility("default"))) void DanceSolver::Stop()
+{
+    pimpl_->Stop();
+}
+
 __attribute__((visibility("default")))
 const DanceSolver::DanceSolution
 DanceSolver::GetPossibleDances()
@@ -104,18 +292,148 @@ DanceSolver::GetPossibleDances()
     return pimpl_->GetPossibleDances();
 }
 
+__attribute__((visibility("default")))
+const std::vector<DanceSolver::DanceSolution>
+DanceSolver::GetAlternativeDances(
+    int num_alternatives,
+    int min_difference,
+    AlternativeDifference difference)
+{
+    return pimpl_->GetAlternativeDances(num_alternatives, min_difference, difference);
+}
+
 DanceSolver::DanceSolverImpl::DanceSolverImpl(
     logger *logger,
     const std::vector<Dancer> &dancers,
     const std::vector<Dance> &dances,
     const std::vector<DancerPosition> &dancer_positions)
     : logger_(logger),
+      cp_model_(own_model_),
+      dancers_(dancers),
+      dances_(dances),
+      dancer_positions_(dancer_positions)
+{
+}
+
+DanceSolver::DanceSolverImpl::DanceSolverImpl(
+    logger *logger,
+    const std::vector<Dancer> &dancers,
+    const std::vector<Dance> &dances,
+    const std::vector<DancerPosition> &dancer_positions,
+    CpModelBuilder &cp_model)
+    : logger_(logger),
+      cp_model_(cp_model),
       dancers_(dancers),
       dances_(dances),
       dancer_positions_(dancer_positions)
 {
 }
 
+void DanceSolver::DanceSolverImpl::SetNumDances(int min_dances, int max_dances)
+{
+    Debug(logger_) << "set length: min " << min_dances << " max " << max_dances;
+
+    min_set_length_ = min_dances;
+    max_set_length_ = max_dances;
+}
+
+void DanceSolver::DanceSolverImpl::IncludeDance(int dance_id)
+{
+    Debug(logger_) << "including dance " << dance_id;
+
+    included_dances_.insert(dance_id);
+}
+
+void DanceSolver::DanceSolverImpl::ExcludeDance(int dance_id)
+{
+    Debug(logger_) << "excluding dance " << dance_id;
+
+    excluded_dances_.insert(dance_id);
+}
+
+void DanceSolver::DanceSolverImpl::PinDancer(int dancer_id, int dance_id, int position_id)
+{
+    Debug(logger_) << "pinning dancer " << dancer_id << " to dance " << dance_id << " position " << position_id;
+
+    pinned_dancers_[dance_id][position_id] = dancer_id;
+}
+
+void DanceSolver::DanceSolverImpl::SetDancerLimits(int dancer_id, DancerLimit limit)
+{
+    Debug(logger_) << "dancer " << dancer_id << " limits: min " << limit.MinDances << " max " << limit.MaxDances << (limit.Soft ? " (soft)" : "");
+
+    dancer_limits_[dancer_id] = limit;
+}
+
+void DanceSolver::DanceSolverImpl::SetDancerWeight(int dancer_id, int weight)
+{
+    Debug(logger_) << "dancer " << dancer_id << " weight: " << weight << "/" << DANCER_WEIGHT_UNITS;
+
+    dancer_weights_[dancer_id] = weight;
+}
+
+void DanceSolver::DanceSolverImpl::AddPair(int dancer_id, int other_id, PairRule rule)
+{
+    Debug(logger_) << "pair rule " << rule << " for dancers " << dancer_id << " and " << other_id;
+
+    pairs_.push_back({dancer_id, other_id, rule});
+}
+
+void DanceSolver::DanceSolverImpl::SetFacingPositions(int dance_id, int position_id, int other_position_id)
+{
+    Debug(logger_) << "dance " << dance_id << " positions " << position_id << " and " << other_position_id << " face each other";
+
+    facing_positions_[dance_id].push_back({position_id, other_position_id});
+}
+
+void DanceSolver::DanceSolverImpl::SetDanceCaller(int dance_id, CallerRule rule)
+{
+    Debug(logger_) << "dance " << dance_id << " caller rule: " << rule;
+
+    caller_rules_[dance_id] = rule;
+}
+
+void DanceSolver::DanceSolverImpl::AddCaller(int dancer_id, int dance_id)
+{
+    Debug(logger_) << "dancer " << dancer_id << " can call dance " << dance_id;
+
+    callers_[dance_id].insert(dancer_id);
+}
+
+void DanceSolver::DanceSolverImpl::SetDanceVariant(int dance_id, int full_dance_id)
+{
+    Debug(logger_) << "dance " << dance_id << " is a version of dance " << full_dance_id;
+
+    variants_[dance_id] = full_dance_id;
+}
+
+void DanceSolver::DanceSolverImpl::SetPreviousAssignment(int dancer_id, int dance_id, int position_id)
+{
+    Debug(logger_) << "previously dancer " << dancer_id << " danced dance " << dance_id << " position " << position_id;
+
+    previous_assignments_[dance_id][position_id] = dancer_id;
+}
+
+void DanceSolver::DanceSolverImpl::SetDump(const DumpOptions &dump)
+{
+    dump_ = dump;
+}
+
+void DanceSolver::DanceSolverImpl::SetSolutionObserver(SolutionObserver observer)
+{
+    solution_observer_ = observer;
+}
+
+void DanceSolver::DanceSolverImpl::SetNumWorkers(int num_workers)
+{
+    num_workers_ = num_workers;
+}
+
+void DanceSolver::DanceSolverImpl::Stop()
+{
+    stop_ = true;
+}
+
 void DanceSolver::DanceSolverImpl::ProcessDancerPositions(const std::vector<DancerPosition> &dancer_positions)
 {
     DancePositionDancerPreferenceMap dancer_position_preference_map;
@@ -203,6 +521,7 @@ void DanceSolver::DanceSolverImpl::HandleDancerPositionPreference(
             .OnlyEnforceIf(dance_is_danced)
             .WithName(name);
         favourites_.push_back(pref);
+        favourites_by_dancer_[dancer_id].push_back(pref);
 
         break;
     }
@@ -233,6 +552,48 @@ void DanceSolver::DanceSolverImpl::ProcessDancerForDancePosition(
     const auto dancer_is_assigned =
         cp_model_.NewBoolVar().WithName(dancing_position);
     dances_by_dancer_[dancer_id].push_back(dancer_is_assigned);
+    dancer_is_assigned_vars_.push_back(dancer_is_assigned);
+    dancer_is_assigned_map_[dance_id][position_id][dancer_id] = dancer_is_assigned;
+
+    // nobody can be assigned to a dance which isn't being danced. without this
+    // the dance counts below could include dances which aren't in the set.
+    cp_model_.AddImplication(dancer_is_assigned, dance_is_danced)
+        .WithName(dancing_position + "_only_if_danced");
+
+    const auto pinned_dance = pinned_dancers_.find(dance_id);
+    if (pinned_dance != pinned_dancers_.end())
+    {
+        const auto pinned_position = pinned_dance->second.find(position_id);
+        if (pinned_position != pinned_dance->second.end() && pinned_position->second == dancer_id)
+        {
+            cp_model_.AddEquality(dancer_is_assigned, true)
+                .WithName(dancer_id_str + "_pinned_to_" + dance_id_str + "_position_" + position_id_str);
+        }
+    }
+
+    if (!previous_assignments_.empty())
+    {
+        bool was_assigned = false;
+
+        const auto previous_dance = previous_assignments_.find(dance_id);
+        if (previous_dance != previous_assignments_.end())
+        {
+            const auto previous_position = previous_dance->second.find(position_id);
+            was_assigned = previous_position != previous_dance->second.end() && previous_position->second == dancer_id;
+        }
+
+        // start the search from the previous set, and make straying from it
+        // cost something
+        cp_model_.AddHint(dancer_is_assigned, was_assigned);
+        if (was_assigned)
+        {
+            kept_assignments_.push_back(dancer_is_assigned);
+        }
+        else
+        {
+            new_assignments_.push_back(dancer_is_assigned);
+        }
+    }
 
     HandleDancerPositionPreference(dance, position, dancer, dancer_preference_map, dance_is_danced, dancer_is_assigned);
 
@@ -335,6 +696,23 @@ void DanceSolver::DanceSolverImpl::ProcessDance(
             .WithName("is_dance_" + std::to_string(dance_id) + "_danced");
     dance_is_danced_vars_[dance_id] = dance_is_danced;
 
+    if (!previous_assignments_.empty())
+    {
+        cp_model_.AddHint(dance_is_danced, previous_assignments_.contains(dance_id));
+    }
+
+    // a dance with somebody pinned to it is implicitly included
+    if (included_dances_.contains(dance_id) || pinned_dancers_.contains(dance_id))
+    {
+        cp_model_.AddEquality(dance_is_danced, true)
+            .WithName("dance_" + std::to_string(dance_id) + "_included");
+    }
+    if (excluded_dances_.contains(dance_id))
+    {
+        cp_model_.AddEquality(dance_is_danced, false)
+            .WithName("dance_" + std::to_string(dance_id) + "_excluded");
+    }
+
     const auto position_dancer_preference_map = dancer_position_preference_map_[dance_id];
 
     for (const auto &position : dance.Positions)
@@ -358,6 +736,123 @@ void DanceSolver::DanceSolverImpl::ProcessDance(
         .WithName("all_positions_different_" + std::to_string(dance_id));
 }
 
+void DanceSolver::DanceSolverImpl::ApplyDancerLimits(int dancer_id, const IntVar &dance_count_for_dancer)
+{
+    const auto it = dancer_limits_.find(dancer_id);
+    if (it == dancer_limits_.end())
+    {
+        return;
+    }
+
+    const auto limit = it->second;
+    const auto dancer_id_str = std::to_string(dancer_id);
+
+    if (limit.MinDances > 0)
+    {
+        if (limit.Soft)
+        {
+            // however many dances short of the minimum the dancer is
+            const auto shortfall =
+                cp_model_.NewIntVar({0, limit.MinDances})
+                    .WithName("dancer_" + dancer_id_str + "_min_dances_shortfall");
+            cp_model_.AddGreaterOrEqual(dance_count_for_dancer + shortfall, limit.MinDances)
+                .WithName("dancer_" + dancer_id_str + "_soft_min_dances");
+            dancer_limit_misses_.push_back(shortfall);
+        }
+        else
+        {
+            cp_model_.AddGreaterOrEqual(dance_count_for_dancer, limit.MinDances)
+                .WithName("dancer_" + dancer_id_str + "_min_dances");
+        }
+    }
+
+    if (limit.MaxDances > 0)
+    {
+        if (limit.Soft)
+        {
+            // however many dances over the maximum the dancer is
+            const auto excess =
+                cp_model_.NewIntVar({0, (int64_t)dances_.size()})
+                    .WithName("dancer_" + dancer_id_str + "_max_dances_excess");
+            cp_model_.AddLessOrEqual(dance_count_for_dancer - excess, limit.MaxDances)
+                .WithName("dancer_" + dancer_id_str + "_soft_max_dances");
+            dancer_limit_misses_.push_back(excess);
+        }
+        else
+        {
+            cp_model_.AddLessOrEqual(dance_count_for_dancer, limit.MaxDances)
+                .WithName("dancer_" + dancer_id_str + "_max_dances");
+        }
+    }
+}
+
+// A dancer is in at most one position of each dance, so the sum of their
+// assignment variables says whether they're in it at all.
+void DanceSolver::DanceSolverImpl::ApplyPairs()
+{
+    for (const auto &pair : pairs_)
+    {
+        const auto pair_str = "pair_" + std::to_string(pair.dancer_id) + "_" + std::to_string(pair.other_id);
+
+        for (const auto &dance : dances_)
+        {
+            const auto &positions = dancer_is_assigned_map_[dance.ID];
+            const auto dance_str = "_dance_" + std::to_string(dance.ID);
+
+            LinearExpr dancer_in_dance;
+            LinearExpr other_in_dance;
+            for (const auto &[position_id, dancers] : positions)
+            {
+                if (const auto it = dancers.find(pair.dancer_id); it != dancers.end())
+                {
+                    dancer_in_dance += it->second;
+                }
+                if (const auto it = dancers.find(pair.other_id); it != dancers.end())
+                {
+                    other_in_dance += it->second;
+                }
+            }
+
+            switch (pair.rule)
+            {
+            case PairRuleTogether:
+                cp_model_.AddEquality(dancer_in_dance, other_in_dance)
+                    .WithName(pair_str + "_together" + dance_str);
+                break;
+            case PairRuleApart:
+                cp_model_.AddLessOrEqual(dancer_in_dance + other_in_dance, 1)
+                    .WithName(pair_str + "_apart" + dance_str);
+                break;
+            case PairRuleNotFacing:
+                for (const auto &[position_id, other_position_id] : facing_positions_[dance.ID])
+                {
+                    // either way round
+                    for (const auto &[a, b] : {std::pair{position_id, other_position_id}, std::pair{other_position_id, position_id}})
+                    {
+                        const auto a_dancers = positions.find(a);
+                        const auto b_dancers = positions.find(b);
+                        if (a_dancers == positions.end() || b_dancers == positions.end())
+                        {
+                            continue;
+                        }
+
+                        const auto dancer_var = a_dancers->second.find(pair.dancer_id);
+                        const auto other_var = b_dancers->second.find(pair.other_id);
+                        if (dancer_var == a_dancers->second.end() || other_var == b_dancers->second.end())
+                        {
+                            continue;
+                        }
+
+                        cp_model_.AddBoolOr({Not(dancer_var->second), Not(other_var->second)})
+                            .WithName(pair_str + "_not_facing" + dance_str + "_positions_" + std::to_string(a) + "_" + std::to_string(b));
+                    }
+                }
+                break;
+            }
+        }
+    }
+}
+
 const LinearExpr DanceSolver::DanceSolverImpl::CreateObjective()
 {
     // at least one dance must be performed
@@ -371,6 +866,30 @@ const LinearExpr DanceSolver::DanceSolverImpl::CreateObjective()
     // to maximise the fairness among dancers, we need to minimise the
     // difference between max and min dance count. This ensures that dancers get
     // an even number of dances.
+    //
+    // weighted dancers should get their share in proportion to their weight,
+    // so each count is divided by the weight before they're compared. to keep
+    // to whole numbers, the counts are multiplied up by `scale` instead, and so
+    // is the rest of the objective. without weights, `scale` is 1.
+    const auto dancer_weight = [this](int dancer_id)
+    {
+        const auto it = dancer_weights_.find(dancer_id);
+        return (int64_t)(it == dancer_weights_.end() ? DANCER_WEIGHT_UNITS : it->second);
+    };
+
+    int64_t scale = 1;
+    for (const auto &[dancer_id, dance_vars] : dances_by_dancer_)
+    {
+        const auto weight = dancer_weight(dancer_id);
+        scale = std::lcm(scale, weight / std::gcd(weight, (int64_t)DANCER_WEIGHT_UNITS));
+    }
+    Debug(logger_) << "fairness scale: " << scale;
+
+    // a dancer's count is worth this much per dance
+    const auto count_scale = [&](int dancer_id)
+    { return scale * DANCER_WEIGHT_UNITS / dancer_weight(dancer_id); };
+
+    int64_t max_count_scale = 1;
 
     // first we find out how many dances each dancer is doing
     for (const auto &dancer_dances : dances_by_dancer_)
@@ -385,12 +904,30 @@ const LinearExpr DanceSolver::DanceSolverImpl::CreateObjective()
         cp_model_.AddEquality(dance_count_for_dancer, LinearExpr::Sum(dance_vars_for_dancer))
             .WithName("dance_count_" + std::to_string(dancer_id));
 
-        dancer_counts_.push_back(dance_count_for_dancer);
+        dancer_count_vars_.emplace(dancer_id, dance_count_for_dancer);
+
+        ApplyDancerLimits(dancer_id, dance_count_for_dancer);
+
+        const auto dancer_count_scale = count_scale(dancer_id);
+        max_count_scale = std::max(max_count_scale, dancer_count_scale);
+        if (dancer_count_scale == 1)
+        {
+            dancer_counts_.push_back(dance_count_for_dancer);
+            continue;
+        }
+
+        const auto scaled_count =
+            cp_model_.NewIntVar({0, (int64_t)dances_.size() * dancer_count_scale})
+                .WithName("scaled_dance_count_" + std::to_string(dancer_id));
+        cp_model_.AddEquality(scaled_count, dance_count_for_dancer * dancer_count_scale)
+            .WithName("scaled_dance_count_" + std::to_string(dancer_id));
+        dancer_counts_.push_back(scaled_count);
     }
 
     // then we get the minimum and maximum of those counts
-    min_dances_ = cp_model_.NewIntVar(dance_domain).WithName("min_dances");
-    max_dances_ = cp_model_.NewIntVar(dance_domain).WithName("max_dances");
+    const Domain scaled_dance_domain = {0, (int64_t)dances_.size() * max_count_scale};
+    min_dances_ = cp_model_.NewIntVar(scaled_dance_domain).WithName("min_dances");
+    max_dances_ = cp_model_.NewIntVar(scaled_dance_domain).WithName("max_dances");
     cp_model_.AddMinEquality(min_dances_, dancer_counts_).WithName("min_dances");
     cp_model_.AddMaxEquality(max_dances_, dancer_counts_).WithName("max_dances");
 
@@ -398,7 +935,7 @@ const LinearExpr DanceSolver::DanceSolverImpl::CreateObjective()
     // to minimize however, the objective function is to maximise, so we negate
     // the difference (* -1). maximising this number is the same as reducing the
     // difference, i.e. equalising the spread as far as possible.
-    dance_diff_ = cp_model_.NewIntVar({(int64_t)(-1 * dances_.size() * (dancers_.size() - 1)), 0})
+    dance_diff_ = cp_model_.NewIntVar({-1 * (int64_t)dances_.size() * max_count_scale, 0})
                       .WithName("dance_diff");
     cp_model_.AddEquality(dance_diff_, (max_dances_ - min_dances_) * -1).WithName("dance_diff");
 
@@ -428,70 +965,238 @@ const LinearExpr DanceSolver::DanceSolverImpl::CreateObjective()
     cp_model_.AddEquality(number_of_dances_performed, LinearExpr::Sum(dance_is_danced_vars))
         .WithName("number_of_dances_performed");
 
+    // the set might need to fit into a fixed slot. once the upper bound is
+    // reached the number of dances can't grow any more, so it's the preference
+    // and fairness terms which decide which dances make the cut.
+    if (min_set_length_ > 0)
+    {
+        cp_model_.AddGreaterOrEqual(number_of_dances_performed, min_set_length_)
+            .WithName("min_set_length");
+    }
+    if (max_set_length_ > 0)
+    {
+        cp_model_.AddLessOrEqual(number_of_dances_performed, max_set_length_)
+            .WithName("max_set_length");
+    }
+
     // the objective function is a weighted sum of the above variables
-    const auto objective = LinearExpr::WeightedSum(
+    auto objective = LinearExpr::WeightedSum(
         {dance_diff_, number_of_dances_performed, favourite_count_, yes_count_, maybe_count_},
-        {FAIRNESS_WEIGHT, NUM_DANCES_PERFORMED_WEIGHT, PREFERENCE_FAVOURITE_WEIGHT, PREFERENCE_YES_WEIGHT, PREFERENCE_MAYBE_WEIGHT});
-
-    return objective;
-}
-
-void DanceSolver::DanceSolverImpl::CreateVariablesAndConstraints()
-{
-    ProcessDancerPositions(dancer_positions_);
-
-    for (const auto &dance : dances_)
+        {FAIRNESS_WEIGHT,
+         NUM_DANCES_PERFORMED_WEIGHT * scale,
+         PREFERENCE_FAVOURITE_WEIGHT * scale,
+         PREFERENCE_YES_WEIGHT * scale,
+         PREFERENCE_MAYBE_WEIGHT * scale});
+
+    // minus whatever it costs to break any soft dancer limits
+    objective -= LinearExpr::Sum(dancer_limit_misses_) * (DANCER_LIMIT_WEIGHT * scale);
+
+    // and for dancing a smaller version of a dance than there could have been
+    objective -= variant_shortfall_ * (VARIANT_WEIGHT * scale);
+
+    // and, when repairing a set, for every assignment which is made or undone.
+    // previous assignments for dancers who aren't here any more are lost
+    // whatever we do, so they don't need counting.
+    if (!previous_assignments_.empty())
     {
-        ProcessDance(dance);
+        const auto changes =
+            LinearExpr::Sum(new_assignments_) + (int64_t)kept_assignments_.size() - LinearExpr::Sum(kept_assignments_);
+        objective -= changes * (CHANGE_WEIGHT * scale);
     }
 
-    cp_model_.Maximize(CreateObjective());
+    return objective;
 }
 
-const DanceSolver::DanceSolution DanceSolver::DanceSolverImpl::GetSolution(const CpSolverResponse &response)
+// Every dance which is danced and needs a caller gets exactly one, from the
+// active dancers who can call it. Depending on the dance, they might have to be
+// dancing it or standing out.
+void DanceSolver::DanceSolverImpl::ApplyCallers()
 {
-    const auto status = static_cast<SolverStatus>(response.status());
-
-    DancesPerformed dances_performed;
-    if (status == SolverStatus::SolverStatusInfeasible)
+    std::set<int> active_dancers;
+    for (const auto &dancer : dancers_)
     {
-        for (const auto &dance : dances_)
+        if (dancer.Active)
         {
-            dances_performed[dance.ID] = false;
+            active_dancers.insert(dancer.ID);
         }
-        return {status, 0, dances_performed, {}};
-    }
-
-    std::map<int64_t, Dancer> dancer_map;
-    for (const auto &dancer : dancers_)
-    {
-        dancer_map[dancer.ID] = dancer;
     }
 
-    SolutionAssignment positions;
-    int num_assignments = 0;
-
-    for (const auto &dance : dances_)
+    for (const auto &[dance_id, rule] : caller_rules_)
     {
-        auto dance_id = dance.ID;
-
-        auto it = dancer_position_map_.find(dance_id);
-
-        if (it == dancer_position_map_.end())
+        const auto danced = dance_is_danced_vars_.find(dance_id);
+        if (rule == CallerRuleNone || danced == dance_is_danced_vars_.end())
         {
-            Debug(logger_) << "Dance: " << dance_id << " not danced, not enough dancers";
-            dances_performed[dance_id] = false;
             continue;
         }
 
-        auto var = dance_is_danced_vars_[dance_id];
-        const auto is_danced = SolutionBooleanValue(response, var);
-
-        dances_performed[dance_id] = is_danced;
+        const auto dance_str = "dance_" + std::to_string(dance_id);
+        const auto &positions = dancer_is_assigned_map_[dance_id];
 
-        if (!is_danced)
+        std::vector<BoolVar> calling;
+        for (const auto dancer_id : callers_[dance_id])
+        {
+            if (!active_dancers.contains(dancer_id))
+            {
+                continue;
+            }
+
+            const auto name = "dancer_" + std::to_string(dancer_id) + "_calling_" + dance_str;
+            const auto caller = cp_model_.NewBoolVar().WithName(name);
+
+            LinearExpr in_dance;
+            for (const auto &[position_id, dancers] : positions)
+            {
+                if (const auto it = dancers.find(dancer_id); it != dancers.end())
+                {
+                    in_dance += it->second;
+                }
+            }
+
+            switch (rule)
+            {
+            case CallerRuleFromDancers:
+                cp_model_.AddGreaterOrEqual(in_dance, caller).WithName(name + "_dancing");
+                break;
+            case CallerRuleStandingOut:
+                cp_model_.AddLessOrEqual(in_dance + caller, 1).WithName(name + "_standing_out");
+                break;
+            default:
+                break;
+            }
+
+            calling.push_back(caller);
+            dance_caller_vars_[dance_id].emplace(dancer_id, caller);
+        }
+
+        // one caller if the dance is danced, none if it isn't. with nobody to
+        // call it, it can't be danced at all.
+        cp_model_.AddEquality(LinearExpr::Sum(calling), danced->second).WithName(dance_str + "_caller");
+    }
+}
+
+// Only one version of each dance can be danced. Each version costs something
+// for every dancer fewer it has than the largest, so that's the one which is
+// usually picked.
+void DanceSolver::DanceSolverImpl::ApplyVariants()
+{
+    if (variants_.empty())
+    {
+        return;
+    }
+
+    // full dance id -> its versions, including itself
+    std::map<int, std::vector<const Dance *>> versions;
+    for (const auto &dance : dances_)
+    {
+        const auto it = variants_.find(dance.ID);
+        versions[it == variants_.end() ? dance.ID : it->second].push_back(&dance);
+    }
+
+    for (const auto &[full_dance_id, dances] : versions)
+    {
+        if (dances.size() < 2)
         {
-            Debug(logger_) << "Dance: " << dance_id << " not danced";
+            continue;
+        }
+
+        size_t largest = 0;
+        for (const auto *dance : dances)
+        {
+            largest = std::max(largest, dance->Positions.size());
+        }
+
+        std::vector<BoolVar> danced;
+        for (const auto *dance : dances)
+        {
+            const auto dance_is_danced = dance_is_danced_vars_[dance->ID];
+            danced.push_back(dance_is_danced);
+            variant_shortfall_ += dance_is_danced * (int64_t)(largest - dance->Positions.size());
+        }
+
+        cp_model_.AddAtMostOne(danced).WithName("one_version_of_dance_" + std::to_string(full_dance_id));
+    }
+}
+
+// Add this event's variables and constraints to the model, returning its
+// objective
+const LinearExpr DanceSolver::DanceSolverImpl::BuildModel()
+{
+    ProcessDancerPositions(dancer_positions_);
+
+    for (const auto &dance : dances_)
+    {
+        ProcessDance(dance);
+    }
+
+    ApplyPairs();
+    ApplyCallers();
+    ApplyVariants();
+
+    return CreateObjective();
+}
+
+void DanceSolver::DanceSolverImpl::CreateVariablesAndConstraints()
+{
+    if (model_built_)
+    {
+        return;
+    }
+    model_built_ = true;
+
+    cp_model_.Maximize(BuildModel());
+}
+
+// If the solver was `stopped`, any solution is kept but it's marked as
+// cancelled, unless it was proved optimal before the solver noticed.
+const DanceSolver::DanceSolution DanceSolver::DanceSolverImpl::GetSolution(const CpSolverResponse &response, bool stopped)
+{
+    auto status = static_cast<SolverStatus>(response.status());
+    const bool found = status == SolverStatus::SolverStatusOptimal || status == SolverStatus::SolverStatusFeasible;
+    if (stopped && status != SolverStatus::SolverStatusOptimal)
+    {
+        status = SolverStatus::SolverStatusCancelled;
+    }
+
+    DancesPerformed dances_performed;
+    if (!found)
+    {
+        for (const auto &dance : dances_)
+        {
+            dances_performed[dance.ID] = false;
+        }
+        return {status, 0, dances_performed, {}, 0, {}};
+    }
+
+    std::map<int64_t, Dancer> dancer_map;
+    for (const auto &dancer : dancers_)
+    {
+        dancer_map[dancer.ID] = dancer;
+    }
+
+    SolutionAssignment positions;
+    int num_assignments = 0;
+
+    for (const auto &dance : dances_)
+    {
+        auto dance_id = dance.ID;
+
+        auto it = dancer_position_map_.find(dance_id);
+
+        if (it == dancer_position_map_.end())
+        {
+            Debug(logger_) << "Dance: " << dance_id << " not danced, not enough dancers";
+            dances_performed[dance_id] = false;
+            continue;
+        }
+
+        auto var = dance_is_danced_vars_[dance_id];
+        const auto is_danced = SolutionBooleanValue(response, var);
+
+        dances_performed[dance_id] = is_danced;
+
+        if (!is_danced)
+        {
+            Debug(logger_) << "Dance: " << dance_id << " not danced";
             continue;
         }
 
@@ -502,70 +1207,662 @@ const DanceSolver::DanceSolution DanceSolver::DanceSolverImpl::GetSolution(const
             auto variable = position_map[position_id];
             auto value = SolutionIntegerValue(response, variable);
 
-            Debug(logger_) << "Dance: " << dance_id << " Position: " << position_id << " Dancer: " << value;
+            Debug(logger_) << "Dance: " << dance_id << " Position: " << position_id << " Dancer: " << value;
+
+            positions[dance_id][position_id] = dancer_map[value].ID;
+            num_assignments++;
+        }
+    }
+
+    // print min, max, diff
+    auto min_dances = SolutionIntegerValue(response, min_dances_);
+    auto max_dances = SolutionIntegerValue(response, max_dances_);
+    auto dance_diff = SolutionIntegerValue(response, dance_diff_);
+
+    Debug(logger_) << "min dances: " << min_dances;
+    Debug(logger_) << "max dances: " << max_dances;
+    Debug(logger_) << "dance diff: " << dance_diff;
+
+    // print the preferences satisfied
+    auto favourite_count = SolutionIntegerValue(response, favourite_count_);
+    auto yes_count = SolutionIntegerValue(response, yes_count_);
+    auto maybe_count = SolutionIntegerValue(response, maybe_count_);
+
+    Debug(logger_) << "favourite count: " << favourite_count;
+    Debug(logger_) << "yes count: " << yes_count;
+    Debug(logger_) << "maybe count: " << maybe_count;
+
+    const auto objective = static_cast<int64_t>(response.objective_value());
+    Debug(logger_) << "objective: " << objective;
+
+    DanceCallers callers;
+    for (const auto &[dance_id, dancers] : dance_caller_vars_)
+    {
+        for (const auto &[dancer_id, calling] : dancers)
+        {
+            if (SolutionBooleanValue(response, calling))
+            {
+                Debug(logger_) << "Dance: " << dance_id << " Caller: " << dancer_id;
+                callers[dance_id] = dancer_id;
+            }
+        }
+    }
+
+    return {status, num_assignments, dances_performed, positions, objective, callers};
+}
+
+// Write `proto` to `path` for debugging. Nothing is written if `path` is empty.
+static void WriteProto(logger *logger_, const std::string &path, const google::protobuf::Message &proto, DumpFormat format)
+{
+    if (path.empty())
+    {
+        return;
+    }
+
+    std::ofstream out(path, std::ios::binary | std::ios::trunc);
+    if (!out)
+    {
+        Error(logger_) << "can't open " << path << " to write to it";
+        return;
+    }
+
+    bool ok = false;
+    switch (format)
+    {
+    case DumpFormat::DumpFormatText:
+    {
+        std::string text;
+        ok = google::protobuf::TextFormat::PrintToString(proto, &text);
+        out << text;
+        break;
+    }
+    case DumpFormat::DumpFormatBinary:
+        ok = proto.SerializeToOstream(&out);
+        break;
+    }
+
+    if (!ok || !out)
+    {
+        Error(logger_) << "failed to write " << path;
+        return;
+    }
+
+    Debug(logger_) << "wrote " << path;
+}
+
+// Read a proto written by `WriteProto`, in either format.
+static bool ReadProto(logger *logger_, const std::string &path, google::protobuf::Message *proto)
+{
+    std::ifstream in(path, std::ios::binary);
+    if (!in)
+    {
+        Error(logger_) << "can't open " << path << " to read it";
+        return false;
+    }
+
+    std::stringstream contents;
+    contents << in.rdbuf();
+
+    // text first: a text proto can happen to parse as binary, but binary
+    // almost never parses as text
+    if (google::protobuf::TextFormat::ParseFromString(contents.str(), proto) || proto->ParseFromString(contents.str()))
+    {
+        return true;
+    }
+
+    Error(logger_) << path << " isn't a model in text or binary format";
+    return false;
+}
+
+typedef std::function<void(const CpSolverResponse &)> ResponseObserver;
+
+// `stop` can be set from another thread to stop the solver early. It can be
+// null. `num_workers` of 0 leaves CP-SAT's default.
+static const CpSolverResponse SolveProto(
+    logger *logger_,
+    const CpModelProto &b,
+    const DumpOptions &dump,
+    int num_workers,
+    std::atomic<bool> *stop,
+    const ResponseObserver &observer)
+{
+    // Build a solver and configure it
+    Model model;
+
+    SatParameters parameters;
+    parameters.fill_additional_solutions_in_response();
+    parameters.set_instantiate_all_variables(true);
+    if (num_workers > 0)
+    {
+        parameters.set_num_workers(num_workers);
+    }
+    // parameters.set_log_search_progress(true);
+
+    model.Add(NewSatParameters(parameters));
+
+    if (observer)
+    {
+        model.Add(NewFeasibleSolutionObserver(observer));
+    }
+
+    if (stop)
+    {
+        model.GetOrCreate<TimeLimit>()->RegisterExternalBooleanAsLimit(stop);
+    }
+
+    // This is useful when something is broken, you often get errors referring
+    // to variables/constraints by index
+    for (int i = 0; i < b.variables_size(); ++i)
+    {
+        auto var = b.variables(i);
+        Trace(logger_) << "variable " << std::to_string(i) << " : " << var.name();
+    }
+
+    for (int i = 0; i < b.constraints_size(); ++i)
+    {
+        auto constraint = b.constraints(i);
+        Trace(logger_) << "constraint " << std::to_string(i) << " : " << constraint.name();
+    }
+
+    WriteProto(logger_, dump.ModelPath, b, dump.Format);
+
+    // Solve the model.
+    const CpSolverResponse response = SolveCpModel(b, &model);
+    Debug(logger_) << "Finished: " << CpSolverResponseStats(response);
+
+    WriteProto(logger_, dump.ResponsePath, response, dump.Format);
+
+    return response;
+}
+
+static const CpSolverResponse RunSolver(
+    logger *logger_,
+    const CpModelBuilder &cp_model_,
+    const DumpOptions &dump,
+    int num_workers,
+    std::atomic<bool> &stop,
+    const ResponseObserver &observer = {})
+{
+    return SolveProto(logger_, cp_model_.Build(), dump, num_workers, &stop, observer);
+}
+
+__attribute__((visibility("default")))
+const std::optional<ReplaySolution>
+ReplayDump(logger *logger_, const std::string &model_path, const DumpOptions &dump)
+{
+    CpModelProto model_proto;
+    if (!ReadProto(logger_, model_path, &model_proto))
+    {
+        return std::nullopt;
+    }
+
+    // the model's already on disk
+    const auto response = SolveProto(logger_, model_proto, {"", dump.ResponsePath, dump.Format}, 0, nullptr, {});
+
+    return ReplaySolution{
+        static_cast<SolverStatus>(response.status()),
+        static_cast<int64_t>(response.objective_value()),
+        CpSolverResponseStats(response)};
+}
+
+const CpSolverResponse DanceSolver::DanceSolverImpl::SolveModel()
+{
+    CreateVariablesAndConstraints();
+
+    if (!solution_observer_)
+    {
+        return RunSolver(logger_, cp_model_, dump_, num_workers_, stop_);
+    }
+
+    const auto observer = [this](const CpSolverResponse &response)
+    {
+        // intermediate responses don't always have their status filled in,
+        // but they're all feasible
+        auto feasible = response;
+        if (feasible.status() != CpSolverStatus::OPTIMAL)
+        {
+            feasible.set_status(CpSolverStatus::FEASIBLE);
+        }
+
+        solution_observer_(GetSolution(feasible));
+    };
+
+    return RunSolver(logger_, cp_model_, dump_, num_workers_, stop_, observer);
+}
+
+const DanceSolver::DanceSolution DanceSolver::DanceSolverImpl::GetPossibleDances()
+{
+    return GetSolution(SolveModel(), stop_);
+}
+
+// Rule out any solution which is within `min_difference` of the one in
+// `response`. Enumerating every solution with CP-SAT would give us lots which
+// only differ by swapping two people around, which isn't much of a choice.
+void DanceSolver::DanceSolverImpl::ExcludeSimilarSolutions(
+    const CpSolverResponse &response,
+    int min_difference,
+    AlternativeDifference difference,
+    int solution_number)
+{
+    std::vector<BoolVar> vars;
+    switch (difference)
+    {
+    case AlternativeDifference::AlternativeDifferenceDances:
+        for (const auto &[dance_id, var] : dance_is_danced_vars_)
+        {
+            vars.push_back(var);
+        }
+        break;
+    case AlternativeDifference::AlternativeDifferenceAssignments:
+        vars = dancer_is_assigned_vars_;
+        break;
+    }
+
+    // count how many of the variables have changed: the ones which were true
+    // and are now false, plus the ones which were false and are now true
+    std::vector<BoolVar> were_true;
+    std::vector<BoolVar> were_false;
+    for (const auto &var : vars)
+    {
+        if (SolutionBooleanValue(response, var))
+        {
+            were_true.push_back(var);
+        }
+        else
+        {
+            were_false.push_back(var);
+        }
+    }
+
+    const auto changed =
+        LinearExpr::Sum(were_false) + (int64_t)were_true.size() - LinearExpr::Sum(were_true);
+
+    cp_model_.AddGreaterOrEqual(changed, min_difference)
+        .WithName("differs_from_solution_" + std::to_string(solution_number));
+}
+
+const std::vector<DanceSolver::DanceSolution> DanceSolver::DanceSolverImpl::GetAlternativeDances(
+    int num_alternatives,
+    int min_difference,
+    AlternativeDifference difference)
+{
+    std::vector<DanceSolution> solutions;
+
+    // solve, rule out that solution and anything like it, and go again. each
+    // solution can't be better than the one before as the model only gets
+    // more constrained, so they come out ranked best first.
+    for (int i = 0; i < num_alternatives; ++i)
+    {
+        const auto response = SolveModel();
+        const auto solution = GetSolution(response, stop_);
+
+        if (solution.status == SolverStatus::SolverStatusCancelled)
+        {
+            Debug(logger_) << "stopped while looking for alternative " << i;
+            if (solution.num_assignments > 0)
+            {
+                solutions.push_back(solution);
+            }
+            break;
+        }
+
+        if (solution.status != SolverStatus::SolverStatusOptimal && solution.status != SolverStatus::SolverStatusFeasible)
+        {
+            Debug(logger_) << "no more alternatives after " << i;
+            break;
+        }
+
+        solutions.push_back(solution);
+        ExcludeSimilarSolutions(response, min_difference, difference, i);
+    }
+
+    return solutions;
+}
+
+class SeasonSolver::SeasonSolverImpl
+{
+public:
+    SeasonSolverImpl(
+        logger *logger,
+        const std::vector<Dance> &dances,
+        const std::vector<DancerPosition> &dancer_positions);
+    int AddEvent(const std::vector<Dancer> &dancers);
+    void SetNumDances(int min_dances, int max_dances);
+    void SetDanceCaller(int dance_id, CallerRule rule);
+    void AddCaller(int event, int dancer_id, int dance_id);
+    void SetDanceVariant(int dance_id, int full_dance_id);
+    void SetDump(const DumpOptions &dump);
+    void SetNumWorkers(int num_workers);
+    void Stop();
+    const std::vector<DanceSolver::DanceSolution> Solve();
+
+private:
+    void AddSpread(
+        const std::string &name,
+        const std::map<int, std::vector<IntVar>> &totals,
+        const std::map<int, int> &attendance,
+        int64_t scale,
+        int64_t max_per_event,
+        int64_t weight,
+        LinearExpr &objective);
+    const LinearExpr CreateObjective();
+
+    logger *logger_;
+
+    CpModelBuilder cp_model_;
+
+    const std::vector<Dance> dances_;
+    const std::vector<DancerPosition> dancer_positions_;
+
+    // one per event, in order
+    std::vector<std::unique_ptr<DanceSolver::DanceSolverImpl>> events_;
+
+    // bounds on the length of each event's set, 0 means unbounded
+    int min_set_length_ = 0;
+    int max_set_length_ = 0;
+
+    // dance id -> who calls it, at every event
+    std::map<int, CallerRule> caller_rules_;
+
+    // variant dance id -> the dance it's a version of
+    std::map<int, int> variants_;
+
+    DumpOptions dump_;
+    int num_workers_ = 0;
+    std::atomic<bool> stop_ = false;
+};
+
+__attribute__((visibility("default")))
+SeasonSolver::SeasonSolver(
+    logger *logger,
+    std::vector<Dance> &dances,
+    std::vector<DancerPosition> &dancer_positions)
+    : pimpl_(std::make_unique<SeasonSolver::SeasonSolverImpl>(
+          logger,
+          dances,
+          dancer_positions))
+{
+}
+
+SeasonSolver::~SeasonSolver() = default;
+
+__attribute__((visibility("default"))) int SeasonSolver::AddEvent(std::vector<Dancer> &dancers)
+{
+    return pimpl_->AddEvent(dancers);
+}
+
+__attribute__((visibility("default"))) void SeasonSolver::SetNumDances(int min_dances, int max_dances)
+{
+    pimpl_->SetNumDances(min_dances, max_dances);
+}
+
+__attribute__((visibility("default"))) void SeasonSolver::SetDanceCaller(int dance_id, CallerRule rule)
+{
+    pimpl_->SetDanceCaller(dance_id, rule);
+}
+
+__attribute__((visibility("default"))) void SeasonSolver::AddCaller(int event, int dancer_id, int dance_id)
+{
+    pimpl_->AddCaller(event, dancer_id, dance_id);
+}
+
+__attribute__((visibility("default"))) void SeasonSolver::SetDanceVariant(int dance_id, int full_dance_id)
+{
+    pimpl_->SetDanceVariant(dance_id, full_dance_id);
+}
+
+__attribute__((visibility("default"))) void SeasonSolver::SetDump(const DumpOptions &dump)
+{
+    pimpl_->SetDump(dump);
+}
+
+__attribute__((visibility("default"))) void SeasonSolver::SetNumWorkers(int num_workers)
+{
+    pimpl_->SetNumWorkers(num_workers);
+}
+
+__attribute__((visibility("default"))) void SeasonSolver::Stop()
+{
+    pimpl_->Stop();
+}
+
+__attribute__((visibility("default")))
+const std::vector<DanceSolver::DanceSolution>
+SeasonSolver::Solve()
+{
+    return pimpl_->Solve();
+}
+
+SeasonSolver::SeasonSolverImpl::SeasonSolverImpl(
+    logger *logger,
+    const std::vector<Dance> &dances,
+    const std::vector<DancerPosition> &dancer_positions)
+    : logger_(logger),
+      dances_(dances),
+      dancer_positions_(dancer_positions)
+{
+}
 
-            positions[dance_id][position_id] = dancer_map[value].ID;
-            num_assignments++;
+int SeasonSolver::SeasonSolverImpl::AddEvent(const std::vector<Dancer> &dancers)
+{
+    // each event only knows about the dancers who are there. the others'
+    // preferences would let the event's model assign them.
+    std::set<int> dancer_ids;
+    for (const auto &dancer : dancers)
+    {
+        dancer_ids.insert(dancer.ID);
+    }
+
+    std::vector<DancerPosition> dancer_positions;
+    for (const auto &dancer_position : dancer_positions_)
+    {
+        if (dancer_ids.contains(dancer_position.DancerID))
+        {
+            dancer_positions.push_back(dancer_position);
         }
     }
 
-    // print min, max, diff
-    auto min_dances = SolutionIntegerValue(response, min_dances_);
-    auto max_dances = SolutionIntegerValue(response, max_dances_);
-    auto dance_diff = SolutionIntegerValue(response, dance_diff_);
+    Debug(logger_) << "event " << events_.size() << ": " << dancers.size() << " dancers";
 
-    Debug(logger_) << "min dances: " << min_dances;
-    Debug(logger_) << "max dances: " << max_dances;
-    Debug(logger_) << "dance diff: " << dance_diff;
+    events_.push_back(std::make_unique<DanceSolver::DanceSolverImpl>(
+        logger_,
+        dancers,
+        dances_,
+        dancer_positions,
+        cp_model_));
 
-    // print the preferences satisfied
-    auto favourite_count = SolutionIntegerValue(response, favourite_count_);
-    auto yes_count = SolutionIntegerValue(response, yes_count_);
-    auto maybe_count = SolutionIntegerValue(response, maybe_count_);
+    return events_.size() - 1;
+}
 
-    Debug(logger_) << "favourite count: " << favourite_count;
-    Debug(logger_) << "yes count: " << yes_count;
-    Debug(logger_) << "maybe count: " << maybe_count;
+void SeasonSolver::SeasonSolverImpl::SetNumDances(int min_dances, int max_dances)
+{
+    min_set_length_ = min_dances;
+    max_set_length_ = max_dances;
+}
 
-    return {status, num_assignments, dances_performed, positions};
+void SeasonSolver::SeasonSolverImpl::SetDanceCaller(int dance_id, CallerRule rule)
+{
+    caller_rules_[dance_id] = rule;
 }
 
-const DanceSolver::DanceSolution DanceSolver::DanceSolverImpl::GetPossibleDances()
+// Who can call a dance depends on who's there, so callers are added to one
+// event, which must have been added already.
+void SeasonSolver::SeasonSolverImpl::AddCaller(int event, int dancer_id, int dance_id)
 {
-    CreateVariablesAndConstraints();
+    events_.at(event)->AddCaller(dancer_id, dance_id);
+}
 
-    // Build a solver and configure it
-    Model model;
+void SeasonSolver::SeasonSolverImpl::SetDanceVariant(int dance_id, int full_dance_id)
+{
+    variants_[dance_id] = full_dance_id;
+}
 
-    SatParameters parameters;
-    parameters.fill_additional_solutions_in_response();
-    parameters.set_instantiate_all_variables(true);
-    // parameters.set_enumerate_all_solutions(true);
-    // parameters.set_log_search_progress(true);
+void SeasonSolver::SeasonSolverImpl::SetDump(const DumpOptions &dump)
+{
+    dump_ = dump;
+}
 
-    model.Add(NewSatParameters(parameters));
+void SeasonSolver::SeasonSolverImpl::SetNumWorkers(int num_workers)
+{
+    num_workers_ = num_workers;
+}
 
-    auto b = cp_model_.Build();
+void SeasonSolver::SeasonSolverImpl::Stop()
+{
+    stop_ = true;
+}
 
-    // This is useful when something is broken, you often get errors referring
-    // to variables/constraints by index
-    for (int i = 0; i < b.variables_size(); ++i)
+// Penalise the difference between the dancers with the highest and lowest
+// season totals. Somebody who has only been to one event can't be expected to
+// have done as much as somebody who's been to all of them, so each total is
+// scaled by how many events the dancer was at: `scale` is a multiple of all of
+// the attendance counts, so this stays in whole numbers.
+void SeasonSolver::SeasonSolverImpl::AddSpread(
+    const std::string &name,
+    const std::map<int, std::vector<IntVar>> &totals,
+    const std::map<int, int> &attendance,
+    int64_t scale,
+    int64_t max_per_event,
+    int64_t weight,
+    LinearExpr &objective)
+{
+    std::vector<IntVar> scaled_totals;
+    for (const auto &[dancer_id, events_attended] : attendance)
     {
-        auto var = b.variables(i);
-        Trace(logger_) << "variable " << std::to_string(i) << " : " << var.name();
+        const auto scaled_total =
+            cp_model_.NewIntVar({0, max_per_event * scale})
+                .WithName(name + "_dancer_" + std::to_string(dancer_id));
+
+        LinearExpr total;
+        const auto it = totals.find(dancer_id);
+        if (it != totals.end())
+        {
+            total = LinearExpr::Sum(it->second);
+        }
+
+        cp_model_.AddEquality(scaled_total, total * (scale / events_attended))
+            .WithName(name + "_dancer_" + std::to_string(dancer_id));
+        scaled_totals.push_back(scaled_total);
     }
 
-    for (int i = 0; i < b.constraints_size(); ++i)
+    if (scaled_totals.empty())
     {
-        auto constraint = b.constraints(i);
-        Trace(logger_) << "constraint " << std::to_string(i) << " : " << constraint.name();
+        return;
     }
 
-    // Solve the model.
-    const CpSolverResponse response = SolveCpModel(b, &model);
-    Debug(logger_) << "Finished: " << CpSolverResponseStats(response);
+    const auto min = cp_model_.NewIntVar({0, max_per_event * scale}).WithName(name + "_min");
+    const auto max = cp_model_.NewIntVar({0, max_per_event * scale}).WithName(name + "_max");
+    cp_model_.AddMinEquality(min, scaled_totals).WithName(name + "_min");
+    cp_model_.AddMaxEquality(max, scaled_totals).WithName(name + "_max");
+
+    objective -= (LinearExpr(max) - min) * weight;
+}
+
+const LinearExpr SeasonSolver::SeasonSolverImpl::CreateObjective()
+{
+    LinearExpr events_objective;
+    for (auto &event : events_)
+    {
+        event->SetNumDances(min_set_length_, max_set_length_);
+        for (const auto &[dance_id, rule] : caller_rules_)
+        {
+            event->SetDanceCaller(dance_id, rule);
+        }
+        for (const auto &[dance_id, full_dance_id] : variants_)
+        {
+            event->SetDanceVariant(dance_id, full_dance_id);
+        }
+        events_objective += event->BuildModel();
+    }
+
+    // active dancer id -> how many events they're at
+    std::map<int, int> attendance;
+    // dancer id -> per event dance counts, and favourite positions
+    std::map<int, std::vector<IntVar>> dance_counts;
+    std::map<int, std::vector<IntVar>> favourite_counts;
+
+    for (const auto &event : events_)
+    {
+        for (const auto &dancer : event->dancers_)
+        {
+            if (dancer.Active)
+            {
+                attendance[dancer.ID]++;
+            }
+        }
+
+        for (const auto &[dancer_id, count] : event->dancer_count_vars_)
+        {
+            dance_counts[dancer_id].push_back(count);
+        }
+
+        for (const auto &[dancer_id, favourites] : event->favourites_by_dancer_)
+        {
+            const auto count =
+                cp_model_.NewIntVar({0, (int64_t)favourites.size()})
+                    .WithName("favourite_count_dancer_" + std::to_string(dancer_id));
+            cp_model_.AddEquality(count, LinearExpr::Sum(favourites))
+                .WithName("favourite_count_dancer_" + std::to_string(dancer_id));
+            favourite_counts[dancer_id].push_back(count);
+        }
+    }
+
+    int64_t scale = 1;
+    for (const auto &[dancer_id, events_attended] : attendance)
+    {
+        scale = std::lcm(scale, (int64_t)events_attended);
+    }
+    Debug(logger_) << "season objective scale: " << scale;
+
+    // the events' objectives are scaled up too, so the season terms don't
+    // drown them out
+    auto objective = events_objective * scale;
+
+    const auto max_per_event = (int64_t)dances_.size();
+    AddSpread("season_dances", dance_counts, attendance, scale, max_per_event, SEASON_FAIRNESS_WEIGHT, objective);
+    AddSpread("season_favourites", favourite_counts, attendance, scale, max_per_event, SEASON_FAVOURITE_FAIRNESS_WEIGHT, objective);
+
+    // rotate the repertoire: it costs something to dance a dance at the event
+    // straight after one where it was danced
+    std::vector<BoolVar> repeats;
+    for (size_t i = 1; i < events_.size(); ++i)
+    {
+        for (const auto &dance : dances_)
+        {
+            const auto before = events_[i - 1]->dance_is_danced_vars_[dance.ID];
+            const auto after = events_[i]->dance_is_danced_vars_[dance.ID];
+
+            const auto name = "dance_" + std::to_string(dance.ID) + "_repeated_at_event_" + std::to_string(i);
+            const auto repeat = cp_model_.NewBoolVar().WithName(name);
+            cp_model_.AddGreaterOrEqual(repeat, LinearExpr(before) + after - 1)
+                .WithName(name);
+            repeats.push_back(repeat);
+        }
+    }
+
+    objective -= LinearExpr::Sum(repeats) * (REPEAT_WEIGHT * scale);
+
+    return objective;
+}
+
+const std::vector<DanceSolver::DanceSolution> SeasonSolver::SeasonSolverImpl::Solve()
+{
+    if (events_.empty())
+    {
+        return {};
+    }
+
+    cp_model_.Maximize(CreateObjective());
+
+    const auto response = RunSolver(logger_, cp_model_, dump_, num_workers_, stop_);
 
-    return GetSolution(response);
+    std::vector<DanceSolver::DanceSolution> solutions;
+    for (auto &event : events_)
+    {
+        solutions.push_back(event->GetSolution(response, stop_));
+    }
+
+    return solutions;
 }
 
 extern "C"
@@ -615,6 +1912,7 @@ extern "C"
     {
         DanceSolver::DancesPerformed dances_performed;
         DanceSolver::SolutionAssignment assignments;
+        DanceSolver::DanceCallers callers;
     };
 
     dance_solver_c_api::DanceSolution *dance_solution_new(DanceSolver::DanceSolution solution)
@@ -624,17 +1922,132 @@ extern "C"
         sol->status = static_cast<dance_solver_c_api::SolverStatus>(solution.status);
         sol->num_assignments = solution.num_assignments;
         sol->num_dances = solution.dance_performed.size();
+        sol->objective = solution.objective;
         sol->priv->dances_performed = solution.dance_performed;
         sol->priv->assignments = solution.assignment;
+        sol->priv->callers = solution.callers;
 
         return sol;
     }
 
+    dance_solver_c_api::DanceSolutionList *dance_solution_list_new(const std::vector<DanceSolver::DanceSolution> &solutions)
+    {
+        auto list = new dance_solver_c_api::DanceSolutionList();
+        list->num_solutions = solutions.size();
+        list->solutions = new dance_solver_c_api::DanceSolution *[solutions.size()];
+        for (size_t i = 0; i < solutions.size(); ++i)
+        {
+            list->solutions[i] = dance_solution_new(solutions[i]);
+        }
+
+        return list;
+    }
+
     __attribute__((visibility("default"))) void free_dance_solver(dance_solver_c_api::Solver *solver)
     {
         delete solver;
     }
 
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_num_dances(
+        dance_solver_c_api::Solver *solver, int min_dances, int max_dances)
+    {
+        solver->impl->SetNumDances(min_dances, max_dances);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_include_dance(
+        dance_solver_c_api::Solver *solver, int dance_id)
+    {
+        solver->impl->IncludeDance(dance_id);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_exclude_dance(
+        dance_solver_c_api::Solver *solver, int dance_id)
+    {
+        solver->impl->ExcludeDance(dance_id);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_pin_dancer(
+        dance_solver_c_api::Solver *solver, int dancer_id, int dance_id, int position_id)
+    {
+        solver->impl->PinDancer(dancer_id, dance_id, position_id);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_dance_caller(
+        dance_solver_c_api::Solver *solver, int dance_id, dance_solver_c_api::CallerRule rule)
+    {
+        solver->impl->SetDanceCaller(dance_id, static_cast<::CallerRule>(rule));
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_add_caller(
+        dance_solver_c_api::Solver *solver, int dancer_id, int dance_id)
+    {
+        solver->impl->AddCaller(dancer_id, dance_id);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_dance_variant(
+        dance_solver_c_api::Solver *solver, int dance_id, int full_dance_id)
+    {
+        solver->impl->SetDanceVariant(dance_id, full_dance_id);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_dancer_weight(
+        dance_solver_c_api::Solver *solver, int dancer_id, int weight)
+    {
+        solver->impl->SetDancerWeight(dancer_id, weight);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_add_pair(
+        dance_solver_c_api::Solver *solver, int dancer_id, int other_id, dance_solver_c_api::PairRule rule)
+    {
+        solver->impl->AddPair(dancer_id, other_id, static_cast<::PairRule>(rule));
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_facing_positions(
+        dance_solver_c_api::Solver *solver, int dance_id, int position_id, int other_position_id)
+    {
+        solver->impl->SetFacingPositions(dance_id, position_id, other_position_id);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_dancer_limits(
+        dance_solver_c_api::Solver *solver, int dancer_id, int min_dances, int max_dances, int soft)
+    {
+        solver->impl->SetDancerLimits(dancer_id, {min_dances, max_dances, soft != 0});
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_previous_assignment(
+        dance_solver_c_api::Solver *solver, int dancer_id, int dance_id, int position_id)
+    {
+        solver->impl->SetPreviousAssignment(dancer_id, dance_id, position_id);
+    }
+
+    // NULL paths aren't written
+    static DumpOptions dump_options_new(const char *model_path, const char *response_path, dance_solver_c_api::DumpFormat format)
+    {
+        return {
+            model_path ? model_path : "",
+            response_path ? response_path : "",
+            static_cast<DumpFormat>(format)};
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_solution_observer(
+        dance_solver_c_api::Solver *solver, dance_solver_c_api::solution_observer *observer)
+    {
+        const auto on_solution = [observer = *observer](const DanceSolver::DanceSolution &solution)
+        {
+            auto c_solution = dance_solution_new(solution);
+            observer.on_solution(observer.handle, c_solution);
+            free_dance_solution(c_solution);
+        };
+
+        solver->impl->SetSolutionObserver(on_solution);
+    }
+
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_dump(
+        dance_solver_c_api::Solver *solver, const char *model_path, const char *response_path, dance_solver_c_api::DumpFormat format)
+    {
+        solver->impl->SetDump(dump_options_new(model_path, response_path, format));
+    }
+
     // C wrapper for the C++ public API, mainly so we can call it from Go
     // Invokes the solver and then flattens the solution into a C struct.  On
     // the Go side we will be copying back into managed memory (Go structs) so
@@ -651,6 +2064,23 @@ extern "C"
         return dance_solution_new(cpp_solution);
     }
 
+    __attribute__((visibility("default")))
+    dance_solver_c_api::DanceSolutionList *
+    get_alternative_dances(
+        dance_solver_c_api::Solver *solver_ptr,
+        int num_alternatives,
+        int min_difference,
+        dance_solver_c_api::AlternativeDifference difference)
+    {
+        auto solver = solver_ptr->impl.get();
+        const auto cpp_solutions = solver->GetAlternativeDances(
+            num_alternatives,
+            min_difference,
+            static_cast<AlternativeDifference>(difference));
+
+        return dance_solution_list_new(cpp_solutions);
+    }
+
     __attribute__((visibility("default"))) int dance_solver_c_api::get_dancer_dance_position(
         dance_solver_c_api::DanceSolution *assignments, int dance_id, int position_id)
     {
@@ -689,10 +2119,152 @@ extern "C"
         return it->second ? 1 : 0;
     }
 
+    __attribute__((visibility("default"))) int dance_solver_c_api::get_dance_caller(
+        dance_solver_c_api::DanceSolution *solution, int dance_id)
+    {
+        const auto &callers = solution->priv->callers;
+
+        const auto it = callers.find(dance_id);
+        if (it == callers.end())
+        {
+            return -1;
+        }
+
+        return it->second;
+    }
+
     __attribute__((visibility("default"))) void free_dance_solution(dance_solver_c_api::DanceSolution *solution)
     {
         delete solution->priv;
         solution->priv = nullptr;
         delete solution;
     }
+
+    __attribute__((visibility("default"))) void free_dance_solution_list(dance_solver_c_api::DanceSolutionList *list)
+    {
+        for (int i = 0; i < list->num_solutions; ++i)
+        {
+            free_dance_solution(list->solutions[i]);
+        }
+        delete[] list->solutions;
+        delete list;
+    }
+
+    // How many threads to search with. 0 lets CP-SAT choose.
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_set_num_workers(
+        dance_solver_c_api::Solver *solver, int num_workers)
+    {
+        solver->impl->SetNumWorkers(num_workers);
+    }
+
+    // Stops a solve running in another thread, which then returns the best
+    // solution it has found so far.
+    __attribute__((visibility("default"))) void dance_solver_c_api::dance_solver_stop(dance_solver_c_api::Solver *solver)
+    {
+        solver->impl->Stop();
+    }
+
+    struct dance_solver_c_api::SeasonSolver
+    {
+        std::unique_ptr<::SeasonSolver> impl;
+    };
+
+    __attribute__((visibility("default")))
+    dance_solver_c_api::SeasonSolver *
+    season_solver_new_with_logger(
+        logger *l,
+        dance_solver_c_api::Dance *dances, int num_dances,
+        dance_solver_c_api::DancerPosition *dancer_positions, int num_dancer_positions)
+    {
+        std::vector<Dance> cpp_dances(dances, dances + num_dances);
+        std::vector<DancerPosition> cpp_dancer_positions(dancer_positions, dancer_positions + num_dancer_positions);
+
+        return new dance_solver_c_api::SeasonSolver{
+            std::make_unique<::SeasonSolver>(l, cpp_dances, cpp_dancer_positions)};
+    }
+
+    __attribute__((visibility("default"))) void free_season_solver(dance_solver_c_api::SeasonSolver *solver)
+    {
+        delete solver;
+    }
+
+    __attribute__((visibility("default"))) int season_solver_add_event(
+        dance_solver_c_api::SeasonSolver *solver, dance_solver_c_api::Dancer *dancers, int num_dancers)
+    {
+        std::vector<Dancer> cpp_dancers(dancers, dancers + num_dancers);
+
+        return solver->impl->AddEvent(cpp_dancers);
+    }
+
+    __attribute__((visibility("default"))) void season_solver_set_num_dances(
+        dance_solver_c_api::SeasonSolver *solver, int min_dances, int max_dances)
+    {
+        solver->impl->SetNumDances(min_dances, max_dances);
+    }
+
+    __attribute__((visibility("default"))) void season_solver_set_dance_caller(
+        dance_solver_c_api::SeasonSolver *solver, int dance_id, dance_solver_c_api::CallerRule rule)
+    {
+        solver->impl->SetDanceCaller(dance_id, static_cast<::CallerRule>(rule));
+    }
+
+    __attribute__((visibility("default"))) void season_solver_add_caller(
+        dance_solver_c_api::SeasonSolver *solver, int event, int dancer_id, int dance_id)
+    {
+        solver->impl->AddCaller(event, dancer_id, dance_id);
+    }
+
+    __attribute__((visibility("default"))) void season_solver_set_dance_variant(
+        dance_solver_c_api::SeasonSolver *solver, int dance_id, int full_dance_id)
+    {
+        solver->impl->SetDanceVariant(dance_id, full_dance_id);
+    }
+
+    __attribute__((visibility("default"))) void season_solver_set_num_workers(
+        dance_solver_c_api::SeasonSolver *solver, int num_workers)
+    {
+        solver->impl->SetNumWorkers(num_workers);
+    }
+
+    __attribute__((visibility("default"))) void season_solver_stop(dance_solver_c_api::SeasonSolver *solver)
+    {
+        solver->impl->Stop();
+    }
+
+    __attribute__((visibility("default")))
+    dance_solver_c_api::DanceSolutionList *
+    season_solver_solve(dance_solver_c_api::SeasonSolver *solver)
+    {
+        return dance_solution_list_new(solver->impl->Solve());
+    }
+
+    __attribute__((visibility("default"))) void season_solver_set_dump(
+        dance_solver_c_api::SeasonSolver *solver, const char *model_path, const char *response_path, dance_solver_c_api::DumpFormat format)
+    {
+        solver->impl->SetDump(dump_options_new(model_path, response_path, format));
+    }
+
+    __attribute__((visibility("default")))
+    dance_solver_c_api::DumpReplay *
+    dance_solver_replay_dump(logger *l, const char *model_path, const char *response_path, dance_solver_c_api::DumpFormat format)
+    {
+        const auto solution = ReplayDump(l, model_path, dump_options_new(nullptr, response_path, format));
+        if (!solution)
+        {
+            return nullptr;
+        }
+
+        auto replay = new dance_solver_c_api::DumpReplay();
+        replay->status = static_cast<dance_solver_c_api::SolverStatus>(solution->status);
+        replay->objective = solution->objective;
+        replay->stats = strdup(solution->stats.c_str());
+
+        return replay;
+    }
+
+    __attribute__((visibility("default"))) void free_dump_replay(dance_solver_c_api::DumpReplay *replay)
+    {
+        free(replay->stats);
+        delete replay;
+    }
 }
@@ -19,6 +19,8 @@ typedef enum
     SolverStatusFeasible = 2,
     SolverStatusInfeasible = 3,
     SolverStatusOptimal = 4,
+    // stopped before it finished: any solution is the best found so far
+    SolverStatusCancelled = 5,
 } SolverStatus;
 
 typedef enum
@@ -27,3 +29,45 @@ typedef enum
     DancerPositionStatusNo = 1,
     DancerPositionStatusYes = 2,
 } DancerPositionStatus;
+
+// how alternative solutions are made to differ from each other
+typedef enum
+{
+    // by which dances are in the set
+    AlternativeDifferenceDances = 0,
+    // by who is dancing which position
+    AlternativeDifferenceAssignments = 1,
+} AlternativeDifference;
+
+// a rule about two dancers being in the same dance
+typedef enum
+{
+    // if either dances a dance, so does the other
+    PairRuleTogether = 1,
+    // never in the same dance
+    PairRuleApart = 2,
+    // never in facing positions of the same dance
+    PairRuleNotFacing = 3,
+} PairRule;
+
+// who calls a dance
+typedef enum
+{
+    // the dance doesn't need a caller
+    CallerRuleNone = 0,
+    // one of the dancers in it
+    CallerRuleFromDancers = 1,
+    // somebody who isn't dancing it
+    CallerRuleStandingOut = 2,
+    // anybody, whether they're dancing it or not
+    CallerRuleAnyone = 3,
+} CallerRule;
+
+// how a model and its response are written out for debugging
+typedef enum
+{
+    // protobuf text format, which can be read and edited
+    DumpFormatText = 0,
+    // binary protobuf, which is smaller
+    DumpFormatBinary = 1,
+} DumpFormat;
@@ -58,9 +58,29 @@ extern "C"
             SolverStatus status;
             int num_assignments;
             int num_dances;
+            // the value of the objective function: higher is better
+            int64_t objective;
             DanceSolutionPriv *priv;
         } DanceSolution;
 
+        typedef struct
+        {
+            int num_solutions;
+            DanceSolution **solutions;
+        } DanceSolutionList;
+
+        // Called with each solution which is better than the ones before it,
+        // while the solver carries on looking. The solution belongs to the
+        // library, and is only valid until the function returns.
+        typedef uintptr_t ObserverHandle;
+        typedef void (*SolutionFunc)(ObserverHandle, DanceSolution *);
+
+        typedef struct
+        {
+            ObserverHandle handle;
+            SolutionFunc on_solution;
+        } solution_observer;
+
         typedef struct Solver Solver;
 
         Solver *dance_solver_new_with_logger(
@@ -69,10 +89,110 @@ extern "C"
             Dance *dances, int num_dances,
             DancerPosition *dancer_positions, int num_dancer_positions);
         void free_dance_solver(Solver *solver);
+        // Bound the number of dances in the set. Pass 0 for either bound to
+        // leave it open; pass the same value for both to ask for exactly that
+        // many dances.
+        void dance_solver_set_num_dances(Solver *solver, int min_dances, int max_dances);
+        // Force a dance to be in (include) or out of (exclude) the set.
+        void dance_solver_include_dance(Solver *solver, int dance_id);
+        void dance_solver_exclude_dance(Solver *solver, int dance_id);
+        // Fix a dancer to a position in a dance. This also forces the dance
+        // into the set.
+        void dance_solver_pin_dancer(Solver *solver, int dancer_id, int dance_id, int position_id);
+        // Bound how many dances a dancer does. 0 means unbounded. If `soft` is
+        // non-zero the bounds can be broken, at a cost.
+        void dance_solver_set_dancer_limits(Solver *solver, int dancer_id, int min_dances, int max_dances, int soft);
+        // Weight a dancer's share of the dances, in quarters: 4 is an even
+        // share, 8 twice that and 2 half of it. It must be positive.
+        void dance_solver_set_dancer_weight(Solver *solver, int dancer_id, int weight);
+        // Add a rule about two dancers. Both should be in the solver's
+        // dancers.
+        void dance_solver_add_pair(Solver *solver, int dancer_id, int other_id, PairRule rule);
+        // Record that two positions of a dance face each other, for
+        // `PairRuleNotFacing`.
+        void dance_solver_set_facing_positions(Solver *solver, int dance_id, int position_id, int other_position_id);
+        // Say who calls a dance, and record that a dancer can call it. A
+        // dance which needs a caller can only be danced if one of them can.
+        void dance_solver_set_dance_caller(Solver *solver, int dance_id, CallerRule rule);
+        void dance_solver_add_caller(Solver *solver, int dancer_id, int dance_id);
+        // Record that a dance is a different sized version of another. At
+        // most one version of a dance is danced, preferring the largest.
+        void dance_solver_set_dance_variant(Solver *solver, int dance_id, int full_dance_id);
+        // Record who danced a position in a previous set. The solver repairs
+        // that set, changing as few assignments as possible.
+        void dance_solver_set_previous_assignment(Solver *solver, int dancer_id, int dance_id, int position_id);
+        // Write the model to `model_path` and the solver's response to
+        // `response_path` when solving, so it can be replayed with
+        // `dance_solver_replay_dump`. Either path can be NULL to skip it.
+        void dance_solver_set_dump(Solver *solver, const char *model_path, const char *response_path, DumpFormat format);
+        // The observer is copied.
+        void dance_solver_set_solution_observer(Solver *solver, solution_observer *observer);
+        // How many threads to search with. 0 lets the solver choose.
+        void dance_solver_set_num_workers(Solver *solver, int num_workers);
+        // Stop a solve which is running in another thread, keeping the best
+        // solution found so far. Its status is `SolverStatusCancelled`, unless
+        // it was already optimal.
+        void dance_solver_stop(Solver *solver);
         DanceSolution *get_possible_dances(Solver *solver);
         void free_dance_solution(DanceSolution *solution);
+        // Find up to `num_alternatives` sets, best first. Each one differs
+        // from all of the ones before it by at least `min_difference` dances
+        // or assignments. Free with `free_dance_solution_list`.
+        DanceSolutionList *get_alternative_dances(
+            Solver *solver,
+            int num_alternatives,
+            int min_difference,
+            AlternativeDifference difference);
+        void free_dance_solution_list(DanceSolutionList *list);
+        // Solve several events together. Add the events in the order they
+        // happen with `season_solver_add_event`, then solve to get one solution
+        // per event.
+        typedef struct SeasonSolver SeasonSolver;
+
+        SeasonSolver *season_solver_new_with_logger(
+            logger *l,
+            Dance *dances, int num_dances,
+            DancerPosition *dancer_positions, int num_dancer_positions);
+        void free_season_solver(SeasonSolver *solver);
+        // Returns the index of the event.
+        int season_solver_add_event(SeasonSolver *solver, Dancer *dancers, int num_dancers);
+        // Bound the number of dances at each event, as for
+        // `dance_solver_set_num_dances`.
+        void season_solver_set_num_dances(SeasonSolver *solver, int min_dances, int max_dances);
+        // As for `dance_solver_set_dance_caller`, at every event.
+        void season_solver_set_dance_caller(SeasonSolver *solver, int dance_id, CallerRule rule);
+        // As for `dance_solver_add_caller`, at one event. Only add the
+        // callers who are at it.
+        void season_solver_add_caller(SeasonSolver *solver, int event, int dancer_id, int dance_id);
+        // As for `dance_solver_set_dance_variant`, at every event.
+        void season_solver_set_dance_variant(SeasonSolver *solver, int dance_id, int full_dance_id);
+        // As for `dance_solver_set_dump`.
+        void season_solver_set_dump(SeasonSolver *solver, const char *model_path, const char *response_path, DumpFormat format);
+        // As for `dance_solver_set_num_workers`.
+        void season_solver_set_num_workers(SeasonSolver *solver, int num_workers);
+        // As for `dance_solver_stop`.
+        void season_solver_stop(SeasonSolver *solver);
+        // Free with `free_dance_solution_list`.
+        DanceSolutionList *season_solver_solve(SeasonSolver *solver);
+
+        typedef struct
+        {
+            SolverStatus status;
+            int64_t objective;
+            // the solver's summary of the response
+            char *stats;
+        } DumpReplay;
+
+        // Solve a model written out by `dance_solver_set_dump` again, writing
+        // the new response to `response_path` if it isn't NULL. Returns NULL
+        // if the model can't be read. Free with `free_dump_replay`.
+        DumpReplay *dance_solver_replay_dump(logger *l, const char *model_path, const char *response_path, DumpFormat format);
+        void free_dump_replay(DumpReplay *replay);
+
         int get_dancer_dance_position(DanceSolution *solution, int dance_id, int position_id);
         int is_dance_performed(DanceSolution *solution, int dance_id);
+        // The dancer calling a dance, or -1 if nobody is.
+        int get_dance_caller(DanceSolution *solution, int dance_id);
 
 #ifdef __cplusplus
     } // namespace dance_solver_c_api
@@ -1,8 +1,12 @@
 #pragma once
 
+#include <atomic>
+#include <functional>
 #include <map>
 #include <memory>
+#include <optional>
 #include <ranges>
+#include <string>
 #include <vector>
 
 #define DANCE_SOLVER_INTERNAL_INCLUDE
@@ -22,6 +26,32 @@
 #define PREFERENCE_YES_WEIGHT 2
 #define PREFERENCE_FAVOURITE_WEIGHT 3
 
+// cost of each dance a soft per-dancer limit is missed by. this is more than a
+// dance is worth, so limits are only broken when there's no other way.
+#define DANCER_LIMIT_WEIGHT 5
+
+// cost of each assignment which differs from the previous set when repairing
+// it. this outweighs anything a single assignment can gain, so the set is only
+// changed where it has to be.
+#define CHANGE_WEIGHT 10
+
+// cost of each dancer fewer a smaller version of a dance has than the largest.
+// this is as much as a favourite position, so the full version is danced
+// unless the smaller one is much better liked.
+#define VARIANT_WEIGHT 3
+
+// dancer weights are in quarters. a dancer with this weight gets the usual
+// share of dances, one with twice it should get twice as many, and so on.
+#define DANCER_WEIGHT_UNITS 4
+
+// season weights. the fairness ones are per dance (or favourite position) of
+// difference between the dancers who have had the most and the least, per event
+// they've been to. repeating a dance at consecutive events costs about as much
+// as one yes, so a dance is swapped for another one rather than dropped.
+#define SEASON_FAIRNESS_WEIGHT 1
+#define SEASON_FAVOURITE_FAIRNESS_WEIGHT 1
+#define REPEAT_WEIGHT 2
+
 struct Dancer
 {
     int ID;
@@ -83,6 +113,37 @@ struct DancerPosition
     DancerPosition &operator=(const dance_solver_c_api::DancerPosition &dancer_position);
 };
 
+// how many dances a dancer should do, 0 means unbounded
+struct DancerLimit
+{
+    int MinDances;
+    int MaxDances;
+    bool Soft;
+};
+
+// where to write the model and the solver's response, for replaying later.
+// empty paths aren't written.
+struct DumpOptions
+{
+    std::string ModelPath;
+    std::string ResponsePath;
+    DumpFormat Format;
+};
+
+// the result of solving a dumped model again
+struct ReplaySolution
+{
+    SolverStatus status;
+    int64_t objective;
+    // the solver's summary of the response
+    std::string stats;
+};
+
+// Solve a model written out with `DumpOptions` again. The model can be in
+// either format. The new response is written to `dump.ResponsePath`, if it's
+// set; `dump.ModelPath` is ignored. Returns nothing if the model can't be read.
+const std::optional<ReplaySolution> ReplayDump(logger *l, const std::string &model_path, const DumpOptions &dump);
+
 struct PositionSolution
 {
     int dance_id;
@@ -104,24 +165,121 @@ public:
     // dance_id -> bool
     typedef std::map<DanceID, bool> DancesPerformed;
 
+    // dance_id -> dancer_id of whoever is calling it
+    typedef std::map<DanceID, DancerID> DanceCallers;
+
     struct DanceSolution
     {
         const SolverStatus status;
         const int num_assignments;
         const DancesPerformed dance_performed;
         const SolutionAssignment assignment;
+        const int64_t objective;
+        // only for the dances which need a caller
+        const DanceCallers callers;
     };
 
+    // called with each solution which is better than the ones before it,
+    // while the solver carries on looking
+    typedef std::function<void(const DanceSolution &)> SolutionObserver;
+
     DanceSolver(
         logger *l,
         std::vector<Dancer> &dancers,
         std::vector<Dance> &dances,
         std::vector<DancerPosition> &dancer_positions);
     ~DanceSolver();
+
+    // 0 means "no bound" for either side
+    void SetNumDances(int min_dances, int max_dances);
+    void IncludeDance(DanceID dance_id);
+    void ExcludeDance(DanceID dance_id);
+    void PinDancer(DancerID dancer_id, DanceID dance_id, PositionID position_id);
+    void SetDancerLimits(DancerID dancer_id, DancerLimit limit);
+    // How many dances a dancer should get compared to everybody else, in
+    // quarters. The default is `DANCER_WEIGHT_UNITS`, an even share.
+    void SetDancerWeight(DancerID dancer_id, int weight);
+    // Add a rule about two dancers. Both should be in the solver's dancers.
+    void AddPair(DancerID dancer_id, DancerID other_id, PairRule rule);
+    // Record that two positions of a dance face each other, so their dancers
+    // are partners. Only `PairRuleNotFacing` uses them.
+    void SetFacingPositions(DanceID dance_id, PositionID position_id, PositionID other_position_id);
+    // Say who calls a dance. Every dance which is danced and needs a caller
+    // gets one of the dancers added with `AddCaller` for it.
+    void SetDanceCaller(DanceID dance_id, CallerRule rule);
+    void AddCaller(DancerID dancer_id, DanceID dance_id);
+    // Record that a dance is a different sized version of `full_dance_id`. At
+    // most one version of a dance is danced, preferring the largest.
+    void SetDanceVariant(DanceID dance_id, DanceID full_dance_id);
+    // Record an assignment from a previous set. If there are any, the solver
+    // starts from that set and changes as little of it as it can.
+    void SetPreviousAssignment(DancerID dancer_id, DanceID dance_id, PositionID position_id);
+    // Write the model and the response out when solving. Finding alternatives
+    // solves more than once, and each solve overwrites the files.
+    void SetDump(const DumpOptions &dump);
+    // Finding alternatives reports the solutions found while looking for each
+    // one.
+    void SetSolutionObserver(SolutionObserver observer);
+    // How many threads CP-SAT searches with. 0, the default, lets it choose,
+    // which is one per core. Lower it when running several solvers at once.
+    void SetNumWorkers(int num_workers);
+    // Stop solving as soon as possible. This is safe to call from another
+    // thread while solving. The solution is the best found so far, with the
+    // status `SolverStatusCancelled`, unless it had already been proved
+    // optimal. Any alternatives still to be found are skipped.
+    void Stop();
+
+    // Each solver should be used for one call to one of these.
     const DanceSolution GetPossibleDances();
+    const std::vector<DanceSolution> GetAlternativeDances(
+        int num_alternatives,
+        int min_difference,
+        AlternativeDifference difference);
 
 private:
     // hide the or-tools dependency
     class DanceSolverImpl;
     std::unique_ptr<DanceSolverImpl> pimpl_;
+
+    // a season is made of one of our models per event
+    friend class SeasonSolver;
+};
+
+// Solves several events at once, so dances and favourite positions can be
+// shared out fairly over the whole season, and the same dances aren't danced at
+// every event.
+class SeasonSolver
+{
+public:
+    SeasonSolver(
+        logger *l,
+        std::vector<Dance> &dances,
+        std::vector<DancerPosition> &dancer_positions);
+    ~SeasonSolver();
+
+    // Add an event attended by `dancers`, returning its index. Events should be
+    // added in the order they happen.
+    int AddEvent(std::vector<Dancer> &dancers);
+    // Bound the number of dances at each event. 0 means "no bound".
+    void SetNumDances(int min_dances, int max_dances);
+    // As for `DanceSolver::SetDanceCaller`, at every event.
+    void SetDanceCaller(int dance_id, CallerRule rule);
+    // As for `DanceSolver::AddCaller`, at the event with index `event`.
+    void AddCaller(int event, int dancer_id, int dance_id);
+    // As for `DanceSolver::SetDanceVariant`, at every event.
+    void SetDanceVariant(int dance_id, int full_dance_id);
+    // Write the model and the response out when solving.
+    void SetDump(const DumpOptions &dump);
+    // As for `DanceSolver::SetNumWorkers`.
+    void SetNumWorkers(int num_workers);
+    // As for `DanceSolver::Stop`.
+    void Stop();
+
+    // One solution per event, in the order they were added. Each solver should
+    // only be solved once.
+    const std::vector<DanceSolver::DanceSolution> Solve();
+
+private:
+    class SeasonSolverImpl;
+    std::unique_ptr<SeasonSolverImpl> pimpl_;
 };
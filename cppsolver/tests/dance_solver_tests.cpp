@@ -1,5 +1,8 @@
 #include <catch2/catch.hpp>
 
+#include <algorithm>
+#include <filesystem>
+
 #include "dance_solver.hpp"
 #include "testlogger.h"
 
@@ -352,3 +355,654 @@ TEST_CASE("Real world")
 
     free_test_logger(logger);
 }
+
+TEST_CASE("Maximum set length: the preferred dance makes the cut", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceMaybe},
+        {1, 1, 2, PreferenceFavourite}};
+
+    auto logger = new_test_logger();
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    solver.SetNumDances(0, 1);
+
+    auto solution = solver.GetPossibleDances();
+    REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+    REQUIRE(solution.num_assignments == 1);
+
+    auto dances_performed = solution.dance_performed;
+    REQUIRE(dances_performed.size() == 2);
+    REQUIRE(!dances_performed[1]);
+    REQUIRE(dances_performed[2]);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Exact set length which can't be reached is infeasible", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}},
+        {2, {{1}, {2}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes}};
+
+    auto logger = new_test_logger();
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    solver.SetNumDances(2, 2);
+
+    auto solution = solver.GetPossibleDances();
+    REQUIRE(solution.status == SolverStatus::SolverStatusInfeasible);
+    REQUIRE(solution.num_assignments == 0);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Included, excluded and pinned dances", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}}},
+        {2, {{1}}},
+        {3, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {1, 2, 1, PreferenceFavourite},
+        {2, 1, 1, PreferenceFavourite},
+        {2, 2, 1, PreferenceYes},
+        {1, 1, 2, PreferenceFavourite},
+        {2, 1, 2, PreferenceFavourite},
+        {1, 1, 3, PreferenceMaybe}};
+
+    auto logger = new_test_logger();
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    solver.SetNumDances(0, 2);
+    solver.ExcludeDance(2);
+    solver.IncludeDance(3);
+    // against their preferences
+    solver.PinDancer(1, 1, 1);
+
+    auto solution = solver.GetPossibleDances();
+    REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+    auto dances_performed = solution.dance_performed;
+    REQUIRE(dances_performed[1]);
+    REQUIRE(!dances_performed[2]);
+    REQUIRE(dances_performed[3]);
+
+    auto assignment = solution.assignment;
+    REQUIRE(assignment[1][1] == 1);
+    REQUIRE(assignment[1][2] == 2);
+    REQUIRE(assignment[3][1] == 1);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Per-dancer limits", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceFavourite},
+        {1, 1, 2, PreferenceFavourite},
+        {2, 1, 1, PreferenceMaybe},
+        {2, 1, 2, PreferenceMaybe}};
+
+    auto logger = new_test_logger();
+
+    SECTION("hard minimum")
+    {
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+        solver.SetDancerLimits(2, {2, 0, false});
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+        auto assignment = solution.assignment;
+        REQUIRE(assignment[1][1] == 2);
+        REQUIRE(assignment[2][1] == 2);
+    }
+
+    SECTION("impossible hard minimum")
+    {
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+        solver.SetDancerLimits(2, {3, 0, false});
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusInfeasible);
+    }
+
+    SECTION("impossible soft minimum")
+    {
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+        solver.SetDancerLimits(2, {3, 0, true});
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+        auto assignment = solution.assignment;
+        REQUIRE(assignment[1][1] == 2);
+        REQUIRE(assignment[2][1] == 2);
+    }
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Alternative sets differ by dances", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {1, 1, 2, PreferenceYes}};
+
+    auto logger = new_test_logger();
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+
+    // there are only three sets: both dances, or either one of them
+    auto solutions = solver.GetAlternativeDances(5, 1, AlternativeDifference::AlternativeDifferenceDances);
+    REQUIRE(solutions.size() == 3);
+
+    auto best = solutions[0].dance_performed;
+    REQUIRE(best[1]);
+    REQUIRE(best[2]);
+
+    REQUIRE(solutions[0].objective >= solutions[1].objective);
+    REQUIRE(solutions[1].objective >= solutions[2].objective);
+
+    auto second = solutions[1].dance_performed;
+    auto third = solutions[2].dance_performed;
+    REQUIRE(second[1] != third[1]);
+    REQUIRE(second[2] != third[2]);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Repairing a set keeps as much of it as possible", "[dance_solver]")
+{
+    // dancer 2 has dropped out
+    std::vector<Dancer> dancers = {{1, true}, {2, false}, {3, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {1, 2, 1, PreferenceYes},
+        {2, 2, 1, PreferenceYes},
+        {3, 1, 1, PreferenceFavourite},
+        {3, 2, 1, PreferenceYes}};
+
+    auto logger = new_test_logger();
+
+    SECTION("from scratch")
+    {
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+        auto assignment = solution.assignment;
+        REQUIRE(assignment[1][1] == 3);
+        REQUIRE(assignment[1][2] == 1);
+    }
+
+    SECTION("repairing")
+    {
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+        solver.SetPreviousAssignment(1, 1, 1);
+        solver.SetPreviousAssignment(2, 1, 2);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+        // dancer 3 would rather dance position 1, but that would move dancer 1
+        auto assignment = solution.assignment;
+        REQUIRE(assignment[1][1] == 1);
+        REQUIRE(assignment[1][2] == 3);
+    }
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Dumped models replay to the same solution", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {2, 1, 1, PreferenceMaybe},
+        {2, 1, 2, PreferenceFavourite}};
+
+    auto logger = new_test_logger();
+
+    const auto format = GENERATE(DumpFormat::DumpFormatText, DumpFormat::DumpFormatBinary);
+    const auto dir = std::filesystem::temp_directory_path();
+    const auto model_path = (dir / "dance_solver_test_model").string();
+    const auto response_path = (dir / "dance_solver_test_response").string();
+
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    solver.SetDump({model_path, "", format});
+
+    auto solution = solver.GetPossibleDances();
+    REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+    REQUIRE(std::filesystem::exists(model_path));
+
+    auto replay = ReplayDump(logger, model_path, {"", response_path, format});
+    REQUIRE(replay.has_value());
+    REQUIRE(replay->status == solution.status);
+    REQUIRE(replay->objective == solution.objective);
+    REQUIRE(std::filesystem::exists(response_path));
+
+    std::filesystem::remove(model_path);
+    std::filesystem::remove(response_path);
+
+    REQUIRE_FALSE(ReplayDump(logger, model_path, {}).has_value());
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("The solution observer sees each improving solution", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}, {3, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {2, 1, 1, PreferenceMaybe},
+        {2, 2, 1, PreferenceFavourite},
+        {3, 2, 1, PreferenceYes},
+        {3, 1, 2, PreferenceYes}};
+
+    auto logger = new_test_logger();
+
+    std::vector<int64_t> objectives;
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    const auto observer = [&objectives](const DanceSolver::DanceSolution &solution)
+    {
+        REQUIRE(solution.status != SolverStatus::SolverStatusUnknown);
+        REQUIRE(solution.num_assignments > 0);
+        objectives.push_back(solution.objective);
+    };
+    solver.SetSolutionObserver(observer);
+
+    auto solution = solver.GetPossibleDances();
+    REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+    REQUIRE_FALSE(objectives.empty());
+    REQUIRE(std::is_sorted(objectives.begin(), objectives.end()));
+    REQUIRE(objectives.back() == solution.objective);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Stopping the solver keeps the best solution so far", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}, {3, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}}},
+        {2, {{1}}},
+        {3, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {2, 2, 1, PreferenceYes},
+        {3, 1, 2, PreferenceMaybe},
+        {1, 1, 3, PreferenceFavourite},
+        {2, 1, 3, PreferenceYes},
+        {3, 1, 3, PreferenceYes}};
+
+    auto logger = new_test_logger();
+
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    const auto stop = [&solver](const DanceSolver::DanceSolution &)
+    {
+        solver.Stop();
+    };
+    solver.SetSolutionObserver(stop);
+
+    SECTION("one set")
+    {
+        auto solution = solver.GetPossibleDances();
+
+        // it might have finished before it noticed
+        REQUIRE((solution.status == SolverStatus::SolverStatusCancelled || solution.status == SolverStatus::SolverStatusOptimal));
+        REQUIRE(solution.num_assignments > 0);
+    }
+
+    SECTION("alternatives")
+    {
+        auto solutions = solver.GetAlternativeDances(3, 1, AlternativeDifference::AlternativeDifferenceDances);
+
+        REQUIRE(solutions.size() == 1);
+        REQUIRE(solutions[0].num_assignments > 0);
+    }
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("The number of workers doesn't change the solution", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {2, 2, 1, PreferenceYes},
+        {1, 1, 2, PreferenceMaybe},
+        {2, 1, 2, PreferenceFavourite}};
+
+    auto logger = new_test_logger();
+
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    solver.SetNumWorkers(GENERATE(0, 1, 4));
+
+    auto solution = solver.GetPossibleDances();
+
+    REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+    REQUIRE(solution.num_assignments == 3);
+
+    auto assignment = solution.assignment;
+    REQUIRE(assignment[2][1] == 2);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Weighted dancers get a proportional share", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}},
+        {2, {{1}}},
+        {3, {{1}}}};
+    std::vector<DancerPosition> dancer_positions;
+    for (int dance_id = 1; dance_id <= 3; dance_id++)
+    {
+        dancer_positions.push_back({1, 1, dance_id, PreferenceYes});
+        dancer_positions.push_back({2, 1, dance_id, PreferenceYes});
+    }
+
+    auto logger = new_test_logger();
+
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    const auto weighted = GENERATE(1, 2);
+    // twice the usual share
+    solver.SetDancerWeight(weighted, 2 * DANCER_WEIGHT_UNITS);
+
+    auto solution = solver.GetPossibleDances();
+    REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+    REQUIRE(solution.num_assignments == 3);
+
+    int weighted_dances = 0;
+    for (const auto &[dance_id, positions] : solution.assignment)
+    {
+        for (const auto &[position_id, dancer_id] : positions)
+        {
+            if (dancer_id == weighted)
+            {
+                weighted_dances++;
+            }
+        }
+    }
+    REQUIRE(weighted_dances == 2);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Pair rules", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}, {3, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}, {3}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceFavourite},
+        {3, 2, 1, PreferenceFavourite},
+        {3, 3, 1, PreferenceYes},
+        {2, 2, 1, PreferenceYes},
+        {2, 3, 1, PreferenceFavourite},
+        {2, 1, 2, PreferenceMaybe}};
+
+    auto logger = new_test_logger();
+
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+    // positions 1 and 2 of dance 1 face each other
+    solver.SetFacingPositions(1, 1, 2);
+
+    SECTION("Not facing")
+    {
+        solver.AddPair(1, 3, PairRule::PairRuleNotFacing);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+        auto assignment = solution.assignment;
+        REQUIRE(assignment[1][1] == 1);
+        REQUIRE(assignment[1][2] == 2);
+        REQUIRE(assignment[1][3] == 3);
+    }
+
+    SECTION("Apart")
+    {
+        solver.AddPair(1, 3, PairRule::PairRuleApart);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+        REQUIRE(!solution.dance_performed.at(1));
+        REQUIRE(solution.dance_performed.at(2));
+    }
+
+    SECTION("Together")
+    {
+        // dancer 1 can't dance dance 2, so dancer 2 can't either
+        solver.AddPair(1, 2, PairRule::PairRuleTogether);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+        REQUIRE(solution.dance_performed.at(1));
+        REQUIRE(!solution.dance_performed.at(2));
+    }
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Callers", "[dance_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}, {3, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceFavourite},
+        {2, 2, 1, PreferenceFavourite},
+        {3, 2, 1, PreferenceMaybe},
+        {2, 1, 2, PreferenceYes}};
+
+    auto logger = new_test_logger();
+
+    DanceSolver solver(logger, dancers, dances, dancer_positions);
+
+    SECTION("From the dancers")
+    {
+        solver.SetDanceCaller(1, CallerRule::CallerRuleFromDancers);
+        solver.AddCaller(3, 1);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+        auto assignment = solution.assignment;
+        REQUIRE(assignment[1][2] == 3);
+        REQUIRE(solution.callers.at(1) == 3);
+        // dance 2 doesn't need a caller
+        REQUIRE(!solution.callers.contains(2));
+    }
+
+    SECTION("Standing out")
+    {
+        solver.SetDanceCaller(1, CallerRule::CallerRuleStandingOut);
+        // dancer 1 has to dance position 1, so can't call it
+        solver.AddCaller(1, 1);
+        solver.AddCaller(3, 1);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+
+        auto assignment = solution.assignment;
+        REQUIRE(assignment[1][2] == 2);
+        REQUIRE(solution.callers.at(1) == 3);
+    }
+
+    SECTION("Nobody can call it")
+    {
+        solver.SetDanceCaller(1, CallerRule::CallerRuleAnyone);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+        REQUIRE(!solution.dance_performed.at(1));
+        REQUIRE(solution.dance_performed.at(2));
+    }
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Only one version of a dance is danced", "[dance_solver]")
+{
+    // dance 2 is the two person version of dance 1
+    std::vector<Dance> dances = {
+        {1, {{1}, {2}, {3}}},
+        {2, {{1}, {2}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {2, 2, 1, PreferenceYes},
+        {3, 3, 1, PreferenceYes},
+        {1, 1, 2, PreferenceFavourite},
+        {2, 2, 2, PreferenceFavourite}};
+
+    auto logger = new_test_logger();
+
+    SECTION("The largest version is preferred")
+    {
+        std::vector<Dancer> dancers = {{1, true}, {2, true}, {3, true}};
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+        solver.SetDanceVariant(2, 1);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+        REQUIRE(solution.dance_performed.at(1));
+        REQUIRE(!solution.dance_performed.at(2));
+    }
+
+    SECTION("A smaller version when there aren't enough dancers")
+    {
+        std::vector<Dancer> dancers = {{1, true}, {2, true}, {3, false}};
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+        solver.SetDanceVariant(2, 1);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+        REQUIRE(!solution.dance_performed.at(1));
+        REQUIRE(solution.dance_performed.at(2));
+    }
+
+    SECTION("Unrelated dances are both danced")
+    {
+        std::vector<Dancer> dancers = {{1, true}, {2, true}, {3, true}};
+        DanceSolver solver(logger, dancers, dances, dancer_positions);
+
+        auto solution = solver.GetPossibleDances();
+        REQUIRE(solution.status == SolverStatus::SolverStatusOptimal);
+        REQUIRE(solution.dance_performed.at(1));
+        REQUIRE(solution.dance_performed.at(2));
+    }
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Season shares dances out over the events", "[season_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}, {2, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {2, 1, 1, PreferenceYes}};
+
+    auto logger = new_test_logger();
+    SeasonSolver solver(logger, dances, dancer_positions);
+    REQUIRE(solver.AddEvent(dancers) == 0);
+    REQUIRE(solver.AddEvent(dancers) == 1);
+
+    auto solutions = solver.Solve();
+    REQUIRE(solutions.size() == 2);
+    REQUIRE(solutions[0].status == SolverStatus::SolverStatusOptimal);
+
+    // repeating the dance is better than not dancing it at all, but each
+    // dancer should get a turn
+    auto first = solutions[0].assignment;
+    auto second = solutions[1].assignment;
+    REQUIRE(solutions[0].dance_performed.at(1));
+    REQUIRE(solutions[1].dance_performed.at(1));
+    REQUIRE(first[1][1] != second[1][1]);
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Season rotates the repertoire", "[season_solver]")
+{
+    std::vector<Dancer> dancers = {{1, true}};
+    std::vector<Dance> dances = {
+        {1, {{1}}},
+        {2, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes},
+        {1, 1, 2, PreferenceYes}};
+
+    auto logger = new_test_logger();
+    SeasonSolver solver(logger, dances, dancer_positions);
+    solver.AddEvent(dancers);
+    solver.AddEvent(dancers);
+    solver.SetNumDances(1, 1);
+
+    auto solutions = solver.Solve();
+    REQUIRE(solutions.size() == 2);
+    REQUIRE(solutions[0].status == SolverStatus::SolverStatusOptimal);
+    REQUIRE(solutions[0].dance_performed.at(1) != solutions[1].dance_performed.at(1));
+
+    free_test_logger(logger);
+}
+
+TEST_CASE("Season callers are only at their own events", "[season_solver]")
+{
+    std::vector<Dance> dances = {
+        {1, {{1}}}};
+    std::vector<DancerPosition> dancer_positions = {
+        {1, 1, 1, PreferenceYes}};
+
+    auto logger = new_test_logger();
+    SeasonSolver solver(logger, dances, dancer_positions);
+    std::vector<Dancer> with_caller = {{1, true}, {2, true}};
+    std::vector<Dancer> without_caller = {{1, true}};
+    solver.AddEvent(with_caller);
+    solver.AddEvent(without_caller);
+    solver.SetDanceCaller(1, CallerRule::CallerRuleStandingOut);
+    solver.AddCaller(0, 2, 1);
+
+    auto solutions = solver.Solve();
+    REQUIRE(solutions.size() == 2);
+    REQUIRE(solutions[0].status == SolverStatus::SolverStatusOptimal);
+    REQUIRE(solutions[0].dance_performed.at(1));
+    REQUIRE(solutions[0].callers.at(1) == 2);
+    // nobody can call it at the second event
+    REQUIRE(!solutions[1].dance_performed.at(1));
+
+    free_test_logger(logger);
+}
@@ -76,14 +76,39 @@ func LogError(lh C.LoggerHandle, msg *C.cchar_t) {
 	log(lh, logrus.ErrorLevel, msg)
 }
 
-func PopulateLogger(loggerHandle cgo.Handle) *C.logger {
+// Logger is a C logger which passes its messages on to a logrus entry.
+//
+// It's safe for the C library to log from several threads at once: the handle
+// is only read, and logrus entries can be logged to concurrently.
+type Logger struct {
+	handle cgo.Handle
+	logger *C.logger
+}
+
+// NewLogger makes a C logger for `entry`. It must be freed with `Free` once the
+// C library has finished with it.
+func NewLogger(entry *logrus.Entry) Logger {
+	handle := cgo.NewHandle(entry)
+
 	logger := (*C.logger)(C.malloc(C.sizeof_logger))
-	logger.handle = C.uintptr_t(loggerHandle)
+	logger.handle = C.uintptr_t(handle)
 	logger.log_trace = (C.LogFunc)(C.LogTrace)
 	logger.log_debug = (C.LogFunc)(C.LogDebug)
 	logger.log_info = (C.LogFunc)(C.LogInfo)
 	logger.log_warn = (C.LogFunc)(C.LogWarn)
 	logger.log_error = (C.LogFunc)(C.LogError)
 
-	return logger
+	return Logger{handle, logger}
+}
+
+// C returns the `logger *` to pass to the C library. Each package has its own C
+// types, so it has to be converted: `(*C.logger)(l.C())`.
+func (l Logger) C() unsafe.Pointer {
+	return unsafe.Pointer(l.logger)
+}
+
+// Free frees the C struct and the handle to the logrus entry.
+func (l Logger) Free() {
+	C.free(unsafe.Pointer(l.logger))
+	l.handle.Delete()
 }
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// migrate brings an existing database up to date with the tables and columns
+// which have been added since it was made. Tables and columns are only ever
+// added: nothing which is already there is changed, which AutoMigrate can do
+// to the tables we started with, and to those they're related to.
+func migrate(db *gorm.DB) error {
+	migrator := db.Migrator()
+
+	// tables which didn't exist to start with
+	for _, table := range []interface{}{&Event{}, &Attendance{}, &DancerPair{}, &DancerCaller{}} {
+		if !migrator.HasTable(table) {
+			if err := migrator.CreateTable(table); err != nil {
+				return fmt.Errorf("can't migrate database: %w", err)
+			}
+			continue
+		}
+
+		// made by an older version, so it might be missing columns
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(table); err != nil {
+			return fmt.Errorf("can't migrate database: %w", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if err := addColumn(migrator, table, field.DBName); err != nil {
+				return err
+			}
+		}
+	}
+
+	// columns added to the tables we started with
+	columns := []struct {
+		model interface{}
+		field string
+	}{
+		{&Position{}, "facing"},
+		{&Dance{}, "caller"},
+		{&Dance{}, "variant_of"},
+	}
+	for _, column := range columns {
+		if err := addColumn(migrator, column.model, column.field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addColumn adds `column` to the table for `model` if it isn't there already.
+func addColumn(migrator gorm.Migrator, model interface{}, column string) error {
+	if column == "" || migrator.HasColumn(model, column) {
+		return nil
+	}
+
+	if err := migrator.AddColumn(model, column); err != nil {
+		return fmt.Errorf("can't migrate database: %w", err)
+	}
+
+	return nil
+}
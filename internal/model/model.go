@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/exp/maps"
@@ -102,9 +103,12 @@ type Dancer struct {
 }
 
 type Position struct {
-	PositionID      int `gorm:"column:position;primaryKey"`
-	Name            string
-	DanceID         int               `gorm:"column:dance;primaryKey"`
+	PositionID int `gorm:"column:position;primaryKey"`
+	Name       string
+	DanceID    int `gorm:"column:dance;primaryKey"`
+	// FacingID is the position which faces this one, so whoever dances it is
+	// this position's partner. 0 if there isn't one.
+	FacingID        int               `gorm:"column:facing;default:0"`
 	Dance           *Dance            `gorm:"foreignKey:DanceID"`
 	DancerPositions []*DancerPosition `gorm:"foreignKey:DanceID,PositionID;references:DanceID,PositionID"`
 }
@@ -118,7 +122,91 @@ type Dance struct {
 	Active    bool
 	Name      string
 	Note      string
+	Caller    CallerRule  `gorm:"column:caller;type:integer;default:0"`
 	Positions []*Position `gorm:"foreignKey:DanceID"`
+	// VariantOf is the dance this is a different sized version of, e.g. the
+	// four person version of a six person dance. 0 if it isn't a version of
+	// another dance.
+	VariantOf int `gorm:"column:variant_of;default:0"`
+}
+
+// VersionOf returns the ID shared by every version of the dance: the ID of the
+// dance it's a variant of, or its own ID if it isn't a variant.
+func (d *Dance) VersionOf() int {
+	if d.VariantOf != 0 {
+		return d.VariantOf
+	}
+
+	return d.ID
+}
+
+// CallerRule says who calls a dance.
+type CallerRule int
+
+const (
+	// CallerNone means the dance doesn't need a caller.
+	CallerNone CallerRule = 0
+	// CallerFromDancers means one of the dancers in the dance calls it,
+	// usually whoever is in position 1.
+	CallerFromDancers CallerRule = 1
+	// CallerStandingOut means somebody who isn't dancing it calls it.
+	CallerStandingOut CallerRule = 2
+	// CallerAnyone means anybody who can call it does, dancing it or not.
+	CallerAnyone CallerRule = 3
+)
+
+func (r *CallerRule) Scan(value interface{}) error {
+	// dances from before callers don't need one
+	if value == nil {
+		*r = CallerNone
+		return nil
+	}
+
+	switch value.(int64) {
+	case 0:
+		*r = CallerNone
+	case 1:
+		*r = CallerFromDancers
+	case 2:
+		*r = CallerStandingOut
+	case 3:
+		*r = CallerAnyone
+	default:
+		return fmt.Errorf("invalid caller rule value: %v", value)
+	}
+	return nil
+}
+
+func (r CallerRule) Value() (interface{}, error) {
+	return int64(r), nil
+}
+
+func (r CallerRule) String() string {
+	switch r {
+	case CallerNone:
+		return "none"
+	case CallerFromDancers:
+		return "from the dancers"
+	case CallerStandingOut:
+		return "standing out"
+	case CallerAnyone:
+		return "anyone"
+	default:
+		return "unknown"
+	}
+}
+
+// DancerCaller records that a dancer can call a dance.
+type DancerCaller struct {
+	DancerID int `gorm:"column:dancer;primaryKey"`
+	DanceID  int `gorm:"column:dance;primaryKey"`
+
+	Dancer *Dancer `gorm:"foreignKey:DancerID"`
+	Dance  *Dance  `gorm:"foreignKey:DanceID"`
+}
+
+func (DancerCaller) TableName() string {
+	return "dance_callers"
 }
 
 type DancerPosition struct {
@@ -140,13 +228,137 @@ func (dp DancerPosition) String() string {
 	return fmt.Sprintf("%s: %s: %s (%s)", dp.Dance.Name, dp.Dancer.Name, dp.Position.Name, dp.Preference)
 }
 
+// PairRule is a rule about two dancers being in the same dance.
+type PairRule int
+
+const (
+	// PairTogether means that if either dancer dances a dance, so does the
+	// other, for example a learner and whoever is teaching them.
+	PairTogether PairRule = 1
+	// PairApart means the dancers are never in the same dance.
+	PairApart PairRule = 2
+	// PairNotFacing means the dancers can be in the same dance, but not as
+	// partners in facing positions.
+	PairNotFacing PairRule = 3
+)
+
+func (r *PairRule) Scan(value interface{}) error {
+	if value == nil {
+		return fmt.Errorf("invalid pair rule value: %v", value)
+	}
+
+	switch value.(int64) {
+	case 1:
+		*r = PairTogether
+	case 2:
+		*r = PairApart
+	case 3:
+		*r = PairNotFacing
+	default:
+		return fmt.Errorf("invalid pair rule value: %v", value)
+	}
+	return nil
+}
+
+func (r PairRule) Value() (interface{}, error) {
+	return int64(r), nil
+}
+
+func (r PairRule) String() string {
+	switch r {
+	case PairTogether:
+		return "together"
+	case PairApart:
+		return "apart"
+	case PairNotFacing:
+		return "not facing"
+	default:
+		return "unknown"
+	}
+}
+
+// DancerPair is a rule about two dancers. The rules are symmetric, so it
+// doesn't matter which way round the dancers are.
+type DancerPair struct {
+	DancerID int      `gorm:"column:dancer;primaryKey"`
+	OtherID  int      `gorm:"column:other;primaryKey"`
+	Rule     PairRule `gorm:"type:integer;primaryKey"`
+
+	Dancer *Dancer `gorm:"foreignKey:DancerID"`
+	Other  *Dancer `gorm:"foreignKey:OtherID"`
+}
+
+func (DancerPair) TableName() string {
+	return "dancer_pairs"
+}
+
+// Event is a dance-out, practice, or anything else people turn up to.
+type Event struct {
+	ID          int
+	Name        string
+	Date        time.Time
+	Attendances []*Attendance `gorm:"foreignKey:EventID"`
+}
+
+// Attendance records a dancer being at an event, along with anything
+// particular to them on the day.
+type Attendance struct {
+	EventID  int `gorm:"column:event;primaryKey"`
+	DancerID int `gorm:"column:dancer;primaryKey"`
+
+	Event  *Event  `gorm:"foreignKey:EventID"`
+	Dancer *Dancer `gorm:"foreignKey:DancerID"`
+
+	// MinDances and MaxDances bound how many dances the dancer does at the
+	// event. 0 means unbounded. If SoftLimits is set, they're preferences
+	// rather than hard rules.
+	MinDances  int
+	MaxDances  int
+	SoftLimits bool
+
+	// Weight is how many dances the dancer should get compared to everybody
+	// else: 2 for twice as many, 0.5 for half. 0 means an even share.
+	Weight float64
+}
+
+func (Attendance) TableName() string {
+	return "attendance"
+}
+
+// Dancers returns the dancers at the event.
+func (e *Event) Dancers() []*Dancer {
+	dancers := make([]*Dancer, 0, len(e.Attendances))
+	for _, attendance := range e.Attendances {
+		dancers = append(dancers, attendance.Dancer)
+	}
+
+	return dancers
+}
+
 // AssignmentSet is a map of dance to position to dancer
 type Assignments map[*Dance]map[*Position]*Dancer
 type DancesDanced map[*Dance]struct{}
 
+// Callers is a map of dance to whoever is calling it.
+type Callers map[*Dance]*Dancer
+
+// Substitute is somebody who could step into a position if whoever is dancing
+// it can't.
+type Substitute struct {
+	Dancer     *Dancer
+	Preference DancePreference
+	// Dances is how many dances they're already dancing in the set.
+	Dances int
+}
+
+// Substitutes is a map of dance to position to who could step in, best first.
+type Substitutes map[*Dance]map[*Position][]Substitute
+
 type AssignmentSet struct {
 	dancesDanced DancesDanced
 	assignments  Assignments
+	callers      Callers
+	substitutes  Substitutes
 }
 
 func NewAssignmentSet(assignments Assignments, dancesDanced DancesDanced) AssignmentSet {
@@ -160,6 +372,55 @@ func (as AssignmentSet) DancerFor(d *Dance, p *Position) *Dancer {
 	return as.assignments[d][p]
 }
 
+// WithDancer returns the set with `dancer` in position `p` of `d`, which must
+// already be in the set. The set it's called on is left as it was.
+func (as AssignmentSet) WithDancer(d *Dance, p *Position, dancer *Dancer) AssignmentSet {
+	assignments := make(Assignments, len(as.assignments))
+	for dance, positions := range as.assignments {
+		assignments[dance] = positions
+	}
+
+	positions := make(map[*Position]*Dancer, len(as.assignments[d]))
+	for position, dancer := range as.assignments[d] {
+		positions[position] = dancer
+	}
+	positions[p] = dancer
+	assignments[d] = positions
+
+	as.assignments = assignments
+
+	return as
+}
+
+// WithCallers returns the set with `callers` calling its dances.
+func (as AssignmentSet) WithCallers(callers Callers) AssignmentSet {
+	as.callers = callers
+
+	return as
+}
+
+// CallerFor returns whoever is calling `d`, or nil if nobody is.
+func (as AssignmentSet) CallerFor(d *Dance) *Dancer {
+	return as.callers[d]
+}
+
+// WithSubstitutes returns the set with `substitutes` for its positions.
+func (as AssignmentSet) WithSubstitutes(substitutes Substitutes) AssignmentSet {
+	as.substitutes = substitutes
+
+	return as
+}
+
+// HasSubstitutes says whether substitutes have been worked out for the set.
+func (as AssignmentSet) HasSubstitutes() bool {
+	return as.substitutes != nil
+}
+
+// SubstitutesFor returns who could step into `p` in `d`, best first.
+func (as AssignmentSet) SubstitutesFor(d *Dance, p *Position) []Substitute {
+	return as.substitutes[d][p]
+}
+
 func (as AssignmentSet) NumDancesDanced() int {
 	return len(as.dancesDanced)
 }
@@ -178,6 +439,9 @@ func (as AssignmentSet) String() string {
 		for position, dancer := range positions {
 			sb.WriteString(fmt.Sprintf("  %s: %s\n", position.Name, dancer.Name))
 		}
+		if caller := as.callers[dance]; caller != nil {
+			sb.WriteString(fmt.Sprintf("  caller: %s\n", caller.Name))
+		}
 	}
 
 	return sb.String()
@@ -190,7 +454,10 @@ type Model struct {
 }
 
 func NewModel(databaseName string, logger *logrus.Entry) (*Model, error) {
-	db, err := gorm.Open(sqlite.Open(databaseName), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(databaseName), &gorm.Config{
+		// there are no FK constraints in the tables we started with either
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -198,6 +465,10 @@ func NewModel(databaseName string, logger *logrus.Entry) (*Model, error) {
 		db.Logger = NewLogrusLogger(logger)
 	}
 
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+
 	/*
 		err = db.AutoMigrate(&Dancer{}, &Position{}, &Dance{}, &DancerPosition{})
 		if err != nil {
@@ -342,3 +613,102 @@ func (m *Model) FetchDancersByName(names []string) ([]*Dancer, error) {
 	return dancers, result.Error
 
 }
+
+// FetchDancerPairs returns the pair rules between `dancers`. Rules involving
+// anybody else are left out, as there's nobody for them to apply to. The
+// dancers in the rules are the ones from `dancers`.
+func (m *Model) FetchDancerPairs(dancers []*Dancer) ([]*DancerPair, error) {
+	dancerMap := make(map[int]*Dancer, len(dancers))
+	dancerIDs := make([]int, 0, len(dancers))
+	for _, dancer := range dancers {
+		dancerMap[dancer.ID] = dancer
+		dancerIDs = append(dancerIDs, dancer.ID)
+	}
+
+	var pairs []*DancerPair
+	result := m.DB.
+		Where("dancer IN ? AND other IN ?", dancerIDs, dancerIDs).
+		Find(&pairs)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	for _, pair := range pairs {
+		pair.Dancer = dancerMap[pair.DancerID]
+		pair.Other = dancerMap[pair.OtherID]
+	}
+
+	return pairs, nil
+}
+
+// FetchCallers returns who can call which of `dances`, for the given dancers
+// only. The dancers and dances are the ones passed in.
+func (m *Model) FetchCallers(dancers []*Dancer, dances []*Dance) ([]*DancerCaller, error) {
+	dancerMap := make(map[int]*Dancer, len(dancers))
+	dancerIDs := make([]int, 0, len(dancers))
+	for _, dancer := range dancers {
+		dancerMap[dancer.ID] = dancer
+		dancerIDs = append(dancerIDs, dancer.ID)
+	}
+
+	danceMap := make(map[int]*Dance, len(dances))
+	for _, dance := range dances {
+		danceMap[dance.ID] = dance
+	}
+
+	var all []*DancerCaller
+	result := m.DB.
+		Where("dancer IN ?", dancerIDs).
+		Find(&all)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	callers := make([]*DancerCaller, 0, len(all))
+	for _, caller := range all {
+		dance, ok := danceMap[caller.DanceID]
+		if !ok {
+			continue
+		}
+
+		caller.Dancer = dancerMap[caller.DancerID]
+		caller.Dance = dance
+		callers = append(callers, caller)
+	}
+
+	return callers, nil
+}
+
+// FetchEventByName returns the event with the given name, along with who is
+// attending it.
+func (m *Model) FetchEventByName(name string) (*Event, error) {
+	var event Event
+	result := m.DB.
+		Where("name = ?", name).
+		Preload("Attendances", "dancer IN (SELECT id from dancers)").
+		Preload("Attendances.Dancer").
+		First(&event)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("can't find event %q: %w", name, result.Error)
+	}
+
+	return &event, nil
+}
+
+// FetchEventsBefore returns up to `limit` of the events before `date`, latest
+// first, along with who attended them.
+func (m *Model) FetchEventsBefore(date time.Time, limit int) ([]*Event, error) {
+	var events []*Event
+	result := m.DB.
+		Where("date < ?", date).
+		Order("date DESC").
+		Limit(limit).
+		Preload("Attendances", "dancer IN (SELECT id from dancers)").
+		Preload("Attendances.Dancer").
+		Find(&events)
+
+	return events, result.Error
+}
@@ -0,0 +1,217 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SavedSet is how an AssignmentSet is written to disk, and its schema for
+// anything else reading sets, whether as JSON or YAML. Everything is recorded
+// by ID, so a set can be loaded again even if things have been renamed since,
+// and by name, so people can read it. New fields can be added, but existing
+// ones won't be changed or removed.
+type SavedSet struct {
+	// Status is how the solver got on, in lower case: "optimal", "feasible",
+	// "infeasible", "cancelled" and so on. It's empty in sets saved to be
+	// repaired.
+	Status string       `json:"status,omitempty" yaml:"status,omitempty"`
+	Dances []SavedDance `json:"dances" yaml:"dances"`
+}
+
+type SavedDance struct {
+	// Order is where the dance comes in the set, counting from 1.
+	Order     int             `json:"order" yaml:"order"`
+	ID        int             `json:"id" yaml:"id"`
+	Name      string          `json:"name" yaml:"name"`
+	Positions []SavedPosition `json:"positions" yaml:"positions"`
+	CallerID  int             `json:"caller_id,omitempty" yaml:"caller_id,omitempty"`
+	Caller    string          `json:"caller,omitempty" yaml:"caller,omitempty"`
+}
+
+type SavedPosition struct {
+	ID       int    `json:"id" yaml:"id"`
+	Name     string `json:"name" yaml:"name"`
+	DancerID int    `json:"dancer_id" yaml:"dancer_id"`
+	Dancer   string `json:"dancer" yaml:"dancer"`
+	// Preference is how much the dancer likes the position: "favourite",
+	// "yes", "maybe" or "no". It's only filled in by WithPreferences.
+	Preference  string            `json:"preference,omitempty" yaml:"preference,omitempty"`
+	Substitutes []SavedSubstitute `json:"substitutes,omitempty" yaml:"substitutes,omitempty"`
+}
+
+type SavedSubstitute struct {
+	DancerID   int    `json:"dancer_id" yaml:"dancer_id"`
+	Dancer     string `json:"dancer" yaml:"dancer"`
+	Preference string `json:"preference" yaml:"preference"`
+	Dances     int    `json:"dances" yaml:"dances"`
+}
+
+// Dances returns the dances in the set, ordered by name.
+func (as AssignmentSet) Dances() []*Dance {
+	dances := make([]*Dance, 0, len(as.dancesDanced))
+	for dance := range as.dancesDanced {
+		dances = append(dances, dance)
+	}
+
+	sort.Slice(dances, func(i, j int) bool {
+		if dances[i].Name != dances[j].Name {
+			return dances[i].Name < dances[j].Name
+		}
+		return dances[i].ID < dances[j].ID
+	})
+
+	return dances
+}
+
+// Saved converts the set into its on-disk form. Only the dances which are
+// being danced are included.
+func (as AssignmentSet) Saved() SavedSet {
+	saved := SavedSet{Dances: make([]SavedDance, 0, len(as.dancesDanced))}
+
+	for i, dance := range as.Dances() {
+		savedDance := SavedDance{
+			Order:     i + 1,
+			ID:        dance.ID,
+			Name:      dance.Name,
+			Positions: make([]SavedPosition, 0, len(dance.Positions)),
+		}
+
+		for _, position := range dance.Positions {
+			dancer := as.DancerFor(dance, position)
+			if dancer == nil {
+				continue
+			}
+
+			savedPosition := SavedPosition{
+				ID:       position.PositionID,
+				Name:     position.Name,
+				DancerID: dancer.ID,
+				Dancer:   dancer.Name,
+			}
+
+			for _, substitute := range as.SubstitutesFor(dance, position) {
+				savedPosition.Substitutes = append(savedPosition.Substitutes, SavedSubstitute{
+					DancerID:   substitute.Dancer.ID,
+					Dancer:     substitute.Dancer.Name,
+					Preference: substitute.Preference.String(),
+					Dances:     substitute.Dances,
+				})
+			}
+
+			savedDance.Positions = append(savedDance.Positions, savedPosition)
+		}
+
+		if caller := as.CallerFor(dance); caller != nil {
+			savedDance.CallerID = caller.ID
+			savedDance.Caller = caller.Name
+		}
+
+		saved.Dances = append(saved.Dances, savedDance)
+	}
+
+	return saved
+}
+
+// WithPreferences fills in how much each dancer likes their position, from
+// `dps`. Anybody without a preference for their position is left blank.
+func (s SavedSet) WithPreferences(dps []*DancerPosition) SavedSet {
+	type key struct {
+		dancerID   int
+		danceID    int
+		positionID int
+	}
+
+	preferences := make(map[key]DancePreference, len(dps))
+	for _, dp := range dps {
+		preferences[key{dp.DancerID, dp.DanceID, dp.PositionID}] = dp.Preference
+	}
+
+	for _, dance := range s.Dances {
+		for i := range dance.Positions {
+			position := &dance.Positions[i]
+			if preference, ok := preferences[key{position.DancerID, dance.ID, position.ID}]; ok {
+				position.Preference = preference.String()
+			}
+		}
+	}
+
+	return s
+}
+
+// Save writes the set out as JSON.
+func (as AssignmentSet) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(as.Saved())
+}
+
+// LoadAssignmentSet reads a set written by Save. The dances and positions are
+// looked up in `dances`, which must contain all of them. Dancers are looked up
+// in `dancers`. Anyone who isn't there any more (say because they've gone
+// home) is still loaded, but only with the ID and name from the file.
+// Substitutes aren't loaded, as they're out of date as soon as anybody moves.
+func LoadAssignmentSet(r io.Reader, dances []*Dance, dancers []*Dancer) (AssignmentSet, error) {
+	var saved SavedSet
+	if err := json.NewDecoder(r).Decode(&saved); err != nil {
+		return AssignmentSet{}, fmt.Errorf("can't read saved set: %w", err)
+	}
+
+	dancesByID := make(map[int]*Dance, len(dances))
+	for _, dance := range dances {
+		dancesByID[dance.ID] = dance
+	}
+
+	dancersByID := make(map[int]*Dancer, len(dancers))
+	for _, dancer := range dancers {
+		dancersByID[dancer.ID] = dancer
+	}
+
+	as := make(Assignments)
+	dd := make(DancesDanced)
+	callers := make(Callers)
+
+	// anybody who isn't in `dancers` is only looked up once
+	findDancer := func(id int, name string) *Dancer {
+		dancer, ok := dancersByID[id]
+		if !ok {
+			dancer = &Dancer{ID: id, Name: name}
+			dancersByID[id] = dancer
+		}
+
+		return dancer
+	}
+
+	for _, savedDance := range saved.Dances {
+		dance, ok := dancesByID[savedDance.ID]
+		if !ok {
+			return AssignmentSet{}, fmt.Errorf("saved set has unknown dance %q (%d)", savedDance.Name, savedDance.ID)
+		}
+
+		dd[dance] = struct{}{}
+		as[dance] = make(map[*Position]*Dancer, len(savedDance.Positions))
+
+		for _, savedPosition := range savedDance.Positions {
+			var position *Position
+			for _, p := range dance.Positions {
+				if p.PositionID == savedPosition.ID {
+					position = p
+					break
+				}
+			}
+			if position == nil {
+				return AssignmentSet{}, fmt.Errorf("saved set has unknown position %q (%d) in %q", savedPosition.Name, savedPosition.ID, dance.Name)
+			}
+
+			as[dance][position] = findDancer(savedPosition.DancerID, savedPosition.Dancer)
+		}
+
+		if savedDance.CallerID != 0 {
+			callers[dance] = findDancer(savedDance.CallerID, savedDance.Caller)
+		}
+	}
+
+	return NewAssignmentSet(as, dd).WithCallers(callers), nil
+}
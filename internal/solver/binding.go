@@ -1,3 +1,5 @@
+//go:build cgo && !nocppsolver
+
 package solver
 
 /*
@@ -8,91 +10,57 @@ package solver
 #include <stdint.h>
 
 #include "dance_solver.h"
+
+extern void solutionObserverCallback(ObserverHandle handle, DanceSolution *solution);
 */
 import "C"
 import (
-	"fmt"
+	"context"
 	"runtime/cgo"
 	"unsafe"
 
 	"github.com/iainlane/who-dances-what/internal/loggerbinding"
+	"github.com/iainlane/who-dances-what/internal/model"
 	"github.com/sirupsen/logrus"
 )
 
-type SolverStatus int
-
-// map the SolverStatus enum from the C library to Go
-const (
-	SolverStatusUnknown      SolverStatus = C.SolverStatusUnknown
-	SolverStatusModelInvalid SolverStatus = C.SolverStatusModelInvalid
-	SolverStatusFeasible     SolverStatus = C.SolverStatusFeasible
-	SolverStatusInfeasible   SolverStatus = C.SolverStatusInfeasible
-	SolverStatusOptimal      SolverStatus = C.SolverStatusOptimal
-)
-
-func (s SolverStatus) String() string {
-	switch s {
-	case SolverStatusUnknown:
-		return "Unknown"
-	case SolverStatusModelInvalid:
-		return "ModelInvalid"
-	case SolverStatusFeasible:
-		return "Feasible"
-	case SolverStatusInfeasible:
-		return "Infeasible"
-	case SolverStatusOptimal:
-		return "Optimal"
-	default:
-		return fmt.Sprintf("Unknown SolverStatus: %d", s)
-	}
-}
-
-type DancePreference int
-
-// map the DancePreference enum from the C library to Go
-const (
-	PreferenceNo        DancePreference = C.PreferenceNo
-	PreferenceMaybe     DancePreference = C.PreferenceMaybe
-	PreferenceYes       DancePreference = C.PreferenceYes
-	PreferenceFavourite DancePreference = C.PreferenceFavourite
-)
-
-func (p DancePreference) String() string {
-	switch p {
-	case PreferenceNo:
-		return "No"
-	case PreferenceMaybe:
-		return "Maybe"
-	case PreferenceYes:
-		return "Yes"
-	case PreferenceFavourite:
-		return "Favourite"
-	default:
-		return fmt.Sprintf("Unknown DancePreference: %d", p)
-	}
-}
-
-type DancerPositionStatus int
-
+// The enums are defined in types.go so they're there without cgo. Make sure
+// they still match the C library: these fail to compile if they don't.
 const (
-	DancerPositionStatusUnknown DancerPositionStatus = C.DancerPositionStatusUnknown
-	DancerPositionStatusNo      DancerPositionStatus = C.DancerPositionStatusNo
-	DancerPositionStatusYes     DancerPositionStatus = C.DancerPositionStatusYes
+	_ = uint(int(SolverStatusUnknown)-int(C.SolverStatusUnknown)) + uint(int(C.SolverStatusUnknown)-int(SolverStatusUnknown))
+	_ = uint(int(SolverStatusModelInvalid)-int(C.SolverStatusModelInvalid)) + uint(int(C.SolverStatusModelInvalid)-int(SolverStatusModelInvalid))
+	_ = uint(int(SolverStatusFeasible)-int(C.SolverStatusFeasible)) + uint(int(C.SolverStatusFeasible)-int(SolverStatusFeasible))
+	_ = uint(int(SolverStatusInfeasible)-int(C.SolverStatusInfeasible)) + uint(int(C.SolverStatusInfeasible)-int(SolverStatusInfeasible))
+	_ = uint(int(SolverStatusOptimal)-int(C.SolverStatusOptimal)) + uint(int(C.SolverStatusOptimal)-int(SolverStatusOptimal))
+	_ = uint(int(SolverStatusCancelled)-int(C.SolverStatusCancelled)) + uint(int(C.SolverStatusCancelled)-int(SolverStatusCancelled))
+
+	_ = uint(int(PreferenceNo)-int(C.PreferenceNo)) + uint(int(C.PreferenceNo)-int(PreferenceNo))
+	_ = uint(int(PreferenceMaybe)-int(C.PreferenceMaybe)) + uint(int(C.PreferenceMaybe)-int(PreferenceMaybe))
+	_ = uint(int(PreferenceYes)-int(C.PreferenceYes)) + uint(int(C.PreferenceYes)-int(PreferenceYes))
+	_ = uint(int(PreferenceFavourite)-int(C.PreferenceFavourite)) + uint(int(C.PreferenceFavourite)-int(PreferenceFavourite))
+
+	_ = uint(int(AlternativeDifferenceDances)-int(C.AlternativeDifferenceDances)) + uint(int(C.AlternativeDifferenceDances)-int(AlternativeDifferenceDances))
+	_ = uint(int(AlternativeDifferenceAssignments)-int(C.AlternativeDifferenceAssignments)) + uint(int(C.AlternativeDifferenceAssignments)-int(AlternativeDifferenceAssignments))
+
+	_ = uint(int(DancerPositionStatusUnknown)-int(C.DancerPositionStatusUnknown)) + uint(int(C.DancerPositionStatusUnknown)-int(DancerPositionStatusUnknown))
+	_ = uint(int(DancerPositionStatusNo)-int(C.DancerPositionStatusNo)) + uint(int(C.DancerPositionStatusNo)-int(DancerPositionStatusNo))
+	_ = uint(int(DancerPositionStatusYes)-int(C.DancerPositionStatusYes)) + uint(int(C.DancerPositionStatusYes)-int(DancerPositionStatusYes))
+
+	_ = uint(int(DumpFormatText)-int(C.DumpFormatText)) + uint(int(C.DumpFormatText)-int(DumpFormatText))
+	_ = uint(int(DumpFormatBinary)-int(C.DumpFormatBinary)) + uint(int(C.DumpFormatBinary)-int(DumpFormatBinary))
+
+	// pair rules come straight from the model
+	_ = uint(int(model.PairTogether)-int(C.PairRuleTogether)) + uint(int(C.PairRuleTogether)-int(model.PairTogether))
+	_ = uint(int(model.PairApart)-int(C.PairRuleApart)) + uint(int(C.PairRuleApart)-int(model.PairApart))
+	_ = uint(int(model.PairNotFacing)-int(C.PairRuleNotFacing)) + uint(int(C.PairRuleNotFacing)-int(model.PairNotFacing))
+
+	// and so do caller rules
+	_ = uint(int(model.CallerNone)-int(C.CallerRuleNone)) + uint(int(C.CallerRuleNone)-int(model.CallerNone))
+	_ = uint(int(model.CallerFromDancers)-int(C.CallerRuleFromDancers)) + uint(int(C.CallerRuleFromDancers)-int(model.CallerFromDancers))
+	_ = uint(int(model.CallerStandingOut)-int(C.CallerRuleStandingOut)) + uint(int(C.CallerRuleStandingOut)-int(model.CallerStandingOut))
+	_ = uint(int(model.CallerAnyone)-int(C.CallerRuleAnyone)) + uint(int(C.CallerRuleAnyone)-int(model.CallerAnyone))
 )
 
-func (dps DancerPositionStatus) String() string {
-	switch dps {
-	case DancerPositionStatusUnknown:
-		return "Unknown"
-	case DancerPositionStatusNo:
-		return "No"
-	case DancerPositionStatusYes:
-		return "Yes"
-	default:
-		return fmt.Sprintf("Unknown DancerPositionStatus: %d", dps)
-	}
-}
-
 // The raw structs are used to convert the Go structs to C structs and back
 type rawDancer struct {
 	ID     int
@@ -156,91 +124,417 @@ func freeCDancePositions(d *C.Dance) {
 	d.positions = nil
 }
 
+// cDanceSolver owns a C++ DanceSolver and the C copies of its inputs. Like the
+// C++ class, it isn't safe for concurrent use: make one per solve. The only
+// exception is `stop`, which can be called while another goroutine is
+// solving. Different solvers share nothing, so any number can solve at once.
 type cDanceSolver struct {
-	loggerHandle cgo.Handle
-	solver       *C.Solver
+	logger loggerbinding.Logger
+	solver *C.Solver
 
 	dancers         unsafe.Pointer
 	dances          unsafe.Pointer
 	num_dances      int
 	dancerPositions unsafe.Pointer
-}
 
-func newCDanceSolver(logger *logrus.Entry, dancers []rawDancer, dances []rawDance, dancer_positions []rawDancerPosition) cDanceSolver {
-	handle := cgo.NewHandle(logger)
+	// zero unless there's a solution observer
+	observerHandle cgo.Handle
+}
 
+// The C arrays are allocated with `calloc` and must be freed by the caller.
+func toCDancers(dancers []rawDancer) unsafe.Pointer {
 	cDancers := C.calloc(C.size_t(len(dancers)), C.sizeof_Dancer)
 	dancerSlice := (*[1<<30 - 1]C.Dancer)(cDancers)
 	for i, d := range dancers {
 		dancerSlice[i] = toCDancer(d)
 	}
 
+	return cDancers
+}
+
+// Free with `freeCDances`.
+func toCDances(dances []rawDance) unsafe.Pointer {
 	cDances := C.calloc(C.size_t(len(dances)), C.sizeof_Dance)
 	danceSlice := (*[1<<30 - 1]C.Dance)(cDances)
 	for i, d := range dances {
 		danceSlice[i] = toCDance(d)
 	}
 
+	return cDances
+}
+
+func freeCDances(dances unsafe.Pointer, numDances int) {
+	for i := 0; i < numDances; i++ {
+		dance := (*C.Dance)(unsafe.Pointer(uintptr(dances) + uintptr(i)*C.sizeof_Dance))
+		freeCDancePositions(dance)
+	}
+	C.free(dances)
+}
+
+func toCDancerPositions(dancer_positions []rawDancerPosition) unsafe.Pointer {
 	cDancerPositions := C.calloc(C.size_t(len(dancer_positions)), C.sizeof_DancerPosition)
 	dancerPositionSlice := (*[1<<30 - 1]C.DancerPosition)(cDancerPositions)
 	for i, dp := range dancer_positions {
 		dancerPositionSlice[i] = toCDancerPosition(dp)
 	}
 
-	lb := loggerbinding.PopulateLogger(handle)
+	return cDancerPositions
+}
+
+func newCDanceSolver(logger *logrus.Entry, dancers []rawDancer, dances []rawDance, dancer_positions []rawDancerPosition) cDanceSolver {
+	lb := loggerbinding.NewLogger(logger)
+
+	cDancers := toCDancers(dancers)
+	cDances := toCDances(dances)
+	cDancerPositions := toCDancerPositions(dancer_positions)
 
 	solver := C.dance_solver_new_with_logger(
-		(*C.logger)(unsafe.Pointer(lb)),
-		&dancerSlice[0],
+		(*C.logger)(lb.C()),
+		(*C.Dancer)(cDancers),
 		C.int(len(dancers)),
-		&danceSlice[0],
+		(*C.Dance)(cDances),
 		C.int(len(dances)),
-		&dancerPositionSlice[0],
+		(*C.DancerPosition)(cDancerPositions),
 		C.int(len(dancer_positions)),
 	)
 
-	return cDanceSolver{handle, solver, cDancers, cDances, len(dances), cDancerPositions}
+	return cDanceSolver{lb, solver, cDancers, cDances, len(dances), cDancerPositions, 0}
 }
 
+// freeCDanceSolver frees the solver before what it uses, as it can log until
+// it's gone.
 func (solver cDanceSolver) freeCDanceSolver() {
-	solver.loggerHandle.Delete()
-	C.free(solver.dancers)
-	for i := 0; i < solver.num_dances; i++ {
-		dance := (*C.Dance)(unsafe.Pointer(uintptr(solver.dances) + uintptr(i)*C.sizeof_Dance))
-		freeCDancePositions(dance)
+	C.free_dance_solver(solver.solver)
+	if solver.observerHandle != 0 {
+		solver.observerHandle.Delete()
 	}
-	C.free(solver.dances)
+	C.free(solver.dancers)
+	freeCDances(solver.dances, solver.num_dances)
 	C.free(solver.dancerPositions)
-	C.free_dance_solver(solver.solver)
+	solver.logger.Free()
+}
+
+// cSeasonSolver is as safe for concurrent use as `cDanceSolver`.
+type cSeasonSolver struct {
+	logger loggerbinding.Logger
+	solver *C.SeasonSolver
+
+	dances          unsafe.Pointer
+	num_dances      int
+	dancerPositions unsafe.Pointer
+}
+
+func newCSeasonSolver(logger *logrus.Entry, dances []rawDance, dancer_positions []rawDancerPosition) cSeasonSolver {
+	lb := loggerbinding.NewLogger(logger)
+
+	cDances := toCDances(dances)
+	cDancerPositions := toCDancerPositions(dancer_positions)
+
+	solver := C.season_solver_new_with_logger(
+		(*C.logger)(lb.C()),
+		(*C.Dance)(cDances),
+		C.int(len(dances)),
+		(*C.DancerPosition)(cDancerPositions),
+		C.int(len(dancer_positions)),
+	)
+
+	return cSeasonSolver{lb, solver, cDances, len(dances), cDancerPositions}
+}
+
+func (solver cSeasonSolver) freeCSeasonSolver() {
+	C.free_season_solver(solver.solver)
+	freeCDances(solver.dances, solver.num_dances)
+	C.free(solver.dancerPositions)
+	solver.logger.Free()
+}
+
+// addEvent adds an event attended by `dancers`, returning its index. The
+// dancers are copied, so they're freed straight away.
+func (solver cSeasonSolver) addEvent(dancers []rawDancer) int {
+	cDancers := toCDancers(dancers)
+	defer C.free(cDancers)
+
+	return int(C.season_solver_add_event(solver.solver, (*C.Dancer)(cDancers), C.int(len(dancers))))
+}
+
+// cString returns `s` as a C string, or NULL if it's empty. Free it with
+// `C.free`.
+func cString(s string) *C.char {
+	if s == "" {
+		return nil
+	}
+
+	return C.CString(s)
+}
+
+// setDump asks the solver to write out its model and response. The paths are
+// copied, so they're freed straight away.
+func (solver cSeasonSolver) setDump(dump Dump) {
+	modelPath := cString(dump.ModelPath)
+	defer C.free(unsafe.Pointer(modelPath))
+	responsePath := cString(dump.ResponsePath)
+	defer C.free(unsafe.Pointer(responsePath))
+
+	C.season_solver_set_dump(solver.solver, modelPath, responsePath, C.DumpFormat(dump.Format))
+}
+
+func (solver cSeasonSolver) setNumWorkers(workers int) {
+	C.season_solver_set_num_workers(solver.solver, C.int(workers))
+}
+
+func (solver cSeasonSolver) setNumDances(minDances int, maxDances int) {
+	C.season_solver_set_num_dances(solver.solver, C.int(minDances), C.int(maxDances))
+}
+
+func (solver cSeasonSolver) setDanceCaller(danceID int, rule model.CallerRule) {
+	C.season_solver_set_dance_caller(solver.solver, C.int(danceID), C.CallerRule(rule))
+}
+
+// addCaller records that a dancer can call a dance at the event with index
+// `event`, as returned by `addEvent`.
+func (solver cSeasonSolver) addCaller(event int, dancerID int, danceID int) {
+	C.season_solver_add_caller(solver.solver, C.int(event), C.int(dancerID), C.int(danceID))
+}
+
+func (solver cSeasonSolver) setDanceVariant(danceID int, fullDanceID int) {
+	C.season_solver_set_dance_variant(solver.solver, C.int(danceID), C.int(fullDanceID))
+}
+
+// solve returns one solution per event, in the order they were added. It stops
+// early if `ctx` is cancelled.
+func (solver cSeasonSolver) solve(ctx context.Context) cDanceSolutionList {
+	stop := func() { C.season_solver_stop(solver.solver) }
+
+	return whileSolving(ctx, stop, func() cDanceSolutionList {
+		return newCDanceSolutionList(C.season_solver_solve(solver.solver))
+	})
+}
+
+// whileSolving runs `solve`, calling `stop` from another goroutine if `ctx` is
+// cancelled first. `stop` has always returned by the time this does, so the
+// solver can be freed straight after.
+func whileSolving[T any](ctx context.Context, stop func(), solve func() T) T {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-done:
+		}
+	}()
+
+	result := solve()
+	close(done)
+	<-stopped
+
+	return result
+}
+
+func (solver cDanceSolver) setNumDances(minDances int, maxDances int) {
+	C.dance_solver_set_num_dances(solver.solver, C.int(minDances), C.int(maxDances))
+}
+
+func (solver cDanceSolver) includeDance(danceID int) {
+	C.dance_solver_include_dance(solver.solver, C.int(danceID))
+}
+
+func (solver cDanceSolver) excludeDance(danceID int) {
+	C.dance_solver_exclude_dance(solver.solver, C.int(danceID))
+}
+
+func (solver cDanceSolver) pinDancer(dancerID int, danceID int, positionID int) {
+	C.dance_solver_pin_dancer(solver.solver, C.int(dancerID), C.int(danceID), C.int(positionID))
+}
+
+func (solver cDanceSolver) setDanceCaller(danceID int, rule model.CallerRule) {
+	C.dance_solver_set_dance_caller(solver.solver, C.int(danceID), C.CallerRule(rule))
+}
+
+func (solver cDanceSolver) addCaller(dancerID int, danceID int) {
+	C.dance_solver_add_caller(solver.solver, C.int(dancerID), C.int(danceID))
+}
+
+func (solver cDanceSolver) setDanceVariant(danceID int, fullDanceID int) {
+	C.dance_solver_set_dance_variant(solver.solver, C.int(danceID), C.int(fullDanceID))
+}
+
+// setDancerWeight takes the weight in quarters.
+func (solver cDanceSolver) setDancerWeight(dancerID int, weight int) {
+	C.dance_solver_set_dancer_weight(solver.solver, C.int(dancerID), C.int(weight))
+}
+
+func (solver cDanceSolver) addPair(dancerID int, otherID int, rule model.PairRule) {
+	C.dance_solver_add_pair(solver.solver, C.int(dancerID), C.int(otherID), C.PairRule(rule))
+}
+
+func (solver cDanceSolver) setFacingPositions(danceID int, positionID int, otherPositionID int) {
+	C.dance_solver_set_facing_positions(solver.solver, C.int(danceID), C.int(positionID), C.int(otherPositionID))
+}
+
+func (solver cDanceSolver) setDancerLimits(dancerID int, minDances int, maxDances int, soft bool) {
+	cSoft := 0
+	if soft {
+		cSoft = 1
+	}
+
+	C.dance_solver_set_dancer_limits(solver.solver, C.int(dancerID), C.int(minDances), C.int(maxDances), C.int(cSoft))
+}
+
+func (solver cDanceSolver) setPreviousAssignment(dancerID int, danceID int, positionID int) {
+	C.dance_solver_set_previous_assignment(solver.solver, C.int(dancerID), C.int(danceID), C.int(positionID))
+}
+
+// setDump is as for `cSeasonSolver.setDump`.
+func (solver cDanceSolver) setDump(dump Dump) {
+	modelPath := cString(dump.ModelPath)
+	defer C.free(unsafe.Pointer(modelPath))
+	responsePath := cString(dump.ResponsePath)
+	defer C.free(unsafe.Pointer(responsePath))
+
+	C.dance_solver_set_dump(solver.solver, modelPath, responsePath, C.DumpFormat(dump.Format))
+}
+
+func (solver cDanceSolver) setNumWorkers(workers int) {
+	C.dance_solver_set_num_workers(solver.solver, C.int(workers))
+}
+
+//export solutionObserverCallback
+func solutionObserverCallback(oh C.ObserverHandle, solution *C.DanceSolution) {
+	handle := cgo.Handle(oh)
+	observe, ok := handle.Value().(func(cDanceSolution))
+	if !ok {
+		logrus.Fatalf("handle %v is not a solution observer", handle)
+	}
+
+	observe(newCDanceSolution(solution))
+}
+
+// setSolutionObserver calls `observe` with each improving solution while
+// solving. The solution is only valid until `observe` returns, so it mustn't
+// be freed or kept.
+func (solver *cDanceSolver) setSolutionObserver(observe func(cDanceSolution)) {
+	solver.observerHandle = cgo.NewHandle(observe)
+
+	observer := C.solution_observer{
+		handle:      C.ObserverHandle(solver.observerHandle),
+		on_solution: (C.SolutionFunc)(C.solutionObserverCallback),
+	}
+	C.dance_solver_set_solution_observer(solver.solver, &observer)
+}
+
+// replayDump solves a dumped model again, writing the response to
+// `responsePath` if it isn't empty. It returns false if the model can't be
+// read; the library logs why.
+func replayDump(logger *logrus.Entry, modelPath string, responsePath string, format DumpFormat) (ReplayResult, bool) {
+	lb := loggerbinding.NewLogger(logger)
+	defer lb.Free()
+
+	cModelPath := C.CString(modelPath)
+	defer C.free(unsafe.Pointer(cModelPath))
+	cResponsePath := cString(responsePath)
+	defer C.free(unsafe.Pointer(cResponsePath))
+
+	replay := C.dance_solver_replay_dump((*C.logger)(lb.C()), cModelPath, cResponsePath, C.DumpFormat(format))
+	if replay == nil {
+		return ReplayResult{}, false
+	}
+	defer C.free_dump_replay(replay)
+
+	return ReplayResult{
+		Status:    SolverStatus(replay.status),
+		Objective: int64(replay.objective),
+		Stats:     C.GoString(replay.stats),
+	}, true
 }
 
 type cDanceSolution struct {
 	num_assignments int
 	num_dances      int
+	objective       int64
 	solution        *C.DanceSolution
 	status          SolverStatus
 }
 
-func (solver cDanceSolver) getPossibleDances() cDanceSolution {
-	solution := C.get_possible_dances(solver.solver)
+func newCDanceSolution(solution *C.DanceSolution) cDanceSolution {
 	return cDanceSolution{
 		num_assignments: int(solution.num_assignments),
 		num_dances:      int(solution.num_dances),
+		objective:       int64(solution.objective),
 		solution:        solution,
 		status:          SolverStatus(solution.status),
 	}
 }
 
+// stop stops a solve running in another goroutine.
+func (solver cDanceSolver) stop() {
+	C.dance_solver_stop(solver.solver)
+}
+
+// getPossibleDances solves, stopping early if `ctx` is cancelled.
+func (solver cDanceSolver) getPossibleDances(ctx context.Context) cDanceSolution {
+	return whileSolving(ctx, solver.stop, func() cDanceSolution {
+		return newCDanceSolution(C.get_possible_dances(solver.solver))
+	})
+}
+
 func (solution cDanceSolution) freeCDanceSolution() {
 	C.free_dance_solution(solution.solution)
 }
 
+type cDanceSolutionList struct {
+	list      *C.DanceSolutionList
+	solutions []cDanceSolution
+}
+
+func (solver cDanceSolver) getAlternativeDances(
+	ctx context.Context,
+	numAlternatives int,
+	minDifference int,
+	difference AlternativeDifference,
+) cDanceSolutionList {
+	return whileSolving(ctx, solver.stop, func() cDanceSolutionList {
+		list := C.get_alternative_dances(
+			solver.solver,
+			C.int(numAlternatives),
+			C.int(minDifference),
+			C.AlternativeDifference(difference),
+		)
+
+		return newCDanceSolutionList(list)
+	})
+}
+
+func newCDanceSolutionList(list *C.DanceSolutionList) cDanceSolutionList {
+	solutions := make([]cDanceSolution, 0, int(list.num_solutions))
+	if list.num_solutions > 0 {
+		cSolutions := unsafe.Slice(list.solutions, int(list.num_solutions))
+		for _, solution := range cSolutions {
+			solutions = append(solutions, newCDanceSolution(solution))
+		}
+	}
+
+	return cDanceSolutionList{list, solutions}
+}
+
+// The solutions are freed along with the list, so they mustn't be freed
+// individually.
+func (list cDanceSolutionList) freeCDanceSolutionList() {
+	C.free_dance_solution_list(list.list)
+}
+
 func (solution cDanceSolution) getDancerDancePosition(dance_id int, position_id int) int {
 	position := C.get_dancer_dance_position(solution.solution, C.int(dance_id), C.int(position_id))
 
 	return int(position)
 }
 
+// getDanceCaller returns -1 if nobody is calling the dance.
+func (solution cDanceSolution) getDanceCaller(danceID int) int {
+	return int(C.get_dance_caller(solution.solution, C.int(danceID)))
+}
+
 func (solution cDanceSolution) isDancePerformed(dance_id int) bool {
 	performed := C.is_dance_performed(solution.solution, C.int(dance_id))
 
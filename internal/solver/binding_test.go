@@ -1,6 +1,9 @@
+//go:build cgo && !nocppsolver
+
 package solver
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -19,7 +22,7 @@ func TestOneDanceOnePositionOneDancer(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -46,7 +49,7 @@ func Test_OneDanceWithOnePositionAndOneDancer(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -81,7 +84,7 @@ func Test_InactiveDancersAreSkipped(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusInfeasible, solution.status, "Expected status to be SolverStatusInfeasible, got %s", solution.status)
@@ -113,7 +116,7 @@ func Test_OneDanceWithOnePositionAndFourDancers(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -144,7 +147,7 @@ func Test_TwoDancesOneDancer(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -173,7 +176,7 @@ func Test_OneDanceNobodyCanDanceIt(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusInfeasible, solution.status, "Expected status to be SolverStatusInfeasible, got %s", solution.status)
@@ -198,7 +201,7 @@ func Test_OneDanceTwoPositionsTwoDancers(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -229,7 +232,7 @@ func Test_OneDanceTwoPositionsTwoDancers_CantDanceBothPositions(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusInfeasible, solution.status, "Expected status to be SolverStatusInfeasible, got %s", solution.status)
@@ -256,7 +259,7 @@ func Test_TwoDances_OneCanBeDancedAndTheOtherCant(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -289,7 +292,7 @@ func Test_TwoDancesTwoDancers_BothShouldBeGivenOneOfThem(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -324,7 +327,7 @@ func Test_PreferencesAreTakenIntoAccount_SimpleCase(t *testing.T) {
 	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
 	defer solver.freeCDanceSolver()
 
-	solution := solver.getPossibleDances()
+	solution := solver.getPossibleDances(context.Background())
 	defer solution.freeCDanceSolution()
 
 	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
@@ -338,3 +341,183 @@ func Test_PreferencesAreTakenIntoAccount_SimpleCase(t *testing.T) {
 	require.Equal(solution.num_dances, 1)
 	require.True(solution.isDancePerformed(1))
 }
+
+func Test_MaxDancesLimitsTheSet(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	dancers := []rawDancer{{1, true}}
+	dances := []rawDance{
+		{1, []rawPosition{{1}}},
+		{2, []rawPosition{{1}}},
+	}
+	dancer_positions := []rawDancerPosition{
+		{1, 1, 1, PreferenceMaybe},
+		{1, 1, 2, PreferenceFavourite},
+	}
+
+	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
+	defer solver.freeCDanceSolver()
+
+	solver.setNumDances(0, 1)
+
+	solution := solver.getPossibleDances(context.Background())
+	defer solution.freeCDanceSolution()
+
+	require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
+	require.Equal(1, solution.num_assignments, "Expected number of assignments to be 1")
+
+	// Only one dance fits, so it should be the one the dancer likes best
+	require.False(solution.isDancePerformed(1))
+	require.True(solution.isDancePerformed(2))
+}
+
+func Test_MinDancesCanMakeTheProblemInfeasible(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	dancers := []rawDancer{{1, true}}
+	dances := []rawDance{
+		{1, []rawPosition{{1}}},
+		{2, []rawPosition{{1}, {2}}},
+	}
+	dancer_positions := []rawDancerPosition{
+		{1, 1, 1, PreferenceYes},
+	}
+
+	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
+	defer solver.freeCDanceSolver()
+
+	solver.setNumDances(2, 2)
+
+	solution := solver.getPossibleDances(context.Background())
+	defer solution.freeCDanceSolution()
+
+	require.Equalf(SolverStatusInfeasible, solution.status, "Expected status to be SolverStatusInfeasible, got %s", solution.status)
+	require.Equal(0, solution.num_assignments, "Expected number of assignments to be 0")
+}
+
+func Test_DancerLimits(t *testing.T) {
+	t.Parallel()
+
+	dancers := []rawDancer{{1, true}, {2, true}}
+	dances := []rawDance{
+		{1, []rawPosition{{1}}},
+		{2, []rawPosition{{1}}},
+	}
+	dancer_positions := []rawDancerPosition{
+		{1, 1, 1, PreferenceFavourite},
+		{1, 1, 2, PreferenceFavourite},
+		{2, 1, 1, PreferenceMaybe},
+		{2, 1, 2, PreferenceMaybe},
+	}
+
+	t.Run("hard minimum", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
+		defer solver.freeCDanceSolver()
+
+		solver.setDancerLimits(2, 2, 0, false)
+
+		solution := solver.getPossibleDances(context.Background())
+		defer solution.freeCDanceSolution()
+
+		require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
+		require.Equal(2, solution.getDancerDancePosition(1, 1))
+		require.Equal(2, solution.getDancerDancePosition(2, 1))
+	})
+
+	t.Run("impossible hard minimum", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
+		defer solver.freeCDanceSolver()
+
+		solver.setDancerLimits(2, 3, 0, false)
+
+		solution := solver.getPossibleDances(context.Background())
+		defer solution.freeCDanceSolution()
+
+		require.Equalf(SolverStatusInfeasible, solution.status, "Expected status to be SolverStatusInfeasible, got %s", solution.status)
+	})
+
+	t.Run("impossible soft minimum", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
+		defer solver.freeCDanceSolver()
+
+		solver.setDancerLimits(2, 3, 0, true)
+
+		solution := solver.getPossibleDances(context.Background())
+		defer solution.freeCDanceSolution()
+
+		// as close as we can get
+		require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
+		require.Equal(2, solution.getDancerDancePosition(1, 1))
+		require.Equal(2, solution.getDancerDancePosition(2, 1))
+	})
+
+	t.Run("hard maximum", func(t *testing.T) {
+		t.Parallel()
+
+		require := require.New(t)
+
+		solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
+		defer solver.freeCDanceSolver()
+
+		// dancer 1 would otherwise be just as happy doing both dances
+		solver.setDancerLimits(1, 0, 1, false)
+
+		solution := solver.getPossibleDances(context.Background())
+		defer solution.freeCDanceSolution()
+
+		require.Equalf(SolverStatusOptimal, solution.status, "Expected status to be SolverStatusOptimal, got %s", solution.status)
+		require.ElementsMatch([]int{1, 2}, []int{solution.getDancerDancePosition(1, 1), solution.getDancerDancePosition(2, 1)})
+	})
+}
+
+func Test_AlternativeDances(t *testing.T) {
+	t.Parallel()
+
+	require := require.New(t)
+
+	dancers := []rawDancer{{1, true}}
+	dances := []rawDance{
+		{1, []rawPosition{{1}}},
+		{2, []rawPosition{{1}}},
+	}
+	dancer_positions := []rawDancerPosition{
+		{1, 1, 1, PreferenceYes},
+		{1, 1, 2, PreferenceYes},
+	}
+
+	solver := newCDanceSolver(logrus.WithField("test-name", t.Name()), dancers, dances, dancer_positions)
+	defer solver.freeCDanceSolver()
+
+	// there are only three sets: both dances, or either one of them
+	list := solver.getAlternativeDances(context.Background(), 5, 1, AlternativeDifferenceDances)
+	defer list.freeCDanceSolutionList()
+
+	require.Len(list.solutions, 3)
+
+	best := list.solutions[0]
+	require.Equalf(SolverStatusOptimal, best.status, "Expected status to be SolverStatusOptimal, got %s", best.status)
+	require.True(best.isDancePerformed(1))
+	require.True(best.isDancePerformed(2))
+
+	second, third := list.solutions[1], list.solutions[2]
+	require.GreaterOrEqual(best.objective, second.objective)
+	require.GreaterOrEqual(second.objective, third.objective)
+	require.NotEqual(second.isDancePerformed(1), third.isDancePerformed(1))
+	require.NotEqual(second.isDancePerformed(2), third.isDancePerformed(2))
+}
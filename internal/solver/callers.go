@@ -0,0 +1,124 @@
+package solver
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// Caller says that a dancer can call a dance. Whether the dance needs a caller,
+// and whether they have to be dancing it, is up to the dance's `Caller` rule.
+type Caller struct {
+	Dancer *model.Dancer
+	Dance  *model.Dance
+}
+
+func (c Caller) String() string {
+	return fmt.Sprintf("%s can call %s", c.Dancer.Name, c.Dance.Name)
+}
+
+// CallersFromModel converts who can call what from the database.
+func CallersFromModel(callers []*model.DancerCaller) []Caller {
+	converted := make([]Caller, 0, len(callers))
+	for _, caller := range callers {
+		converted = append(converted, Caller{Dancer: caller.Dancer, Dance: caller.Dance})
+	}
+
+	return converted
+}
+
+// activeCallers returns who can call each dance, by dance ID, out of the
+// dancers who are here and active, with each dancer as `key` says. Everybody
+// who's here has preferences in `dps`.
+func activeCallers(callers []Caller, dps []*model.DancerPosition, key func(*model.Dancer) int) map[int][]int {
+	if len(callers) == 0 {
+		return nil
+	}
+
+	here := make(map[int]*model.Dancer)
+	for _, dp := range dps {
+		if dp.Dancer.Active {
+			here[dp.Dancer.ID] = dp.Dancer
+		}
+	}
+
+	byDance := make(map[int][]int)
+	for _, caller := range callers {
+		if dancer, ok := here[caller.Dancer.ID]; ok {
+			byDance[caller.Dance.ID] = append(byDance[caller.Dance.ID], key(dancer))
+		}
+	}
+
+	return byDance
+}
+
+// chooseCaller picks somebody from `callers` to call a dance with `assignment`,
+// the dancer for each position, following `rule`. Dancers are preferred in
+// position order, so whoever is in position 1 calls if they can.
+func chooseCaller(rule model.CallerRule, callers []int, assignment []int) (int, bool) {
+	can := make(map[int]struct{}, len(callers))
+	for _, caller := range callers {
+		can[caller] = struct{}{}
+	}
+
+	dancing := make(map[int]struct{}, len(assignment))
+	for _, dancer := range assignment {
+		dancing[dancer] = struct{}{}
+	}
+
+	if rule == model.CallerFromDancers || rule == model.CallerAnyone {
+		for _, dancer := range assignment {
+			if _, ok := can[dancer]; ok {
+				return dancer, true
+			}
+		}
+	}
+
+	if rule == model.CallerStandingOut || rule == model.CallerAnyone {
+		for _, caller := range callers {
+			if _, ok := dancing[caller]; !ok {
+				return caller, true
+			}
+		}
+	}
+
+	return -1, false
+}
+
+// canBeCalled says whether any of `callers` could call `dance`, given who
+// could dance each of its positions. It only looks at each caller on their
+// own, so it can say yes when they're all needed to fill the positions.
+func canBeCalled(dance *model.Dance, callers []int, eligible [][]int) bool {
+	if dance.Caller == model.CallerNone {
+		return true
+	}
+	if dance.Caller != model.CallerFromDancers {
+		return len(callers) > 0
+	}
+
+	for _, dancers := range eligible {
+		for _, dancer := range dancers {
+			if slices.Contains(callers, dancer) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// callerAllowed says whether `caller` calling a dance with `assignment` keeps
+// to `rule`.
+func callerAllowed(rule model.CallerRule, caller int, assignment []int) bool {
+	dancing := slices.Contains(assignment, caller)
+
+	switch rule {
+	case model.CallerFromDancers:
+		return dancing
+	case model.CallerStandingOut:
+		return !dancing
+	default:
+		return true
+	}
+}
@@ -0,0 +1,76 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// checkedSolver validates every set its backend returns, turning broken ones
+// into a `ViolationsError`. `New` uses it in debug builds.
+type checkedSolver struct {
+	Solver
+}
+
+// checkResult validates a result. An empty set is what comes back when there
+// isn't one to be had, so it's not checked against the constraints.
+func checkResult(result SolveResult, dps []*model.DancerPosition, constraints Constraints) error {
+	if result.Set.NumDancesDanced() == 0 {
+		return nil
+	}
+
+	if violations := Validate(result.Set, dps, constraints); len(violations) > 0 {
+		return ViolationsError(violations)
+	}
+
+	return nil
+}
+
+func (s checkedSolver) Solve(ctx context.Context, logger *logrus.Entry, dps []*model.DancerPosition, constraints Constraints) (SolveResult, error) {
+	result, err := s.Solver.Solve(ctx, logger, dps, constraints)
+	if err != nil {
+		return result, err
+	}
+
+	return result, checkResult(result, dps, constraints)
+}
+
+func (s checkedSolver) SolveAlternatives(
+	ctx context.Context,
+	logger *logrus.Entry,
+	dps []*model.DancerPosition,
+	constraints Constraints,
+	n int,
+	minDifference int,
+	difference AlternativeDifference,
+) ([]SolveResult, error) {
+	results, err := s.Solver.SolveAlternatives(ctx, logger, dps, constraints, n, minDifference, difference)
+	if err != nil {
+		return results, err
+	}
+
+	for i, result := range results {
+		if err := checkResult(result, dps, constraints); err != nil {
+			return results, fmt.Errorf("alternative %d: %w", i+1, err)
+		}
+	}
+
+	return results, nil
+}
+
+func (s checkedSolver) SolveSeason(ctx context.Context, logger *logrus.Entry, events []SeasonEvent, constraints Constraints) ([]SolveResult, error) {
+	results, err := s.Solver.SolveSeason(ctx, logger, events, constraints)
+	if err != nil {
+		return results, err
+	}
+
+	for i, result := range results {
+		if err := checkResult(result, events[i].DancerPositions, constraints); err != nil {
+			return results, fmt.Errorf("%s: %w", events[i].Name, err)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,236 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Constraints are extra rules the caller can place on the set, on top of the
+// ones which always apply (every position filled by a different dancer, nobody
+// dancing a position they've said "no" to, and so on). The zero value places
+// no extra constraints.
+type Constraints struct {
+	// MinDances and MaxDances bound how many dances are in the set. 0 means
+	// unbounded. Setting them both to the same value asks for exactly that many
+	// dances.
+	MinDances int
+	MaxDances int
+
+	// Include and Exclude force dances in or out of the set.
+	Include []*model.Dance
+	Exclude []*model.Dance
+
+	// Pins fix dancers to positions. The solver fills in the rest of the set
+	// around them.
+	Pins []Pin
+
+	// DancerLimits bound how many dances individual dancers do.
+	DancerLimits map[*model.Dancer]DancerLimit
+
+	// Weights say how many dances individual dancers should get compared to
+	// everybody else: somebody with a weight of 2 should get twice as many as
+	// usual, and 0.5 half as many. Weights are rounded to quarters, and go up
+	// to 4. Dancers without one get an even share.
+	Weights map[*model.Dancer]float64
+
+	// Pairs keep dancers together or apart.
+	Pairs []Pair
+
+	// Callers are who can call which dances. Every dance in the set which
+	// needs a caller gets one of them, as its `Caller` rule says.
+	Callers []Caller
+
+	// Repair is a set to start from, for when it needs to change part way
+	// through the day. The solver changes as few of its assignments as it can
+	// while still following the other rules.
+	Repair *model.AssignmentSet
+}
+
+// DancerLimit bounds how many dances a dancer does. 0 means unbounded. Hard
+// limits are always kept to, so they can make a set impossible; soft limits
+// are kept to unless there's no other way to make the set.
+type DancerLimit struct {
+	MinDances int
+	MaxDances int
+	Soft      bool
+}
+
+func (l DancerLimit) IsZero() bool {
+	return l.MinDances == 0 && l.MaxDances == 0
+}
+
+// dancerWeightUnits is how finely dancer weights are divided, the same as
+// DANCER_WEIGHT_UNITS in dance_solver.hpp.
+const dancerWeightUnits = 4
+
+// maxDancerWeight keeps the numbers the solvers have to deal with small.
+const maxDancerWeight = 4
+
+// weightUnits is `weight` in quarters.
+func weightUnits(weight float64) int {
+	return int(math.Round(weight * dancerWeightUnits))
+}
+
+// weightUnitsFor is `dancer`'s weight in quarters.
+func (c Constraints) weightUnitsFor(dancer *model.Dancer) int {
+	for weighted, weight := range c.Weights {
+		if weighted.ID == dancer.ID {
+			return weightUnits(weight)
+		}
+	}
+
+	return dancerWeightUnits
+}
+
+// Pin puts a dancer in a particular position of a dance.
+type Pin struct {
+	Dancer   *model.Dancer
+	Dance    *model.Dance
+	Position *model.Position
+}
+
+func (p Pin) String() string {
+	return fmt.Sprintf("%s=%s:%s", p.Dancer.Name, p.Dance.Name, p.Position.Name)
+}
+
+// check looks for constraints which can never be satisfied, so we can give a
+// clear error instead of the solver just saying the problem is infeasible.
+func (c Constraints) check(dps []*model.DancerPosition) error {
+	excluded := make(map[int]struct{}, len(c.Exclude))
+	for _, dance := range c.Exclude {
+		excluded[dance.ID] = struct{}{}
+	}
+
+	// the dances we know about are only the ones which turned up in `dps`
+	known := make(map[int]struct{})
+	for _, dp := range dps {
+		known[dp.Dance.ID] = struct{}{}
+	}
+
+	included := make(map[int]*model.Dance, len(c.Include))
+	for _, dance := range c.Include {
+		if _, ok := excluded[dance.ID]; ok {
+			return fmt.Errorf("%q is both included and excluded", dance.Name)
+		}
+		// anything which must be in the set has to be danceable by somebody
+		// here
+		if _, ok := known[dance.ID]; !ok {
+			return fmt.Errorf("%q is included, but nobody here can dance it", dance.Name)
+		}
+		included[dance.ID] = dance
+	}
+
+	type pinnedPosition struct {
+		danceID    int
+		positionID int
+	}
+	type pinnedDancer struct {
+		danceID  int
+		dancerID int
+	}
+	pinnedPositions := make(map[pinnedPosition]Pin, len(c.Pins))
+	pinnedDancers := make(map[pinnedDancer]Pin, len(c.Pins))
+
+	for _, pin := range c.Pins {
+		if _, ok := excluded[pin.Dance.ID]; ok {
+			return fmt.Errorf("can't pin %s: %q is excluded", pin, pin.Dance.Name)
+		}
+
+		if !pin.Dancer.Active {
+			return fmt.Errorf("can't pin %s: %s isn't an active dancer", pin, pin.Dancer.Name)
+		}
+
+		pp := pinnedPosition{pin.Dance.ID, pin.Position.PositionID}
+		if other, ok := pinnedPositions[pp]; ok && other.Dancer.ID != pin.Dancer.ID {
+			return fmt.Errorf("can't pin %s: %s is already pinned there", pin, other.Dancer.Name)
+		}
+		pinnedPositions[pp] = pin
+
+		pd := pinnedDancer{pin.Dance.ID, pin.Dancer.ID}
+		if other, ok := pinnedDancers[pd]; ok && other.Position.PositionID != pin.Position.PositionID {
+			return fmt.Errorf("can't pin %s: they're already pinned to %s", pin, other.Position.Name)
+		}
+		pinnedDancers[pd] = pin
+
+		preference := model.PreferenceNo
+		for _, dp := range dps {
+			if dp.Dancer.ID == pin.Dancer.ID && dp.Dance.ID == pin.Dance.ID && dp.Position.PositionID == pin.Position.PositionID {
+				preference = dp.Preference
+				break
+			}
+		}
+
+		if preference == model.PreferenceNo {
+			return fmt.Errorf("can't pin %s: %s has said %q to %s in %q", pin, pin.Dancer.Name, preference, pin.Position.Name, pin.Dance.Name)
+		}
+
+		included[pin.Dance.ID] = pin.Dance
+	}
+
+	// only one version of a dance can be danced
+	versions := make(map[int]*model.Dance, len(included))
+	for _, dance := range included {
+		if other, ok := versions[dance.VersionOf()]; ok {
+			return fmt.Errorf("%q and %q are both included or pinned, but they're versions of the same dance", other.Name, dance.Name)
+		}
+		versions[dance.VersionOf()] = dance
+	}
+
+	for dancer, limit := range c.DancerLimits {
+		if limit.MinDances < 0 || limit.MaxDances < 0 {
+			return fmt.Errorf("%s's dance limits can't be negative", dancer.Name)
+		}
+
+		if limit.MaxDances > 0 && limit.MinDances > limit.MaxDances {
+			return fmt.Errorf("%s's minimum number of dances (%d) is more than their maximum (%d)", dancer.Name, limit.MinDances, limit.MaxDances)
+		}
+	}
+
+	for dancer, weight := range c.Weights {
+		if !(weight > 0 && weight <= maxDancerWeight) || weightUnits(weight) < 1 {
+			return fmt.Errorf("%s's weight must be between %g and %d, not %g", dancer.Name, 1.0/dancerWeightUnits, maxDancerWeight, weight)
+		}
+	}
+
+	for _, pair := range c.Pairs {
+		if pair.Dancer.ID == pair.Other.ID {
+			return fmt.Errorf("%s can't be paired with themselves", pair.Dancer.Name)
+		}
+
+		switch pair.Rule {
+		case model.PairTogether, model.PairApart, model.PairNotFacing:
+		default:
+			return fmt.Errorf("unknown rule for %s", pair)
+		}
+	}
+
+	if c.MaxDances > 0 && len(included) > c.MaxDances {
+		return fmt.Errorf("%d dances are included or pinned, but the set can have at most %d", len(included), c.MaxDances)
+	}
+
+	return nil
+}
+
+// warnAbsentLimits warns about dancers who should dance at least some dances,
+// but aren't in `dps`. Their limits are ignored, as they can't dance at all.
+func (c Constraints) warnAbsentLimits(logger *logrus.Entry, dps []*model.DancerPosition) {
+	here := make(map[int]struct{})
+	for _, dp := range dps {
+		here[dp.Dancer.ID] = struct{}{}
+	}
+
+	for dancer, limit := range c.DancerLimits {
+		if _, ok := here[dancer.ID]; ok || limit.MinDances == 0 {
+			continue
+		}
+
+		dances := "dances"
+		if limit.MinDances == 1 {
+			dances = "dance"
+		}
+		logger.WithField("dancer", dancer.Name).Warnf("%s isn't here, so can't dance at least %d %s", dancer.Name, limit.MinDances, dances)
+	}
+}
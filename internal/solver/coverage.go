@@ -0,0 +1,108 @@
+package solver
+
+import (
+	"sort"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// PositionCoverage is who can dance one position of a dance.
+type PositionCoverage struct {
+	Position *model.Position
+	// Dancers are the active dancers who can dance it, by preference and then
+	// by name.
+	Dancers map[model.DancePreference][]*model.Dancer
+}
+
+// BusFactor is how many dancers can dance the position, so how many would
+// have to be away before it can't be danced.
+func (p PositionCoverage) BusFactor() int {
+	n := 0
+	for _, dancers := range p.Dancers {
+		n += len(dancers)
+	}
+
+	return n
+}
+
+// DanceCoverage is how well a dance is covered by the dancers.
+type DanceCoverage struct {
+	Dance     *model.Dance
+	Positions []PositionCoverage
+	// Danceable says whether there are enough different dancers to fill all
+	// of the positions at once.
+	Danceable bool
+	// Essential are the dancers the dance can't be danced without, by name.
+	Essential []*model.Dancer
+}
+
+// Coverage works out who can dance each position of `dances`, from `dps`,
+// which are everybody's preferences. Only active dancers count. A dance is
+// danceable if its positions could all be filled at once by some of them,
+// without looking at who can call it or any pair rules.
+func Coverage(dances []*model.Dance, dps []*model.DancerPosition) []DanceCoverage {
+	type key struct {
+		danceID    int
+		positionID int
+	}
+
+	able := make(map[key][]*model.DancerPosition)
+	for _, dp := range dps {
+		if canDance(dp) {
+			k := key{dp.Dance.ID, dp.Position.PositionID}
+			able[k] = append(able[k], dp)
+		}
+	}
+
+	coverage := make([]DanceCoverage, 0, len(dances))
+	for _, dance := range dances {
+		dc := DanceCoverage{Dance: dance, Positions: make([]PositionCoverage, 0, len(dance.Positions))}
+
+		eligible := make([][]int, 0, len(dance.Positions))
+		dancers := make(map[int]*model.Dancer)
+		for _, position := range dance.Positions {
+			pc := PositionCoverage{Position: position, Dancers: make(map[model.DancePreference][]*model.Dancer)}
+
+			var ids []int
+			for _, dp := range able[key{dance.ID, position.PositionID}] {
+				pc.Dancers[dp.Preference] = append(pc.Dancers[dp.Preference], dp.Dancer)
+				ids = append(ids, dp.Dancer.ID)
+				dancers[dp.Dancer.ID] = dp.Dancer
+			}
+			for _, byPreference := range pc.Dancers {
+				sort.Slice(byPreference, func(i, j int) bool { return byPreference[i].Name < byPreference[j].Name })
+			}
+
+			dc.Positions = append(dc.Positions, pc)
+			eligible = append(eligible, ids)
+		}
+
+		dc.Danceable = newMatching(eligible).complete()
+		if dc.Danceable {
+			for id, dancer := range dancers {
+				if !newMatching(without(eligible, id)).complete() {
+					dc.Essential = append(dc.Essential, dancer)
+				}
+			}
+			sort.Slice(dc.Essential, func(i, j int) bool { return dc.Essential[i].Name < dc.Essential[j].Name })
+		}
+
+		coverage = append(coverage, dc)
+	}
+
+	return coverage
+}
+
+// without returns `eligible` with `dancer` taken out of every position.
+func without(eligible [][]int, dancer int) [][]int {
+	out := make([][]int, len(eligible))
+	for position, dancers := range eligible {
+		for _, d := range dancers {
+			if d != dancer {
+				out[position] = append(out[position], d)
+			}
+		}
+	}
+
+	return out
+}
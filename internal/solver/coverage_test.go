@@ -0,0 +1,46 @@
+package solver
+
+import (
+	"testing"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoverage(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+
+	dances := goTestDances(2, 2)
+	a, b := dances[0], dances[1]
+
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceFavourite),
+		goTestDP(bob, a, 1, model.PreferenceYes),
+		goTestDP(carol, a, 2, model.PreferenceMaybe),
+		goTestDP(alice, a, 2, model.PreferenceNo),
+		// only Alice can dance either of B's positions
+		goTestDP(alice, b, 1, model.PreferenceYes),
+		goTestDP(alice, b, 2, model.PreferenceYes),
+	}
+
+	coverage := Coverage(dances, dps)
+	require.Len(t, coverage, 2)
+
+	ca := coverage[0]
+	require.Same(t, a, ca.Dance)
+	require.True(t, ca.Danceable)
+	require.Equal(t, []*model.Dancer{alice}, ca.Positions[0].Dancers[model.PreferenceFavourite])
+	require.Equal(t, []*model.Dancer{bob}, ca.Positions[0].Dancers[model.PreferenceYes])
+	require.Equal(t, 2, ca.Positions[0].BusFactor())
+	require.Equal(t, 1, ca.Positions[1].BusFactor())
+	require.Equal(t, []*model.Dancer{carol}, ca.Essential)
+
+	cb := coverage[1]
+	require.False(t, cb.Danceable)
+	require.Equal(t, 1, cb.Positions[0].BusFactor())
+	require.Empty(t, cb.Essential)
+}
@@ -0,0 +1,341 @@
+//go:build cgo && !nocppsolver
+
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+)
+
+// DefaultBackend is the backend used unless another is asked for.
+const DefaultBackend = BackendCpp
+
+func init() {
+	backends[BackendCpp] = newCppSolver
+}
+
+// problem is the data from the model in the format the C solver expects, along
+// with what we need to convert the solution back again.
+type problem struct {
+	dancers         map[*model.Dancer]rawDancer
+	dancersById     map[int]*model.Dancer
+	dances          map[*model.Dance]rawDance
+	dancerPositions []rawDancerPosition
+}
+
+func newProblem(dps []*model.DancerPosition) problem {
+	p := problem{
+		dancers:         make(map[*model.Dancer]rawDancer),
+		dancersById:     make(map[int]*model.Dancer),
+		dances:          make(map[*model.Dance]rawDance),
+		dancerPositions: make([]rawDancerPosition, 0, len(dps)),
+	}
+
+	// check if the dancer is already in the map and if not, add it
+	for _, dp := range dps {
+		dancer := dp.Dancer
+		if _, ok := p.dancers[dancer]; !ok {
+			p.dancers[dancer] = rawDancer{
+				Active: dancer.Active,
+				ID:     int(dancer.ID),
+			}
+			p.dancersById[dancer.ID] = dancer
+		}
+
+		dance := dp.Dance
+		if _, ok := p.dances[dance]; !ok {
+			positions := make([]rawPosition, 0, len(dance.Positions))
+			for _, position := range dance.Positions {
+				positions = append(positions, rawPosition{PositionID: int(position.PositionID)})
+			}
+			p.dances[dance] = rawDance{
+				ID:        int(dance.ID),
+				Positions: positions,
+			}
+		}
+
+		dancerPosition := dp
+		p.dancerPositions = append(p.dancerPositions, rawDancerPosition{
+			DancerID:   int(dancer.ID),
+			PositionID: int(dancerPosition.Position.PositionID),
+			DanceID:    int(dance.ID),
+			Preference: DancePreference(dancerPosition.Preference),
+		})
+	}
+
+	return p
+}
+
+// newSolver creates a C solver for the problem, with the constraints applied.
+// It must be freed with `freeCDanceSolver`.
+func (p problem) newSolver(logger *logrus.Entry, constraints Constraints, options Options) cDanceSolver {
+	solver := newCDanceSolver(logger, maps.Values(p.dancers), maps.Values(p.dances), p.dancerPositions)
+	if options.Dump != nil {
+		solver.setDump(*options.Dump)
+	}
+	solver.setNumWorkers(options.Workers)
+	if options.Progress != nil {
+		solver.setSolutionObserver(func(solution cDanceSolution) {
+			options.Progress(p.result(solution))
+		})
+	}
+	solver.setNumDances(constraints.MinDances, constraints.MaxDances)
+	for _, dance := range constraints.Include {
+		solver.includeDance(dance.ID)
+	}
+	for _, dance := range constraints.Exclude {
+		solver.excludeDance(dance.ID)
+	}
+	for _, pin := range constraints.Pins {
+		solver.pinDancer(pin.Dancer.ID, pin.Dance.ID, pin.Position.PositionID)
+	}
+	for dancer, limit := range constraints.DancerLimits {
+		if _, ok := p.dancers[dancer]; !ok {
+			logger.WithField("dancer", dancer.Name).Debug("ignoring limits for dancer who isn't dancing")
+			continue
+		}
+		solver.setDancerLimits(dancer.ID, limit.MinDances, limit.MaxDances, limit.Soft)
+	}
+	for weighted := range constraints.Weights {
+		if dancer, ok := p.dancersById[weighted.ID]; ok && dancer.Active {
+			solver.setDancerWeight(dancer.ID, constraints.weightUnitsFor(dancer))
+		}
+	}
+	for _, pair := range constraints.Pairs {
+		dancer, ok := p.dancersById[pair.Dancer.ID]
+		other, otherOk := p.dancersById[pair.Other.ID]
+		if !ok || !otherOk || !dancer.Active || !other.Active {
+			logger.WithField("pair", pair.String()).Debug("ignoring pair rule for dancers who aren't both dancing")
+			continue
+		}
+		solver.addPair(dancer.ID, other.ID, pair.Rule)
+	}
+	for dance := range p.dances {
+		if dance.Caller != model.CallerNone {
+			solver.setDanceCaller(dance.ID, dance.Caller)
+		}
+	}
+	for _, caller := range constraints.Callers {
+		if dancer, ok := p.dancersById[caller.Dancer.ID]; ok && dancer.Active {
+			solver.addCaller(dancer.ID, caller.Dance.ID)
+		}
+	}
+	for dance := range p.dances {
+		if dance.VariantOf != 0 {
+			solver.setDanceVariant(dance.ID, dance.VariantOf)
+		}
+	}
+	if len(constraints.Pairs) > 0 {
+		for dance := range p.dances {
+			for _, facing := range facingPositions(dance) {
+				solver.setFacingPositions(dance.ID, dance.Positions[facing[0]].PositionID, dance.Positions[facing[1]].PositionID)
+			}
+		}
+	}
+	if constraints.Repair != nil {
+		for _, dance := range constraints.Repair.Dances() {
+			for _, position := range dance.Positions {
+				dancer := constraints.Repair.DancerFor(dance, position)
+				if dancer == nil {
+					continue
+				}
+				if _, ok := p.dancersById[dancer.ID]; !ok {
+					logger.WithField("dancer", dancer.Name).Debug("dancer in the set being repaired isn't here any more")
+				}
+				solver.setPreviousAssignment(dancer.ID, dance.ID, position.PositionID)
+			}
+		}
+	}
+
+	return solver
+}
+
+// result converts the output from the C solver back into the format the model
+// expects.
+func (p problem) result(solution cDanceSolution) SolveResult {
+	as := make(model.Assignments)
+	dd := make(model.DancesDanced)
+	callers := make(model.Callers)
+	assignments := model.NewAssignmentSet(as, dd).WithCallers(callers)
+	for dance, rawDance := range p.dances {
+		danceID := rawDance.ID
+		as[dance] = make(map[*model.Position]*model.Dancer)
+		if solution.isDancePerformed(dance.ID) {
+			dd[dance] = struct{}{}
+		}
+		if caller, ok := p.dancersById[solution.getDanceCaller(danceID)]; ok {
+			callers[dance] = caller
+		}
+		for _, position := range dance.Positions {
+			positionID := position.PositionID
+			idOfDancer := solution.getDancerDancePosition(danceID, positionID)
+			dancer := p.dancersById[idOfDancer]
+			as[dance][position] = dancer
+		}
+	}
+
+	return SolveResult{
+		Set:       assignments,
+		Status:    solution.status,
+		Objective: solution.objective,
+	}
+}
+
+// cppSolver is a wrapper around the C solver. It takes in the data from the
+// model and converts it into the format the C solver expects.
+// It then converts the output from the C solver back into the format the model
+// expects.
+type cppSolver struct {
+	options Options
+}
+
+func newCppSolver(options Options) (Solver, error) {
+	return cppSolver{options: options}, nil
+}
+
+// ReplayDump solves a model written out with `Options.Dump` again, exactly as
+// it was, and writes the new response to `responsePath` if it isn't empty.
+func ReplayDump(logger *logrus.Entry, modelPath string, responsePath string, format DumpFormat) (ReplayResult, error) {
+	result, ok := replayDump(logger, modelPath, responsePath, format)
+	if !ok {
+		return ReplayResult{}, fmt.Errorf("can't read a model from %s", modelPath)
+	}
+
+	return result, nil
+}
+
+func (s cppSolver) Solve(ctx context.Context, logger *logrus.Entry, dps []*model.DancerPosition, constraints Constraints) (SolveResult, error) {
+	if err := constraints.check(dps); err != nil {
+		return SolveResult{}, err
+	}
+
+	constraints.warnAbsentLimits(logger, dps)
+
+	p := newProblem(dps)
+
+	solver := p.newSolver(logger, constraints, s.options)
+	defer solver.freeCDanceSolver()
+
+	solution := solver.getPossibleDances(ctx)
+	defer solution.freeCDanceSolution()
+
+	result := p.result(solution)
+	result.Explanations = Explain(danceList(dps), dps, result.Set, result.Status, constraints, nil)
+
+	return result, nil
+}
+
+func (s cppSolver) SolveAlternatives(
+	ctx context.Context,
+	logger *logrus.Entry,
+	dps []*model.DancerPosition,
+	constraints Constraints,
+	n int,
+	minDifference int,
+	difference AlternativeDifference,
+) ([]SolveResult, error) {
+	if err := checkAlternatives(n, minDifference); err != nil {
+		return nil, err
+	}
+
+	if err := constraints.check(dps); err != nil {
+		return nil, err
+	}
+
+	constraints.warnAbsentLimits(logger, dps)
+
+	p := newProblem(dps)
+
+	solver := p.newSolver(logger, constraints, s.options)
+	defer solver.freeCDanceSolver()
+
+	list := solver.getAlternativeDances(ctx, n, minDifference, difference)
+	defer list.freeCDanceSolutionList()
+
+	results := make([]SolveResult, 0, len(list.solutions))
+	for _, solution := range list.solutions {
+		result := p.result(solution)
+		result.Explanations = Explain(danceList(dps), dps, result.Set, result.Status, constraints, nil)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (s cppSolver) SolveSeason(ctx context.Context, logger *logrus.Entry, events []SeasonEvent, constraints Constraints) ([]SolveResult, error) {
+	if len(events) == 0 {
+		return nil, errors.New("no events to solve")
+	}
+
+	if err := constraints.checkSeason(); err != nil {
+		return nil, err
+	}
+
+	var dps []*model.DancerPosition
+	for _, event := range events {
+		if err := constraints.check(event.DancerPositions); err != nil {
+			return nil, fmt.Errorf("%s: %w", event.Name, err)
+		}
+		dps = append(dps, event.DancerPositions...)
+	}
+
+	p := newProblem(dps)
+
+	solver := newCSeasonSolver(logger, maps.Values(p.dances), p.dancerPositions)
+	defer solver.freeCSeasonSolver()
+
+	if s.options.Dump != nil {
+		solver.setDump(*s.options.Dump)
+	}
+	solver.setNumWorkers(s.options.Workers)
+
+	for _, event := range events {
+		dancers := make(map[*model.Dancer]rawDancer)
+		here := make(map[int]struct{})
+		for _, dp := range event.DancerPositions {
+			dancers[dp.Dancer] = p.dancers[dp.Dancer]
+			here[dp.Dancer.ID] = struct{}{}
+		}
+
+		logger.WithFields(logrus.Fields{
+			"event":   event.Name,
+			"dancers": len(dancers),
+		}).Debug("adding event")
+
+		index := solver.addEvent(maps.Values(dancers))
+
+		// only the callers at this event can call its dances
+		for _, caller := range constraints.Callers {
+			_, isHere := here[caller.Dancer.ID]
+			if dancer, ok := p.dancersById[caller.Dancer.ID]; ok && isHere && dancer.Active {
+				solver.addCaller(index, dancer.ID, caller.Dance.ID)
+			}
+		}
+	}
+	solver.setNumDances(constraints.MinDances, constraints.MaxDances)
+	for dance := range p.dances {
+		if dance.Caller != model.CallerNone {
+			solver.setDanceCaller(dance.ID, dance.Caller)
+		}
+	}
+	for dance := range p.dances {
+		if dance.VariantOf != 0 {
+			solver.setDanceVariant(dance.ID, dance.VariantOf)
+		}
+	}
+
+	list := solver.solve(ctx)
+	defer list.freeCDanceSolutionList()
+
+	results := make([]SolveResult, 0, len(list.solutions))
+	for _, solution := range list.solutions {
+		results = append(results, p.result(solution))
+	}
+
+	return results, nil
+}
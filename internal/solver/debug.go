@@ -0,0 +1,7 @@
+//go:build debug
+
+package solver
+
+// debugBuild is set by building with the `debug` tag. Every set the solvers
+// return is then checked with `Validate`.
+const debugBuild = true
@@ -0,0 +1,329 @@
+package solver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// Reason is why a dance isn't in a set.
+type Reason int
+
+const (
+	// ReasonExcluded means the dance was excluded by a constraint.
+	ReasonExcluded Reason = iota + 1
+	// ReasonNoEligibleDancer means there are positions which nobody here can
+	// dance.
+	ReasonNoEligibleDancer
+	// ReasonMatchingConflict means every position has somebody who can dance
+	// it, but there aren't enough different people to fill them all at once.
+	ReasonMatchingConflict
+	// ReasonPairs means the positions could all be filled, but not without
+	// breaking a pair rule.
+	ReasonPairs
+	// ReasonNoCaller means the dance needs a caller, but nobody here can call
+	// it in the way it has to be called.
+	ReasonNoCaller
+	// ReasonOtherVersion means a different sized version of the dance is in
+	// the set instead.
+	ReasonOtherVersion
+	// ReasonObjective means the dance could have been danced, but the solver
+	// found a better set without it. For example the set might already be as
+	// long as it's allowed to be.
+	ReasonObjective
+	// ReasonInfeasible means the dance could have been danced, but there's no
+	// set which meets all of the constraints, so nothing was danced.
+	ReasonInfeasible
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonExcluded:
+		return "excluded"
+	case ReasonNoEligibleDancer:
+		return "no eligible dancer"
+	case ReasonMatchingConflict:
+		return "matching conflict"
+	case ReasonPairs:
+		return "pairs"
+	case ReasonNoCaller:
+		return "no caller"
+	case ReasonOtherVersion:
+		return "other version"
+	case ReasonObjective:
+		return "objective"
+	case ReasonInfeasible:
+		return "infeasible"
+	default:
+		return fmt.Sprintf("Unknown Reason: %d", r)
+	}
+}
+
+// Explanation says why a dance isn't in a set, and who could change that.
+type Explanation struct {
+	Dance  *model.Dance
+	Reason Reason
+
+	// Positions are the ones which can't be filled. For a matching conflict,
+	// these are the positions which between them have too few dancers.
+	Positions []*model.Position
+	// Missing is how many more dancers it would take to fill Positions.
+	Missing int
+
+	// Unlockers are dancers who aren't here but who could dance one of
+	// Positions.
+	Unlockers []*model.Dancer
+
+	// Pairs are the pair rules which get in the way, for ReasonPairs.
+	Pairs []Pair
+
+	// Version is the version of the dance which is in the set, for
+	// ReasonOtherVersion.
+	Version *model.Dance
+}
+
+func positionNames(positions []*model.Position) string {
+	s := make([]string, 0, len(positions))
+	for _, position := range positions {
+		s = append(s, position.Name)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func dancerNames(dancers []*model.Dancer) string {
+	s := make([]string, 0, len(dancers))
+	for _, dancer := range dancers {
+		s = append(s, dancer.Name)
+	}
+
+	return strings.Join(s, ", ")
+}
+
+func (e Explanation) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(e.Dance.Name)
+	sb.WriteString(": ")
+
+	switch e.Reason {
+	case ReasonExcluded:
+		sb.WriteString("excluded")
+	case ReasonNoEligibleDancer:
+		sb.WriteString("nobody here can dance ")
+		sb.WriteString(positionNames(e.Positions))
+	case ReasonMatchingConflict:
+		fmt.Fprintf(&sb, "%d more dancer(s) needed for %s", e.Missing, positionNames(e.Positions))
+	case ReasonPairs:
+		sb.WriteString("can't be danced without breaking ")
+		rules := make([]string, 0, len(e.Pairs))
+		for _, pair := range e.Pairs {
+			rules = append(rules, pair.String())
+		}
+		sb.WriteString(strings.Join(rules, ", "))
+	case ReasonNoCaller:
+		sb.WriteString("nobody here can call it")
+	case ReasonOtherVersion:
+		fmt.Fprintf(&sb, "%s is danced instead", e.Version.Name)
+	case ReasonObjective:
+		sb.WriteString("could be danced, but didn't make the set")
+	case ReasonInfeasible:
+		sb.WriteString("could be danced, but no set meets the constraints")
+	}
+
+	if len(e.Unlockers) > 0 {
+		sb.WriteString(" (could be danced by ")
+		sb.WriteString(dancerNames(e.Unlockers))
+		sb.WriteString(")")
+	}
+
+	return sb.String()
+}
+
+// explainPairsLimit is how many assignments Explain looks at to see whether the
+// pair rules rule out a dance.
+const explainPairsLimit = 100000
+
+// pairsInDance returns the pairs where at least one of the dancers could
+// dance in the dance.
+func pairsInDance(checks []pairCheck, eligible [][]int) []Pair {
+	could := make(map[int]struct{})
+	for _, dancers := range eligible {
+		for _, dancer := range dancers {
+			could[dancer] = struct{}{}
+		}
+	}
+
+	var pairs []Pair
+	for _, check := range checks {
+		_, a := could[check.a]
+		_, b := could[check.b]
+		if a || b {
+			pairs = append(pairs, check.pair)
+		}
+	}
+
+	return pairs
+}
+
+func canDance(dp *model.DancerPosition) bool {
+	return dp.Dancer.Active && dp.Preference != model.PreferenceNo
+}
+
+// Explain says why each of `dances` which isn't in `set` was left out. `dps`
+// are the preferences of the dancers who are here, and `status` is how the
+// solve which found `set` ended. `candidates` are preferences for anyone else
+// who might come along; they're used to say who would make a difference, and
+// can be nil.
+func Explain(
+	dances []*model.Dance,
+	dps []*model.DancerPosition,
+	set model.AssignmentSet,
+	status SolverStatus,
+	constraints Constraints,
+	candidates []*model.DancerPosition,
+) []Explanation {
+	excluded := make(map[int]struct{}, len(constraints.Exclude))
+	for _, dance := range constraints.Exclude {
+		excluded[dance.ID] = struct{}{}
+	}
+
+	type key struct {
+		danceID    int
+		positionID int
+	}
+
+	present := make(map[int]struct{})
+	eligible := make(map[key][]int)
+	for _, dp := range dps {
+		present[dp.Dancer.ID] = struct{}{}
+
+		if !canDance(dp) {
+			continue
+		}
+
+		k := key{dp.Dance.ID, dp.Position.PositionID}
+		eligible[k] = append(eligible[k], dp.Dancer.ID)
+	}
+
+	unlockers := make(map[key][]*model.Dancer)
+	for _, dp := range candidates {
+		if _, ok := present[dp.Dancer.ID]; ok || !canDance(dp) {
+			continue
+		}
+
+		k := key{dp.Dance.ID, dp.Position.PositionID}
+		unlockers[k] = append(unlockers[k], dp.Dancer)
+	}
+
+	// everybody who could dance any of the positions, once each, by name
+	unlockersFor := func(dance *model.Dance, positions []*model.Position) []*model.Dancer {
+		seen := make(map[int]struct{})
+		var dancers []*model.Dancer
+
+		for _, position := range positions {
+			for _, dancer := range unlockers[key{dance.ID, position.PositionID}] {
+				if _, ok := seen[dancer.ID]; ok {
+					continue
+				}
+				seen[dancer.ID] = struct{}{}
+				dancers = append(dancers, dancer)
+			}
+		}
+
+		sort.Slice(dancers, func(i, j int) bool { return dancers[i].Name < dancers[j].Name })
+
+		return dancers
+	}
+
+	pairs := activePairs(constraints.Pairs, dps, dancerID)
+	callers := activeCallers(constraints.Callers, dps, dancerID)
+
+	// version -> the dance danced for it
+	versions := make(map[int]*model.Dance)
+	for _, dance := range dances {
+		if dance.IsDanced(set) {
+			versions[dance.VersionOf()] = dance
+		}
+	}
+
+	var explanations []Explanation
+
+	for _, dance := range dances {
+		if dance.IsDanced(set) {
+			continue
+		}
+
+		if _, ok := excluded[dance.ID]; ok {
+			explanations = append(explanations, Explanation{Dance: dance, Reason: ReasonExcluded})
+			continue
+		}
+
+		var unfillable []*model.Position
+		positionEligible := make([][]int, 0, len(dance.Positions))
+		for _, position := range dance.Positions {
+			dancers := eligible[key{dance.ID, position.PositionID}]
+			if len(dancers) == 0 {
+				unfillable = append(unfillable, position)
+			}
+			positionEligible = append(positionEligible, dancers)
+		}
+
+		if len(unfillable) > 0 {
+			explanations = append(explanations, Explanation{
+				Dance:     dance,
+				Reason:    ReasonNoEligibleDancer,
+				Positions: unfillable,
+				Missing:   len(unfillable),
+				Unlockers: unlockersFor(dance, unfillable),
+			})
+			continue
+		}
+
+		m := newMatching(positionEligible)
+		if !m.complete() {
+			blocked := make([]*model.Position, 0, len(dance.Positions))
+			for _, i := range m.blocked() {
+				blocked = append(blocked, dance.Positions[i])
+			}
+
+			explanations = append(explanations, Explanation{
+				Dance:     dance,
+				Reason:    ReasonMatchingConflict,
+				Positions: blocked,
+				Missing:   m.missing(),
+				Unlockers: unlockersFor(dance, blocked),
+			})
+			continue
+		}
+
+		if !canBeCalled(dance, callers[dance.ID], positionEligible) {
+			explanations = append(explanations, Explanation{Dance: dance, Reason: ReasonNoCaller})
+			continue
+		}
+
+		if len(pairs) > 0 && !pairsAllowDance(positionEligible, pairs, facingPositions(dance), explainPairsLimit) {
+			explanations = append(explanations, Explanation{
+				Dance:  dance,
+				Reason: ReasonPairs,
+				Pairs:  pairsInDance(pairs, positionEligible),
+			})
+			continue
+		}
+
+		if version, ok := versions[dance.VersionOf()]; ok {
+			explanations = append(explanations, Explanation{Dance: dance, Reason: ReasonOtherVersion, Version: version})
+			continue
+		}
+
+		reason := ReasonObjective
+		if status == SolverStatusInfeasible {
+			reason = ReasonInfeasible
+		}
+		explanations = append(explanations, Explanation{Dance: dance, Reason: reason})
+	}
+
+	return explanations
+}
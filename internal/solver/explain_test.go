@@ -0,0 +1,112 @@
+package solver
+
+import (
+	"testing"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: false}
+
+	newDance := func(id int, name string, positions ...string) *model.Dance {
+		dance := &model.Dance{ID: id, Name: name}
+		for i, position := range positions {
+			dance.Positions = append(dance.Positions, &model.Position{PositionID: i + 1, Name: position, DanceID: id, Dance: dance})
+		}
+		return dance
+	}
+
+	danced := newDance(1, "Danced", "1")
+	excluded := newDance(2, "Excluded", "1")
+	nobody := newDance(3, "Nobody", "1", "2")
+	conflict := newDance(4, "Conflict", "1", "2", "3")
+	dropped := newDance(5, "Dropped", "1")
+
+	dp := func(dancer *model.Dancer, dance *model.Dance, position int, preference model.DancePreference) *model.DancerPosition {
+		return &model.DancerPosition{
+			Dancer:     dancer,
+			Dance:      dance,
+			Position:   dance.Positions[position-1],
+			Preference: preference,
+		}
+	}
+
+	dps := []*model.DancerPosition{
+		dp(alice, danced, 1, model.PreferenceYes),
+		dp(alice, excluded, 1, model.PreferenceYes),
+		// Dave can't dance as he's inactive, and Bob said no
+		dp(alice, nobody, 1, model.PreferenceYes),
+		dp(bob, nobody, 2, model.PreferenceNo),
+		dp(dave, nobody, 2, model.PreferenceYes),
+		// positions 1 and 2 can only be danced by Alice
+		dp(alice, conflict, 1, model.PreferenceYes),
+		dp(alice, conflict, 2, model.PreferenceYes),
+		dp(bob, conflict, 3, model.PreferenceYes),
+		dp(bob, dropped, 1, model.PreferenceYes),
+	}
+
+	candidates := []*model.DancerPosition{
+		dp(carol, nobody, 2, model.PreferenceMaybe),
+		dp(carol, conflict, 2, model.PreferenceYes),
+		// already here, so not a candidate
+		dp(bob, conflict, 1, model.PreferenceYes),
+	}
+
+	set := model.NewAssignmentSet(
+		model.Assignments{danced: {danced.Positions[0]: alice}},
+		model.DancesDanced{danced: {}},
+	)
+
+	explanations := Explain(
+		[]*model.Dance{danced, excluded, nobody, conflict, dropped},
+		dps,
+		set,
+		SolverStatusOptimal,
+		Constraints{Exclude: []*model.Dance{excluded}},
+		candidates,
+	)
+
+	require.Equal(t, []Explanation{
+		{Dance: excluded, Reason: ReasonExcluded},
+		{
+			Dance:     nobody,
+			Reason:    ReasonNoEligibleDancer,
+			Positions: []*model.Position{nobody.Positions[1]},
+			Missing:   1,
+			Unlockers: []*model.Dancer{carol},
+		},
+		{
+			Dance:     conflict,
+			Reason:    ReasonMatchingConflict,
+			Positions: []*model.Position{conflict.Positions[0], conflict.Positions[1]},
+			Missing:   1,
+			Unlockers: []*model.Dancer{carol},
+		},
+		{Dance: dropped, Reason: ReasonObjective},
+	}, explanations)
+
+	require.Equal(t, "Conflict: 1 more dancer(s) needed for 1, 2 (could be danced by Carol)", explanations[2].String())
+
+	// with no set at all, the dances which could have been danced weren't
+	// left out for a better one
+	explanations = Explain(
+		[]*model.Dance{danced, dropped},
+		dps,
+		model.NewAssignmentSet(model.Assignments{}, model.DancesDanced{}),
+		SolverStatusInfeasible,
+		Constraints{},
+		nil,
+	)
+	require.Equal(t, []Explanation{
+		{Dance: danced, Reason: ReasonInfeasible},
+		{Dance: dropped, Reason: ReasonInfeasible},
+	}, explanations)
+	require.Equal(t, "Dropped: could be danced, but no set meets the constraints", explanations[1].String())
+}
@@ -0,0 +1,804 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// These are the same as the weights in dance_solver.hpp, so both backends agree
+// on what makes a good set.
+const (
+	numDancesPerformedWeight = 1
+	fairnessWeight           = 1
+
+	preferenceMaybeWeight     = 1
+	preferenceYesWeight       = 2
+	preferenceFavouriteWeight = 3
+
+	dancerLimitWeight = 5
+	changeWeight      = 10
+	variantWeight     = 3
+)
+
+// defaultNodeLimit is how many nodes of the search tree the Go solver looks at
+// before settling for the best set it's found so far.
+const defaultNodeLimit = 100000
+
+// goSolver finds sets without needing the C++ library. It does a
+// branch-and-bound search over which dances are in the set. Each dance which
+// goes in gets the best assignment of dancers to positions for the dances
+// chosen so far, found with the Hungarian algorithm, which also tries to even
+// out how many dances each dancer is doing.
+//
+// It follows all of the same rules as the C++ solver and scores sets in the
+// same way, but as it doesn't try every assignment for each dance it can't
+// promise that the set it finds is the best one. So its sets are only ever
+// "feasible", not "optimal".
+type goSolver struct {
+	nodeLimit int
+	options   Options
+}
+
+func newGoSolver(options Options) (Solver, error) {
+	if options.Dump != nil {
+		return nil, fmt.Errorf("dumping the model: %w", ErrUnsupported)
+	}
+
+	return goSolver{nodeLimit: defaultNodeLimit, options: options}, nil
+}
+
+func preferenceWeight(preference model.DancePreference) int64 {
+	switch preference {
+	case model.PreferenceMaybe:
+		return preferenceMaybeWeight
+	case model.PreferenceYes:
+		return preferenceYesWeight
+	case model.PreferenceFavourite:
+		return preferenceFavouriteWeight
+	default:
+		return 0
+	}
+}
+
+// goCandidate is a dancer who can dance a position.
+type goCandidate struct {
+	dancer int
+	weight int64
+}
+
+type goDance struct {
+	dance *model.Dance
+	// position -> who can dance it
+	candidates [][]goCandidate
+	included   bool
+	// position -> the dancer in the set being repaired, or -1
+	previous []int
+	facing   [][2]int
+	// who can call it, if it needs a caller
+	callers []int
+	// the dance it's a version of, and how many dancers it's short of the
+	// largest version
+	version   int
+	shortfall int64
+	// the most the dance could add to the objective
+	bound int64
+}
+
+// goProblem is the data from the model in the form the search wants. Dancers
+// are referred to by their index in `dancers`.
+type goProblem struct {
+	constraints Constraints
+
+	dancers []*model.Dancer
+	limits  []DancerLimit
+	pairs   []pairCheck
+
+	// dance counts are compared as load * loadScales[dancer], and the rest of
+	// the objective is multiplied by weightScale to match
+	loadScales  []int64
+	weightScale int64
+
+	// the dances which can be danced, best first
+	dances []*goDance
+
+	// an included or pinned dance can't be danced
+	infeasible bool
+}
+
+func newGoProblem(logger *logrus.Entry, dps []*model.DancerPosition, constraints Constraints) goProblem {
+	p := goProblem{constraints: constraints}
+
+	dancerIndex := make(map[int]int)
+	type key struct{ danceID, positionID, dancerID int }
+	preferences := make(map[key]model.DancePreference, len(dps))
+
+	for _, dp := range dps {
+		if _, ok := dancerIndex[dp.Dancer.ID]; !ok {
+			dancerIndex[dp.Dancer.ID] = len(p.dancers)
+			p.dancers = append(p.dancers, dp.Dancer)
+		}
+		preferences[key{dp.Dance.ID, dp.Position.PositionID, dp.Dancer.ID}] = dp.Preference
+	}
+
+	p.pairs = activePairs(constraints.Pairs, dps, func(dancer *model.Dancer) int { return dancerIndex[dancer.ID] })
+	callers := activeCallers(constraints.Callers, dps, func(dancer *model.Dancer) int { return dancerIndex[dancer.ID] })
+
+	units := make([]int, len(p.dancers))
+	for i, dancer := range p.dancers {
+		units[i] = dancerWeightUnits
+		if dancer.Active {
+			units[i] = constraints.weightUnitsFor(dancer)
+		}
+	}
+	p.weightScale, p.loadScales = fairnessScales(units)
+
+	p.limits = make([]DancerLimit, len(p.dancers))
+	for dancer, limit := range constraints.DancerLimits {
+		if i, ok := dancerIndex[dancer.ID]; ok {
+			p.limits[i] = limit
+		}
+	}
+
+	excluded := make(map[int]struct{}, len(constraints.Exclude))
+	for _, dance := range constraints.Exclude {
+		excluded[dance.ID] = struct{}{}
+	}
+
+	included := make(map[int]struct{}, len(constraints.Include))
+	for _, dance := range constraints.Include {
+		included[dance.ID] = struct{}{}
+	}
+
+	// dance id -> position id -> dancer index
+	pinned := make(map[int]map[int]int)
+	for _, pin := range constraints.Pins {
+		if pinned[pin.Dance.ID] == nil {
+			pinned[pin.Dance.ID] = make(map[int]int)
+		}
+		pinned[pin.Dance.ID][pin.Position.PositionID] = dancerIndex[pin.Dancer.ID]
+		included[pin.Dance.ID] = struct{}{}
+	}
+
+	// dance id -> position id -> dancer id
+	previous := make(map[int]map[int]int)
+	if constraints.Repair != nil {
+		for _, dance := range constraints.Repair.Dances() {
+			previous[dance.ID] = make(map[int]int)
+			for _, position := range dance.Positions {
+				if dancer := constraints.Repair.DancerFor(dance, position); dancer != nil {
+					previous[dance.ID][position.PositionID] = dancer.ID
+				}
+			}
+		}
+	}
+
+	// version -> the most positions any version of it has
+	largest := make(map[int]int)
+	for _, dance := range danceList(dps) {
+		largest[dance.VersionOf()] = max(largest[dance.VersionOf()], len(dance.Positions))
+	}
+
+	for _, dance := range danceList(dps) {
+		if _, ok := excluded[dance.ID]; ok {
+			continue
+		}
+
+		_, isIncluded := included[dance.ID]
+		d := &goDance{
+			dance:      dance,
+			candidates: make([][]goCandidate, len(dance.Positions)),
+			included:   isIncluded,
+			previous:   make([]int, len(dance.Positions)),
+			facing:     facingPositions(dance),
+			callers:    callers[dance.ID],
+			version:    dance.VersionOf(),
+			shortfall:  int64(largest[dance.VersionOf()] - len(dance.Positions)),
+		}
+		d.bound = numDancesPerformedWeight - d.shortfall*variantWeight
+
+		if dance.Caller != model.CallerNone && len(d.callers) == 0 {
+			logger.WithField("dance", dance.Name).Debug("nobody here can call it")
+			if d.included {
+				p.infeasible = true
+			}
+			continue
+		}
+
+		pins := pinned[dance.ID]
+		pinnedDancers := make(map[int]struct{}, len(pins))
+		for _, dancer := range pins {
+			pinnedDancers[dancer] = struct{}{}
+		}
+
+		eligible := make([][]int, len(dance.Positions))
+		for i, position := range dance.Positions {
+			d.previous[i] = -1
+			if dancerID, ok := previous[dance.ID][position.PositionID]; ok {
+				if index, ok := dancerIndex[dancerID]; ok {
+					d.previous[i] = index
+				}
+			}
+
+			pin, isPinned := pins[position.PositionID]
+
+			var best int64
+			for index, dancer := range p.dancers {
+				if !dancer.Active {
+					continue
+				}
+
+				// pinned positions can only be danced by the pinned dancer,
+				// who can't dance any other position
+				if isPinned && pin != index {
+					continue
+				}
+				if _, ok := pinnedDancers[index]; ok && !isPinned {
+					continue
+				}
+
+				weight := preferenceWeight(preferences[key{dance.ID, position.PositionID, dancer.ID}])
+				if weight == 0 {
+					continue
+				}
+
+				d.candidates[i] = append(d.candidates[i], goCandidate{index, weight})
+				eligible[i] = append(eligible[i], index)
+				if weight > best {
+					best = weight
+				}
+			}
+
+			d.bound += best
+		}
+
+		if !newMatching(eligible).complete() {
+			logger.WithField("dance", dance.Name).Debug("not enough dancers")
+			if d.included {
+				p.infeasible = true
+			}
+			continue
+		}
+
+		p.dances = append(p.dances, d)
+	}
+
+	sort.SliceStable(p.dances, func(i, j int) bool {
+		return p.dances[i].bound > p.dances[j].bound
+	})
+
+	return p
+}
+
+// fairnessScales works out how to compare the dance counts of weighted dancers
+// using whole numbers, in the same way as the C++ solver. `units` are the
+// dancers' weights in quarters. Each dancer's count is multiplied by their
+// scale, which is the overall scale over their weight, and the rest of the
+// objective by the overall scale. Without any weights, they're all 1.
+func fairnessScales(units []int) (int64, []int64) {
+	scale := int64(1)
+	for _, u := range units {
+		n := int64(u / gcd(u, dancerWeightUnits))
+		scale = scale / int64(gcd(int(scale), int(n))) * n
+	}
+
+	scales := make([]int64, len(units))
+	for i, u := range units {
+		scales[i] = scale * dancerWeightUnits / int64(u)
+	}
+
+	return scale, scales
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+// goSearch is the state of the branch-and-bound search.
+type goSearch struct {
+	ctx context.Context
+	p   *goProblem
+
+	nodes     int
+	nodeLimit int
+	// whether the search stopped because `ctx` was cancelled
+	cancelled bool
+
+	// dancer -> how many dances they're doing
+	loads []int
+	// dance -> position -> dancer, nil if the dance isn't being danced
+	chosen [][]int
+	// the dances with a version in the set
+	versions map[int]struct{}
+	// what the chosen dances add to the objective
+	value int64
+
+	found          bool
+	best           [][]int
+	bestObjective  int64
+	remainingBound [][]int64
+
+	// called with each set that's better than the ones before it
+	onImprove func(best [][]int, objective int64)
+}
+
+func newGoSearch(ctx context.Context, p *goProblem, nodeLimit int) *goSearch {
+	s := &goSearch{
+		ctx:       ctx,
+		p:         p,
+		nodeLimit: nodeLimit,
+		loads:     make([]int, len(p.dancers)),
+		chosen:    make([][]int, len(p.dances)),
+		versions:  make(map[int]struct{}),
+	}
+
+	// remainingBound[i][k] is the most the best k of the dances from i onwards
+	// could add
+	s.remainingBound = make([][]int64, len(p.dances)+1)
+	for i := range s.remainingBound {
+		bounds := make([]int64, 0, len(p.dances)-i)
+		for _, d := range p.dances[i:] {
+			// a smaller version of a dance can be worth less than nothing,
+			// but it can always be left out
+			bounds = append(bounds, max(d.bound, 0))
+		}
+		sort.Slice(bounds, func(a, b int) bool { return bounds[a] > bounds[b] })
+
+		sums := make([]int64, len(bounds)+1)
+		for k, bound := range bounds {
+			sums[k+1] = sums[k] + bound
+		}
+		s.remainingBound[i] = sums
+	}
+
+	return s
+}
+
+// assign finds the best way to dance `d`, given the dances chosen so far. It
+// returns the dancer for each position and what that adds to the objective.
+func (s *goSearch) assign(d *goDance) ([]int, int64, bool) {
+	// the preference weights are scaled up so that evening out the dance
+	// counts only ever breaks ties between otherwise equal assignments
+	maxLoadScale := int64(1)
+	for _, loadScale := range s.p.loadScales {
+		maxLoadScale = max(maxLoadScale, loadScale)
+	}
+	scale := int64(len(s.p.dances)+1) * maxLoadScale
+
+	weights := make([][]int64, len(d.candidates))
+	eligible := make([][]int, len(d.candidates))
+
+	for i, candidates := range d.candidates {
+		weights[i] = make([]int64, len(s.p.dancers))
+		for j := range weights[i] {
+			weights[i][j] = forbidden
+		}
+
+		for _, candidate := range candidates {
+			dancer := candidate.dancer
+			load := s.loads[dancer]
+			limit := s.p.limits[dancer]
+
+			if !limit.Soft && limit.MaxDances > 0 && load >= limit.MaxDances {
+				continue
+			}
+
+			weight := candidate.weight * scale
+			if d.previous[i] == dancer {
+				// keeping an assignment saves making one change and undoing
+				// another
+				weight += 2 * changeWeight * scale
+			}
+			if limit.MaxDances > 0 && load >= limit.MaxDances {
+				weight -= dancerLimitWeight * scale
+			}
+			if load < limit.MinDances {
+				weight += dancerLimitWeight * scale
+			}
+			weight -= int64(load) * s.p.loadScales[dancer]
+
+			weights[i][dancer] = weight
+			eligible[i] = append(eligible[i], dancer)
+		}
+	}
+
+	assignment, ok := s.bestAssignment(d, weights, eligible, maxFixes)
+	if !ok {
+		return nil, 0, false
+	}
+
+	gain := numDancesPerformedWeight - d.shortfall*variantWeight
+	for i, dancer := range assignment {
+		for _, candidate := range d.candidates[i] {
+			if candidate.dancer == dancer {
+				gain += candidate.weight
+				break
+			}
+		}
+	}
+
+	return assignment, gain, true
+}
+
+// maxFixes is how many pair rules or missing callers the Go solver will put
+// right in one assignment before giving up on the dance.
+const maxFixes = 3
+
+// goRemoval takes a dancer out of a position.
+type goRemoval struct{ dancer, position int }
+
+// bestAssignment finds the best assignment of dancers to the positions of `d`.
+// If it breaks a pair rule or leaves the dance without a caller, it tries
+// again with each of the ways of putting that right, and keeps the best which
+// doesn't break anything. That happens up to `fixes` times over.
+func (s *goSearch) bestAssignment(d *goDance, weights [][]int64, eligible [][]int, fixes int) ([]int, bool) {
+	if !newMatching(eligible).complete() {
+		return nil, false
+	}
+
+	assignment := maxWeightAssignment(weights, len(s.p.dancers))
+
+	fixOptions, wrong := s.fixesFor(d, assignment, eligible)
+	if !wrong {
+		return assignment, true
+	}
+	if fixes == 0 {
+		return nil, false
+	}
+
+	var best []int
+	var bestWeight int64
+	for _, removals := range fixOptions {
+		fixedWeights := make([][]int64, len(weights))
+		fixedEligible := make([][]int, len(eligible))
+		for position := range weights {
+			fixedWeights[position] = append([]int64(nil), weights[position]...)
+			fixedEligible[position] = append([]int(nil), eligible[position]...)
+		}
+
+		for _, r := range removals {
+			fixedWeights[r.position][r.dancer] = forbidden
+			kept := fixedEligible[r.position][:0]
+			for _, dancer := range fixedEligible[r.position] {
+				if dancer != r.dancer {
+					kept = append(kept, dancer)
+				}
+			}
+			fixedEligible[r.position] = kept
+		}
+
+		fixed, ok := s.bestAssignment(d, fixedWeights, fixedEligible, fixes-1)
+		if !ok {
+			continue
+		}
+
+		var weight int64
+		for position, dancer := range fixed {
+			weight += weights[position][dancer]
+		}
+		if best == nil || weight > bestWeight {
+			best, bestWeight = fixed, weight
+		}
+	}
+
+	return best, best != nil
+}
+
+// fixesFor returns the ways of putting right the first thing that's wrong with
+// `assignment`, each as the removals which might fix it. It returns false if
+// nothing is wrong.
+func (s *goSearch) fixesFor(d *goDance, assignment []int, eligible [][]int) ([][]goRemoval, bool) {
+	everywhere := func(dancer int) []goRemoval {
+		r := make([]goRemoval, 0, len(eligible))
+		for position := range eligible {
+			r = append(r, goRemoval{dancer, position})
+		}
+		return r
+	}
+
+	// bringIn returns the ways of getting `dancer` into the dance, by taking
+	// everybody else out of a position they can dance
+	bringIn := func(dancer int) [][]goRemoval {
+		var options [][]goRemoval
+		for position, dancers := range eligible {
+			var others []goRemoval
+			canDance := false
+			for _, other := range dancers {
+				if other == dancer {
+					canDance = true
+				} else {
+					others = append(others, goRemoval{other, position})
+				}
+			}
+			if canDance {
+				options = append(options, others)
+			}
+		}
+		return options
+	}
+
+	if check, ia, ib, broken := firstBrokenPair(s.p.pairs, assignment, d.facing); broken {
+		switch check.pair.Rule {
+		case model.PairApart:
+			return [][]goRemoval{everywhere(check.a), everywhere(check.b)}, true
+		case model.PairNotFacing:
+			return [][]goRemoval{{{check.a, ia}}, {{check.b, ib}}}, true
+		case model.PairTogether:
+			in, out := check.a, check.b
+			if ia == -1 {
+				in, out = check.b, check.a
+			}
+
+			// either leave them both out, or bring the other one in
+			return append([][]goRemoval{everywhere(in)}, bringIn(out)...), true
+		}
+	}
+
+	if d.dance.Caller == model.CallerNone {
+		return nil, false
+	}
+	if _, ok := chooseCaller(d.dance.Caller, d.callers, assignment); ok {
+		return nil, false
+	}
+
+	var options [][]goRemoval
+	for _, caller := range d.callers {
+		switch d.dance.Caller {
+		case model.CallerFromDancers:
+			options = append(options, bringIn(caller)...)
+		case model.CallerStandingOut:
+			options = append(options, everywhere(caller))
+		}
+	}
+
+	return options, true
+}
+
+// scaledLoad is how many dances `dancer` is doing, scaled by their weight.
+func (s *goSearch) scaledLoad(dancer int) int64 {
+	return int64(s.loads[dancer]) * s.p.loadScales[dancer]
+}
+
+// evaluate scores a complete set, in the same way as the C++ solver does.
+func (s *goSearch) evaluate(numChosen int) (int64, bool) {
+	if numChosen == 0 {
+		return 0, false
+	}
+
+	objective := s.value
+
+	for dancer, limit := range s.p.limits {
+		load := s.loads[dancer]
+		if limit.MinDances > 0 && load < limit.MinDances {
+			if !limit.Soft {
+				return 0, false
+			}
+			objective -= int64(limit.MinDances-load) * dancerLimitWeight
+		}
+		if limit.MaxDances > 0 && load > limit.MaxDances {
+			objective -= int64(load-limit.MaxDances) * dancerLimitWeight
+		}
+	}
+
+	if s.p.constraints.Repair != nil {
+		changes := 0
+		for i, d := range s.p.dances {
+			for position, previous := range d.previous {
+				current := -1
+				if s.chosen[i] != nil {
+					current = s.chosen[i][position]
+				}
+
+				if previous != -1 && current != previous {
+					changes++
+				}
+				if current != -1 && current != previous {
+					changes++
+				}
+			}
+		}
+		objective -= int64(changes) * changeWeight
+	}
+
+	objective *= s.p.weightScale
+
+	minLoad, maxLoad := s.scaledLoad(0), s.scaledLoad(0)
+	for dancer := range s.loads {
+		minLoad = min(minLoad, s.scaledLoad(dancer))
+		maxLoad = max(maxLoad, s.scaledLoad(dancer))
+	}
+	objective -= (maxLoad - minLoad) * fairnessWeight
+
+	return objective, true
+}
+
+func (s *goSearch) search(i int, numChosen int) {
+	if s.nodes >= s.nodeLimit || s.cancelled {
+		return
+	}
+	s.nodes++
+
+	if s.ctx.Err() != nil {
+		s.cancelled = true
+		return
+	}
+
+	maxDances := s.p.constraints.MaxDances
+	remaining := len(s.p.dances) - i
+
+	if numChosen+remaining < s.p.constraints.MinDances {
+		return
+	}
+
+	slots := remaining
+	if maxDances > 0 {
+		slots = min(slots, maxDances-numChosen)
+	}
+	if s.found && (s.value+s.remainingBound[i][slots])*s.p.weightScale <= s.bestObjective {
+		return
+	}
+
+	if i == len(s.p.dances) {
+		objective, ok := s.evaluate(numChosen)
+		if ok && (!s.found || objective > s.bestObjective) {
+			s.found = true
+			s.bestObjective = objective
+			s.best = make([][]int, len(s.chosen))
+			copy(s.best, s.chosen)
+
+			if s.onImprove != nil {
+				s.onImprove(s.best, s.bestObjective)
+			}
+		}
+		return
+	}
+
+	d := s.p.dances[i]
+
+	// only one version of each dance can be danced
+	_, versionChosen := s.versions[d.version]
+
+	// try dancing it first, as good sets tend to have lots of dances
+	if (maxDances == 0 || numChosen < maxDances) && !versionChosen {
+		if assignment, gain, ok := s.assign(d); ok {
+			for _, dancer := range assignment {
+				s.loads[dancer]++
+			}
+			s.chosen[i] = assignment
+			s.value += gain
+			s.versions[d.version] = struct{}{}
+
+			s.search(i+1, numChosen+1)
+
+			delete(s.versions, d.version)
+			s.value -= gain
+			s.chosen[i] = nil
+			for _, dancer := range assignment {
+				s.loads[dancer]--
+			}
+		}
+	}
+
+	if !d.included {
+		s.search(i+1, numChosen)
+	}
+}
+
+// set turns the chosen assignments from a search into a set of `dances`. A nil
+// `chosen` is the empty set.
+func (p *goProblem) set(dances []*model.Dance, chosen [][]int) model.AssignmentSet {
+	as := make(model.Assignments)
+	dd := make(model.DancesDanced)
+	callers := make(model.Callers)
+	for _, dance := range dances {
+		as[dance] = make(map[*model.Position]*model.Dancer)
+		for _, position := range dance.Positions {
+			as[dance][position] = nil
+		}
+	}
+
+	for i, assignment := range chosen {
+		if assignment == nil {
+			continue
+		}
+
+		dance := p.dances[i].dance
+		dd[dance] = struct{}{}
+		for position, dancer := range assignment {
+			as[dance][dance.Positions[position]] = p.dancers[dancer]
+		}
+
+		if dance.Caller != model.CallerNone {
+			if caller, ok := chooseCaller(dance.Caller, p.dances[i].callers, assignment); ok {
+				callers[dance] = p.dancers[caller]
+			}
+		}
+	}
+
+	return model.NewAssignmentSet(as, dd).WithCallers(callers)
+}
+
+func (g goSolver) Solve(ctx context.Context, logger *logrus.Entry, dps []*model.DancerPosition, constraints Constraints) (SolveResult, error) {
+	if err := constraints.check(dps); err != nil {
+		return SolveResult{}, err
+	}
+
+	constraints.warnAbsentLimits(logger, dps)
+
+	p := newGoProblem(logger, dps, constraints)
+	dances := danceList(dps)
+
+	result := SolveResult{
+		Set:    p.set(dances, nil),
+		Status: SolverStatusInfeasible,
+	}
+
+	if !p.infeasible {
+		s := newGoSearch(ctx, &p, g.nodeLimit)
+		if g.options.Progress != nil {
+			s.onImprove = func(best [][]int, objective int64) {
+				g.options.Progress(SolveResult{
+					Set:       p.set(dances, best),
+					Status:    SolverStatusFeasible,
+					Objective: objective,
+				})
+			}
+		}
+		s.search(0, 0)
+
+		logger.WithFields(logrus.Fields{
+			"nodes":     s.nodes,
+			"found":     s.found,
+			"cancelled": s.cancelled,
+		}).Debug("finished search")
+
+		if s.found {
+			result.Set = p.set(dances, s.best)
+			result.Status = SolverStatusFeasible
+			result.Objective = s.bestObjective
+		}
+		if s.cancelled {
+			result.Status = SolverStatusCancelled
+		}
+	}
+
+	result.Explanations = Explain(dances, dps, result.Set, result.Status, constraints, nil)
+
+	return result, nil
+}
+
+func (g goSolver) SolveAlternatives(
+	ctx context.Context,
+	logger *logrus.Entry,
+	dps []*model.DancerPosition,
+	constraints Constraints,
+	n int,
+	minDifference int,
+	difference AlternativeDifference,
+) ([]SolveResult, error) {
+	if err := checkAlternatives(n, minDifference); err != nil {
+		return nil, err
+	}
+
+	if n > 1 {
+		return nil, fmt.Errorf("the %s solver can't find alternative sets: %w", BackendGo, ErrUnsupported)
+	}
+
+	result, err := g.Solve(ctx, logger, dps, constraints)
+	if err != nil {
+		return nil, err
+	}
+
+	return []SolveResult{result}, nil
+}
+
+func (goSolver) SolveSeason(ctx context.Context, logger *logrus.Entry, events []SeasonEvent, constraints Constraints) ([]SolveResult, error) {
+	return nil, fmt.Errorf("the %s solver can't solve a season: %w", BackendGo, ErrUnsupported)
+}
@@ -0,0 +1,575 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxWeightAssignment(t *testing.T) {
+	t.Parallel()
+
+	weights := [][]int64{
+		{3, 2, forbidden},
+		{3, forbidden, forbidden},
+	}
+
+	require.Equal(t, []int{1, 0}, maxWeightAssignment(weights, 3))
+}
+
+// goTestDances makes dances with the given number of positions, named after
+// their index.
+func goTestDances(positions ...int) []*model.Dance {
+	dances := make([]*model.Dance, 0, len(positions))
+	for i, n := range positions {
+		dance := &model.Dance{ID: i + 1, Name: string(rune('A' + i))}
+		for p := 1; p <= n; p++ {
+			dance.Positions = append(dance.Positions, &model.Position{PositionID: p, Name: string(rune('0' + p)), DanceID: dance.ID, Dance: dance})
+		}
+		dances = append(dances, dance)
+	}
+
+	return dances
+}
+
+func goTestDP(dancer *model.Dancer, dance *model.Dance, position int, preference model.DancePreference) *model.DancerPosition {
+	return &model.DancerPosition{
+		Dancer:     dancer,
+		Dance:      dance,
+		Position:   dance.Positions[position-1],
+		Preference: preference,
+	}
+}
+
+func TestGoSolver(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: false}
+
+	dances := goTestDances(2, 2, 1)
+	a, b, c := dances[0], dances[1], dances[2]
+
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceFavourite),
+		goTestDP(alice, a, 2, model.PreferenceYes),
+		goTestDP(bob, a, 1, model.PreferenceYes),
+		goTestDP(bob, a, 2, model.PreferenceNo),
+		// only Alice can dance B, so it needs Dave, who isn't active
+		goTestDP(alice, b, 1, model.PreferenceYes),
+		goTestDP(dave, b, 2, model.PreferenceYes),
+		goTestDP(carol, c, 1, model.PreferenceMaybe),
+	}
+
+	solver, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+
+	logger := logrus.WithField("test-name", t.Name())
+
+	t.Run("no constraints", func(t *testing.T) {
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{})
+		require.NoError(t, err)
+		require.Equal(t, SolverStatusFeasible, result.Status)
+
+		set := result.Set
+		require.Equal(t, 2, set.NumDancesDanced())
+		require.True(t, a.IsDanced(set))
+		require.False(t, b.IsDanced(set))
+		require.True(t, c.IsDanced(set))
+
+		// Bob has said no to position 2
+		require.Equal(t, bob, set.DancerFor(a, a.Positions[0]))
+		require.Equal(t, alice, set.DancerFor(a, a.Positions[1]))
+		require.Equal(t, carol, set.DancerFor(c, c.Positions[0]))
+
+		require.Len(t, result.Explanations, 1)
+		require.Equal(t, b, result.Explanations[0].Dance)
+	})
+
+	t.Run("maximum number of dances", func(t *testing.T) {
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{MaxDances: 1})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Set.NumDancesDanced())
+		require.True(t, a.IsDanced(result.Set))
+	})
+
+	t.Run("exclude", func(t *testing.T) {
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{Exclude: []*model.Dance{a}})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Set.NumDancesDanced())
+		require.True(t, c.IsDanced(result.Set))
+	})
+
+	t.Run("hard dancer limit", func(t *testing.T) {
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{
+			DancerLimits: map[*model.Dancer]DancerLimit{carol: {MaxDances: 1}, bob: {MinDances: 2}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, SolverStatusInfeasible, result.Status)
+		require.Equal(t, 0, result.Set.NumDancesDanced())
+		var reasons []Reason
+		for _, explanation := range result.Explanations {
+			reasons = append(reasons, explanation.Reason)
+		}
+		require.Contains(t, reasons, ReasonInfeasible)
+		require.NotContains(t, reasons, ReasonObjective)
+	})
+
+	t.Run("limits for absent dancers", func(t *testing.T) {
+		erin := &model.Dancer{ID: 5, Name: "Erin", Active: true}
+
+		nullLogger, hook := test.NewNullLogger()
+		result, err := solver.Solve(context.Background(), logrus.NewEntry(nullLogger), dps, Constraints{
+			DancerLimits: map[*model.Dancer]DancerLimit{erin: {MinDances: 1}, bob: {MaxDances: 1}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, SolverStatusFeasible, result.Status)
+
+		// Erin isn't here, so their limit can't be kept to
+		require.Len(t, hook.Entries, 1)
+		require.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+		require.Equal(t, "Erin isn't here, so can't dance at least 1 dance", hook.LastEntry().Message)
+	})
+
+	t.Run("soft dancer limit", func(t *testing.T) {
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{
+			DancerLimits: map[*model.Dancer]DancerLimit{bob: {MinDances: 2, Soft: true}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, SolverStatusFeasible, result.Status)
+		require.Equal(t, 2, result.Set.NumDancesDanced())
+	})
+
+	t.Run("pin", func(t *testing.T) {
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{
+			Pins: []Pin{{Dancer: alice, Dance: a, Position: a.Positions[0]}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, SolverStatusInfeasible, result.Status)
+	})
+
+	t.Run("repair", func(t *testing.T) {
+		previous := model.NewAssignmentSet(
+			model.Assignments{a: {a.Positions[0]: bob, a.Positions[1]: alice}},
+			model.DancesDanced{a: {}},
+		)
+
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{Repair: &previous})
+		require.NoError(t, err)
+		require.Equal(t, SolverStatusFeasible, result.Status)
+
+		// adding C would make the set better, but isn't worth the change
+		set := result.Set
+		require.Equal(t, 1, set.NumDancesDanced())
+		require.Equal(t, bob, set.DancerFor(a, a.Positions[0]))
+		require.Equal(t, alice, set.DancerFor(a, a.Positions[1]))
+	})
+
+	t.Run("alternatives aren't supported", func(t *testing.T) {
+		_, err := solver.SolveAlternatives(context.Background(), logger, dps, Constraints{}, 2, 1, AlternativeDifferenceDances)
+		require.True(t, errors.Is(err, ErrUnsupported))
+	})
+
+	t.Run("seasons aren't supported", func(t *testing.T) {
+		_, err := solver.SolveSeason(context.Background(), logger, []SeasonEvent{{Name: "Week 1", DancerPositions: dps}}, Constraints{})
+		require.True(t, errors.Is(err, ErrUnsupported))
+	})
+}
+
+func TestGoSolverSharesDancesOut(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+
+	dances := goTestDances(1, 1)
+	var dps []*model.DancerPosition
+	for _, dance := range dances {
+		dps = append(dps,
+			goTestDP(alice, dance, 1, model.PreferenceYes),
+			goTestDP(bob, dance, 1, model.PreferenceYes),
+		)
+	}
+
+	solver, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+
+	result, err := solver.Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Set.NumDancesDanced())
+	require.NotEqual(t,
+		result.Set.DancerFor(dances[0], dances[0].Positions[0]),
+		result.Set.DancerFor(dances[1], dances[1].Positions[0]),
+	)
+}
+
+func TestGoSolverWeights(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+
+	dances := goTestDances(1, 1, 1)
+	var dps []*model.DancerPosition
+	for _, dance := range dances {
+		dps = append(dps,
+			goTestDP(alice, dance, 1, model.PreferenceYes),
+			goTestDP(bob, dance, 1, model.PreferenceYes),
+		)
+	}
+
+	solver, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+
+	logger := logrus.WithField("test-name", t.Name())
+
+	count := func(set model.AssignmentSet, dancer *model.Dancer) int {
+		n := 0
+		for _, dance := range dances {
+			if set.DancerFor(dance, dance.Positions[0]) == dancer {
+				n++
+			}
+		}
+		return n
+	}
+
+	for _, weighted := range []*model.Dancer{alice, bob} {
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{
+			Weights: map[*model.Dancer]float64{weighted: 2},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, result.Set.NumDancesDanced())
+		require.Equal(t, 2, count(result.Set, weighted), "%s should have twice as many dances", weighted.Name)
+	}
+
+	// half as many is the same as the other one having twice as many
+	result, err := solver.Solve(context.Background(), logger, dps, Constraints{
+		Weights: map[*model.Dancer]float64{alice: 0.5},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, count(result.Set, bob))
+
+	_, err = solver.Solve(context.Background(), logger, dps, Constraints{
+		Weights: map[*model.Dancer]float64{alice: 0.1},
+	})
+	require.ErrorContains(t, err, "Alice's weight must be between 0.25 and 4, not 0.1")
+}
+
+func TestFairnessScales(t *testing.T) {
+	t.Parallel()
+
+	scale, scales := fairnessScales([]int{4, 4})
+	require.Equal(t, int64(1), scale)
+	require.Equal(t, []int64{1, 1}, scales)
+
+	// weights of 1, 2, 0.5 and 0.75
+	scale, scales = fairnessScales([]int{4, 8, 2, 3})
+	require.Equal(t, int64(6), scale)
+	require.Equal(t, []int64{6, 3, 12, 8}, scales)
+}
+
+func TestGoSolverPairs(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: false}
+	erin := &model.Dancer{ID: 5, Name: "Erin", Active: true}
+
+	// positions 1 and 2 face each other
+	a := goTestDances(3)[0]
+	a.Positions[0].FacingID = 2
+
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceFavourite),
+		goTestDP(carol, a, 2, model.PreferenceFavourite),
+		goTestDP(carol, a, 3, model.PreferenceYes),
+		goTestDP(bob, a, 2, model.PreferenceYes),
+		goTestDP(bob, a, 3, model.PreferenceFavourite),
+		goTestDP(erin, a, 2, model.PreferenceYes),
+		goTestDP(dave, a, 3, model.PreferenceYes),
+	}
+
+	solver, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+
+	logger := logrus.WithField("test-name", t.Name())
+
+	for _, tc := range []struct {
+		name     string
+		pairs    []Pair
+		expected []*model.Dancer
+	}{
+		{name: "no pairs", expected: []*model.Dancer{alice, carol, bob}},
+		{
+			name:     "not facing",
+			pairs:    []Pair{{Dancer: alice, Other: carol, Rule: model.PairNotFacing}},
+			expected: []*model.Dancer{alice, erin, bob},
+		},
+		{
+			name:     "apart",
+			pairs:    []Pair{{Dancer: alice, Other: bob, Rule: model.PairApart}},
+			expected: []*model.Dancer{alice, erin, carol},
+		},
+		{
+			name:     "together",
+			pairs:    []Pair{{Dancer: erin, Other: alice, Rule: model.PairTogether}},
+			expected: []*model.Dancer{alice, erin, bob},
+		},
+		{
+			// Dave isn't here, so Carol can dance without him
+			name:     "together with somebody who isn't here",
+			pairs:    []Pair{{Dancer: carol, Other: dave, Rule: model.PairTogether}},
+			expected: []*model.Dancer{alice, carol, bob},
+		},
+		{
+			name: "impossible",
+			pairs: []Pair{
+				{Dancer: alice, Other: bob, Rule: model.PairApart},
+				{Dancer: alice, Other: erin, Rule: model.PairApart},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := solver.Solve(context.Background(), logger, dps, Constraints{Pairs: tc.pairs})
+			require.NoError(t, err)
+
+			if tc.expected == nil {
+				require.False(t, a.IsDanced(result.Set))
+				require.Len(t, result.Explanations, 1)
+				require.Equal(t, ReasonPairs, result.Explanations[0].Reason)
+				require.Equal(t, "A: can't be danced without breaking Alice and Bob never in the same dance, Alice and Erin never in the same dance", result.Explanations[0].String())
+				return
+			}
+
+			require.True(t, a.IsDanced(result.Set))
+			for i, dancer := range tc.expected {
+				require.Equal(t, dancer, result.Set.DancerFor(a, a.Positions[i]), "position %d", i+1)
+			}
+			require.Empty(t, Validate(result.Set, dps, Constraints{Pairs: tc.pairs}))
+		})
+	}
+}
+
+func TestGoSolverCallers(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: false}
+
+	a := goTestDances(2)[0]
+
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceFavourite),
+		goTestDP(bob, a, 2, model.PreferenceFavourite),
+		goTestDP(carol, a, 2, model.PreferenceYes),
+		goTestDP(dave, a, 2, model.PreferenceYes),
+	}
+
+	solver, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+
+	logger := logrus.WithField("test-name", t.Name())
+
+	for _, tc := range []struct {
+		name     string
+		rule     model.CallerRule
+		callers  []*model.Dancer
+		expected []*model.Dancer
+		caller   *model.Dancer
+	}{
+		{name: "no caller needed", callers: []*model.Dancer{carol}, expected: []*model.Dancer{alice, bob}},
+		{
+			name:     "from the dancers",
+			rule:     model.CallerFromDancers,
+			callers:  []*model.Dancer{carol},
+			expected: []*model.Dancer{alice, carol},
+			caller:   carol,
+		},
+		{
+			name:     "standing out",
+			rule:     model.CallerStandingOut,
+			callers:  []*model.Dancer{bob},
+			expected: []*model.Dancer{alice, carol},
+			caller:   bob,
+		},
+		{
+			name:     "anyone",
+			rule:     model.CallerAnyone,
+			callers:  []*model.Dancer{bob, carol},
+			expected: []*model.Dancer{alice, bob},
+			caller:   bob,
+		},
+		{
+			// Dave isn't here, so he can't call it
+			name:    "nobody can call it",
+			rule:    model.CallerFromDancers,
+			callers: []*model.Dancer{dave},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			a.Caller = tc.rule
+			var callers []Caller
+			for _, dancer := range tc.callers {
+				callers = append(callers, Caller{Dancer: dancer, Dance: a})
+			}
+			constraints := Constraints{Callers: callers}
+
+			result, err := solver.Solve(context.Background(), logger, dps, constraints)
+			require.NoError(t, err)
+
+			if tc.expected == nil {
+				require.False(t, a.IsDanced(result.Set))
+				require.Len(t, result.Explanations, 1)
+				require.Equal(t, ReasonNoCaller, result.Explanations[0].Reason)
+				require.Equal(t, "A: nobody here can call it", result.Explanations[0].String())
+				return
+			}
+
+			require.True(t, a.IsDanced(result.Set))
+			for i, dancer := range tc.expected {
+				require.Equal(t, dancer, result.Set.DancerFor(a, a.Positions[i]), "position %d", i+1)
+			}
+			require.Equal(t, tc.caller, result.Set.CallerFor(a))
+			require.Empty(t, Validate(result.Set, dps, constraints))
+		})
+	}
+}
+
+func TestGoSolverVariants(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	absentCarol := &model.Dancer{ID: 3, Name: "Carol", Active: false}
+
+	// B is the two person version of A
+	dances := goTestDances(3, 2)
+	a, b := dances[0], dances[1]
+	b.VariantOf = a.ID
+
+	dpsWith := func(carol *model.Dancer) []*model.DancerPosition {
+		return []*model.DancerPosition{
+			goTestDP(alice, a, 1, model.PreferenceYes),
+			goTestDP(bob, a, 2, model.PreferenceYes),
+			goTestDP(carol, a, 3, model.PreferenceYes),
+			goTestDP(alice, b, 1, model.PreferenceFavourite),
+			goTestDP(bob, b, 2, model.PreferenceFavourite),
+		}
+	}
+
+	solver, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+
+	logger := logrus.WithField("test-name", t.Name())
+
+	t.Run("largest version", func(t *testing.T) {
+		dps := dpsWith(carol)
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{})
+		require.NoError(t, err)
+
+		require.True(t, a.IsDanced(result.Set))
+		require.False(t, b.IsDanced(result.Set))
+		require.Len(t, result.Explanations, 1)
+		require.Equal(t, ReasonOtherVersion, result.Explanations[0].Reason)
+		require.Equal(t, "B: A is danced instead", result.Explanations[0].String())
+		require.Empty(t, Validate(result.Set, dps, Constraints{}))
+	})
+
+	t.Run("not enough dancers for the largest version", func(t *testing.T) {
+		dps := dpsWith(absentCarol)
+		result, err := solver.Solve(context.Background(), logger, dps, Constraints{})
+		require.NoError(t, err)
+
+		require.False(t, a.IsDanced(result.Set))
+		require.True(t, b.IsDanced(result.Set))
+		require.Empty(t, Validate(result.Set, dps, Constraints{}))
+	})
+
+	t.Run("both versions included", func(t *testing.T) {
+		_, err := solver.Solve(context.Background(), logger, dpsWith(carol), Constraints{Include: []*model.Dance{a, b}})
+		require.ErrorContains(t, err, "versions of the same dance")
+	})
+}
+
+func TestGoSolverProgress(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+
+	dances := goTestDances(2, 1, 1)
+	var dps []*model.DancerPosition
+	for _, dance := range dances {
+		for position := range dance.Positions {
+			dps = append(dps,
+				goTestDP(alice, dance, position+1, model.PreferenceYes),
+				goTestDP(bob, dance, position+1, model.PreferenceMaybe),
+			)
+		}
+	}
+
+	var progress []SolveResult
+	solver, err := New(BackendGo, Options{Progress: func(result SolveResult) {
+		progress = append(progress, result)
+	}})
+	require.NoError(t, err)
+
+	result, err := solver.Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, progress)
+	for i := 1; i < len(progress); i++ {
+		require.Greater(t, progress[i].Objective, progress[i-1].Objective)
+	}
+	last := progress[len(progress)-1]
+	require.Equal(t, SolverStatusFeasible, last.Status)
+	require.Equal(t, result.Objective, last.Objective)
+	require.Equal(t, result.Set.NumDancesDanced(), last.Set.NumDancesDanced())
+}
+
+func TestGoSolverCancelled(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	dances := goTestDances(1)
+	dps := []*model.DancerPosition{goTestDP(alice, dances[0], 1, model.PreferenceYes)}
+
+	solver, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := solver.Solve(ctx, logrus.WithField("test-name", t.Name()), dps, Constraints{})
+	require.NoError(t, err)
+	require.Equal(t, SolverStatusCancelled, result.Status)
+	require.Equal(t, 0, result.Set.NumDancesDanced())
+}
+
+func TestNewUnknownBackend(t *testing.T) {
+	t.Parallel()
+
+	_, err := New("abacus", Options{})
+	require.Error(t, err)
+}
+
+func TestGoSolverCantDump(t *testing.T) {
+	t.Parallel()
+
+	_, err := New(BackendGo, Options{Dump: &Dump{ModelPath: "model.pbtxt"}})
+	require.True(t, errors.Is(err, ErrUnsupported))
+}
@@ -0,0 +1,90 @@
+package solver
+
+import "math"
+
+// forbidden is the weight of a pairing which can't be made. It's low enough
+// that the best assignment never uses one if there's any other way, but not so
+// low that the sums below overflow.
+const forbidden = -(1 << 40)
+
+// maxWeightAssignment assigns each row a different column, so that the total
+// weight is as high as possible. There must be no more rows than columns. It
+// returns the column for each row. This is the Hungarian algorithm, working on
+// costs (negated weights).
+func maxWeightAssignment(weights [][]int64, columns int) []int {
+	rows := len(weights)
+
+	// everything is 1-indexed, with row/column 0 as a sentinel
+	u := make([]int64, rows+1)
+	v := make([]int64, columns+1)
+	rowFor := make([]int, columns+1)
+	way := make([]int, columns+1)
+
+	cost := func(row, column int) int64 {
+		return -weights[row-1][column-1]
+	}
+
+	for row := 1; row <= rows; row++ {
+		rowFor[0] = row
+		column := 0
+
+		minv := make([]int64, columns+1)
+		used := make([]bool, columns+1)
+		for j := range minv {
+			minv[j] = math.MaxInt64
+		}
+
+		for {
+			used[column] = true
+			row0 := rowFor[column]
+			delta := int64(math.MaxInt64)
+			next := 0
+
+			for j := 1; j <= columns; j++ {
+				if used[j] {
+					continue
+				}
+
+				cur := cost(row0, j) - u[row0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = column
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					next = j
+				}
+			}
+
+			for j := 0; j <= columns; j++ {
+				if used[j] {
+					u[rowFor[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+
+			column = next
+			if rowFor[column] == 0 {
+				break
+			}
+		}
+
+		// follow the augmenting path back
+		for column != 0 {
+			previous := way[column]
+			rowFor[column] = rowFor[previous]
+			column = previous
+		}
+	}
+
+	columnFor := make([]int, rows)
+	for j := 1; j <= columns; j++ {
+		if rowFor[j] != 0 {
+			columnFor[rowFor[j]-1] = j - 1
+		}
+	}
+
+	return columnFor
+}
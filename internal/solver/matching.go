@@ -0,0 +1,106 @@
+package solver
+
+import "sort"
+
+// matching is a maximum bipartite matching between the positions in a dance
+// and the dancers who can dance them. Positions are indexes into `eligible`,
+// and dancers are their IDs.
+type matching struct {
+	// position -> dancers who can dance it
+	eligible [][]int
+
+	// position -> dancer, or -1 if the position isn't filled
+	dancerFor []int
+	// dancer -> position
+	positionFor map[int]int
+}
+
+func newMatching(eligible [][]int) *matching {
+	m := &matching{
+		eligible:    eligible,
+		dancerFor:   make([]int, len(eligible)),
+		positionFor: make(map[int]int),
+	}
+
+	for position := range m.dancerFor {
+		m.dancerFor[position] = -1
+	}
+
+	// Kuhn's algorithm: try to find an augmenting path from each position in
+	// turn
+	for position := range eligible {
+		m.augment(position, make(map[int]struct{}))
+	}
+
+	return m
+}
+
+func (m *matching) augment(position int, seen map[int]struct{}) bool {
+	for _, dancer := range m.eligible[position] {
+		if _, ok := seen[dancer]; ok {
+			continue
+		}
+		seen[dancer] = struct{}{}
+
+		other, taken := m.positionFor[dancer]
+		if !taken || m.augment(other, seen) {
+			m.dancerFor[position] = dancer
+			m.positionFor[dancer] = position
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// complete is true if every position is filled.
+func (m *matching) complete() bool {
+	return len(m.positionFor) == len(m.eligible)
+}
+
+// missing is how many positions can't be filled.
+func (m *matching) missing() int {
+	return len(m.eligible) - len(m.positionFor)
+}
+
+// blocked returns the positions which are stopping the matching from being
+// complete. These are the ones we can reach from an unfilled position by
+// swapping people around. Between them they have fewer eligible dancers than
+// there are positions, so one more person who can dance any of them would
+// fill one more position.
+func (m *matching) blocked() []int {
+	visited := make(map[int]struct{})
+	var queue []int
+
+	for position, dancer := range m.dancerFor {
+		if dancer == -1 {
+			visited[position] = struct{}{}
+			queue = append(queue, position)
+		}
+	}
+
+	for len(queue) > 0 {
+		position := queue[0]
+		queue = queue[1:]
+
+		for _, dancer := range m.eligible[position] {
+			// every dancer here must be matched, otherwise there would be an
+			// augmenting path and the matching wouldn't be maximum
+			other := m.positionFor[dancer]
+			if _, ok := visited[other]; ok {
+				continue
+			}
+			visited[other] = struct{}{}
+			queue = append(queue, other)
+		}
+	}
+
+	blocked := make([]int, 0, len(visited))
+	for position := range visited {
+		blocked = append(blocked, position)
+	}
+	sort.Ints(blocked)
+
+	return blocked
+}
@@ -0,0 +1,17 @@
+//go:build !cgo || nocppsolver
+
+package solver
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultBackend is the backend used unless another is asked for.
+const DefaultBackend = BackendGo
+
+// ReplayDump needs the cpp backend, which isn't built in.
+func ReplayDump(logger *logrus.Entry, modelPath string, responsePath string, format DumpFormat) (ReplayResult, error) {
+	return ReplayResult{}, fmt.Errorf("replaying %s: %w", modelPath, ErrUnsupported)
+}
@@ -0,0 +1,5 @@
+//go:build !debug
+
+package solver
+
+const debugBuild = false
@@ -0,0 +1,202 @@
+package solver
+
+import (
+	"fmt"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// Pair is a rule about two dancers being in the same dance. It only applies
+// when both of them are here: somebody whose partner is away isn't held back by
+// them.
+type Pair struct {
+	Dancer *model.Dancer
+	Other  *model.Dancer
+	Rule   model.PairRule
+}
+
+func (p Pair) String() string {
+	switch p.Rule {
+	case model.PairTogether:
+		return fmt.Sprintf("%s and %s together", p.Dancer.Name, p.Other.Name)
+	case model.PairApart:
+		return fmt.Sprintf("%s and %s never in the same dance", p.Dancer.Name, p.Other.Name)
+	case model.PairNotFacing:
+		return fmt.Sprintf("%s and %s not facing", p.Dancer.Name, p.Other.Name)
+	default:
+		return fmt.Sprintf("%s and %s: %s", p.Dancer.Name, p.Other.Name, p.Rule)
+	}
+}
+
+// PairsFromModel converts the pair rules stored in the database.
+func PairsFromModel(pairs []*model.DancerPair) []Pair {
+	converted := make([]Pair, 0, len(pairs))
+	for _, pair := range pairs {
+		converted = append(converted, Pair{Dancer: pair.Dancer, Other: pair.Other, Rule: pair.Rule})
+	}
+
+	return converted
+}
+
+// pairCheck is a pair rule with the dancers as whatever the caller tells them
+// apart by, IDs or indexes.
+type pairCheck struct {
+	pair Pair
+	a, b int
+}
+
+// activePairs returns the pairs where both dancers are here and active, with
+// each dancer as `key` says. Everybody who's here has preferences in `dps`.
+func activePairs(pairs []Pair, dps []*model.DancerPosition, key func(*model.Dancer) int) []pairCheck {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	here := make(map[int]*model.Dancer)
+	for _, dp := range dps {
+		if dp.Dancer.Active {
+			here[dp.Dancer.ID] = dp.Dancer
+		}
+	}
+
+	var checks []pairCheck
+	for _, pair := range pairs {
+		dancer, ok := here[pair.Dancer.ID]
+		if !ok {
+			continue
+		}
+		other, ok := here[pair.Other.ID]
+		if !ok {
+			continue
+		}
+
+		checks = append(checks, pairCheck{pair: pair, a: key(dancer), b: key(other)})
+	}
+
+	return checks
+}
+
+func dancerID(dancer *model.Dancer) int {
+	return dancer.ID
+}
+
+// facingPositions returns the facing positions of `dance`, as pairs of indexes
+// into its positions. Each pair is only there once.
+func facingPositions(dance *model.Dance) [][2]int {
+	index := make(map[int]int, len(dance.Positions))
+	for i, position := range dance.Positions {
+		index[position.PositionID] = i
+	}
+
+	var facing [][2]int
+	for i, position := range dance.Positions {
+		j, ok := index[position.FacingID]
+		if !ok || j == i {
+			continue
+		}
+
+		// it might be recorded on both positions
+		if j < i && dance.Positions[j].FacingID == position.PositionID {
+			continue
+		}
+
+		facing = append(facing, [2]int{min(i, j), max(i, j)})
+	}
+
+	return facing
+}
+
+// broken says whether `assignment` of a dance breaks the rule. `assignment` is
+// the dancer for each position, or -1 if it isn't filled yet. If
+// `complete` is false, only rules which can't be put right by filling more
+// positions are checked. It also returns which positions the dancers are in,
+// or -1.
+func (c pairCheck) broken(assignment []int, facing [][2]int, complete bool) (int, int, bool) {
+	ia, ib := -1, -1
+	for position, dancer := range assignment {
+		switch dancer {
+		case c.a:
+			ia = position
+		case c.b:
+			ib = position
+		}
+	}
+
+	switch c.pair.Rule {
+	case model.PairTogether:
+		return ia, ib, complete && (ia == -1) != (ib == -1)
+	case model.PairApart:
+		return ia, ib, ia != -1 && ib != -1
+	case model.PairNotFacing:
+		if ia == -1 || ib == -1 {
+			return ia, ib, false
+		}
+		for _, f := range facing {
+			if (f[0] == ia && f[1] == ib) || (f[0] == ib && f[1] == ia) {
+				return ia, ib, true
+			}
+		}
+	}
+
+	return ia, ib, false
+}
+
+// firstBrokenPair returns the first rule that `assignment` breaks.
+func firstBrokenPair(checks []pairCheck, assignment []int, facing [][2]int) (pairCheck, int, int, bool) {
+	for _, check := range checks {
+		if ia, ib, broken := check.broken(assignment, facing, true); broken {
+			return check, ia, ib, true
+		}
+	}
+
+	return pairCheck{}, -1, -1, false
+}
+
+// pairsAllowDance says whether there's any way of filling the positions of a
+// dance from `eligible` without breaking any of `checks`. The search gives up
+// and says yes after looking at `limit` assignments, as it's only used to
+// explain sets.
+func pairsAllowDance(eligible [][]int, checks []pairCheck, facing [][2]int, limit int) bool {
+	assignment := make([]int, len(eligible))
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	used := make(map[int]struct{})
+	nodes := 0
+
+	var fill func(position int) bool
+	fill = func(position int) bool {
+		nodes++
+		if nodes > limit {
+			return true
+		}
+
+		complete := position == len(eligible)
+		for _, check := range checks {
+			if _, _, broken := check.broken(assignment, facing, complete); broken {
+				return false
+			}
+		}
+		if complete {
+			return true
+		}
+
+		for _, dancer := range eligible[position] {
+			if _, ok := used[dancer]; ok {
+				continue
+			}
+
+			used[dancer] = struct{}{}
+			assignment[position] = dancer
+			if fill(position + 1) {
+				return true
+			}
+			assignment[position] = -1
+			delete(used, dancer)
+		}
+
+		return false
+	}
+
+	return fill(0)
+}
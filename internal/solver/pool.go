@@ -0,0 +1,119 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Pool is a Solver which runs at most `size` solves at once, for callers such
+// as servers which might be asked for lots of sets together. Each CP-SAT solve
+// wants every core to itself, so running many side by side just makes them all
+// slow. Instead, the cores are shared out between the pool's slots, and solves
+// past the limit wait their turn.
+//
+// It's safe for concurrent use.
+type Pool struct {
+	name    string
+	options Options
+	slots   chan struct{}
+}
+
+// NewPool returns a pool of at most `size` solvers from the backend called
+// `name`. Each solve gets a fair share of the cores, unless `options` sets
+// `Workers` itself. Dumping isn't allowed, as the solves would overwrite each
+// other's files.
+func NewPool(name string, options Options, size int) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("a pool needs at least one solver, not %d", size)
+	}
+
+	if options.Dump != nil {
+		return nil, errors.New("can't dump from a pool: the solves would overwrite each other")
+	}
+
+	if options.Workers == 0 {
+		options.Workers = max(1, runtime.NumCPU()/size)
+	}
+
+	// make sure the backend exists and is happy with the options now, rather
+	// than on the first solve
+	if _, err := New(name, options); err != nil {
+		return nil, err
+	}
+
+	return &Pool{
+		name:    name,
+		options: options,
+		slots:   make(chan struct{}, size),
+	}, nil
+}
+
+// acquire waits for a free slot, or for `ctx` to be done. Solvers hold state,
+// so each solve gets a new one.
+func (p *Pool) acquire(ctx context.Context) (Solver, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	solver, err := New(p.name, p.options)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+
+	return solver, nil
+}
+
+func (p *Pool) release() {
+	<-p.slots
+}
+
+// Solve is as for `Solver.Solve`, after waiting for a free slot. If `ctx` is
+// cancelled while waiting, it returns the context's error.
+func (p *Pool) Solve(ctx context.Context, logger *logrus.Entry, dps []*model.DancerPosition, constraints Constraints) (SolveResult, error) {
+	solver, err := p.acquire(ctx)
+	if err != nil {
+		return SolveResult{}, err
+	}
+	defer p.release()
+
+	return solver.Solve(ctx, logger, dps, constraints)
+}
+
+// SolveAlternatives is as for `Solver.SolveAlternatives`, after waiting for a
+// free slot.
+func (p *Pool) SolveAlternatives(
+	ctx context.Context,
+	logger *logrus.Entry,
+	dps []*model.DancerPosition,
+	constraints Constraints,
+	n int,
+	minDifference int,
+	difference AlternativeDifference,
+) ([]SolveResult, error) {
+	solver, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release()
+
+	return solver.SolveAlternatives(ctx, logger, dps, constraints, n, minDifference, difference)
+}
+
+// SolveSeason is as for `Solver.SolveSeason`, after waiting for a free slot.
+func (p *Pool) SolveSeason(ctx context.Context, logger *logrus.Entry, events []SeasonEvent, constraints Constraints) ([]SolveResult, error) {
+	solver, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release()
+
+	return solver.SolveSeason(ctx, logger, events, constraints)
+}
@@ -0,0 +1,118 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+
+	dances := goTestDances(2, 1, 1)
+	var dps []*model.DancerPosition
+	for _, dance := range dances {
+		for position := range dance.Positions {
+			dps = append(dps,
+				goTestDP(alice, dance, position+1, model.PreferenceYes),
+				goTestDP(bob, dance, position+1, model.PreferenceMaybe),
+			)
+		}
+	}
+
+	const size = 3
+	// progress is only reported from inside a slot, so this counts how many
+	// solves are running at once
+	var running, most atomic.Int32
+	pool, err := NewPool(BackendGo, Options{Progress: func(SolveResult) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := most.Load()
+			if n <= m || most.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}}, size)
+	require.NoError(t, err)
+
+	want, err := New(BackendGo, Options{})
+	require.NoError(t, err)
+	expected, err := want.Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	results := make([]SolveResult, 50)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = pool.Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		require.NoError(t, errs[i])
+		require.Equal(t, expected.Objective, result.Objective)
+		require.Equal(t, expected.Set.NumDancesDanced(), result.Set.NumDancesDanced())
+	}
+	require.LessOrEqual(t, most.Load(), int32(size))
+}
+
+func TestPoolWaitsForASlot(t *testing.T) {
+	t.Parallel()
+
+	pool, err := NewPool(BackendGo, Options{}, 1)
+	require.NoError(t, err)
+
+	// fill the only slot
+	pool.slots <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = pool.Solve(ctx, logrus.WithField("test-name", t.Name()), nil, Constraints{})
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestNewPool(t *testing.T) {
+	t.Parallel()
+
+	t.Run("needs a slot", func(t *testing.T) {
+		_, err := NewPool(BackendGo, Options{}, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("can't dump", func(t *testing.T) {
+		_, err := NewPool(BackendGo, Options{Dump: &Dump{ModelPath: "model.pbtxt"}}, 1)
+		require.Error(t, err)
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		_, err := NewPool("abacus", Options{}, 1)
+		require.Error(t, err)
+	})
+
+	t.Run("shares the workers out", func(t *testing.T) {
+		pool, err := NewPool(BackendGo, Options{}, 1<<20)
+		require.NoError(t, err)
+		require.Equal(t, 1, pool.options.Workers)
+
+		pool, err = NewPool(BackendGo, Options{Workers: 3}, 2)
+		require.NoError(t, err)
+		require.Equal(t, 3, pool.options.Workers)
+	})
+}
@@ -0,0 +1,441 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+var propertySeed = flag.Int64("property-seed", 0, "seed for the random sides in the property tests, 0 picks one")
+
+// randomSide is a generated side: some dancers, some dances and the dancers'
+// preferences for them. Everything is referred to by index, so it's easy to
+// shrink, and `build` turns it into the model.
+type randomSide struct {
+	// active, per dancer
+	dancers []bool
+	// number of positions, per dance
+	positions   []int
+	preferences []randomPreference
+	maxDances   int
+}
+
+type randomPreference struct {
+	dancer     int
+	dance      int
+	position   int
+	preference model.DancePreference
+}
+
+// generateSide makes a side with up to 6 dancers and 5 dances of up to 4
+// positions. Each dancer has a random preference for about half of the
+// positions, and some dancers are inactive.
+func generateSide(r *rand.Rand) randomSide {
+	var side randomSide
+
+	for i := r.Intn(6) + 1; i > 0; i-- {
+		side.dancers = append(side.dancers, r.Intn(5) > 0)
+	}
+
+	for i := r.Intn(5) + 1; i > 0; i-- {
+		side.positions = append(side.positions, r.Intn(4)+1)
+	}
+
+	for dancer := range side.dancers {
+		for dance, positions := range side.positions {
+			for position := 0; position < positions; position++ {
+				if r.Intn(2) == 0 {
+					continue
+				}
+				side.preferences = append(side.preferences, randomPreference{
+					dancer:     dancer,
+					dance:      dance,
+					position:   position,
+					preference: model.DancePreference(r.Intn(4)),
+				})
+			}
+		}
+	}
+
+	if r.Intn(3) == 0 {
+		side.maxDances = r.Intn(len(side.positions)) + 1
+	}
+
+	return side
+}
+
+func (s randomSide) String() string {
+	var sb strings.Builder
+
+	for i, active := range s.dancers {
+		fmt.Fprintf(&sb, "dancer %d", i+1)
+		if !active {
+			sb.WriteString(" (inactive)")
+		}
+		sb.WriteString("\n")
+	}
+
+	for i, positions := range s.positions {
+		fmt.Fprintf(&sb, "dance %d: %d positions\n", i+1, positions)
+	}
+
+	for _, p := range s.preferences {
+		fmt.Fprintf(&sb, "dancer %d, dance %d, position %d: %s\n", p.dancer+1, p.dance+1, p.position+1, p.preference)
+	}
+
+	if s.maxDances > 0 {
+		fmt.Fprintf(&sb, "at most %d dances\n", s.maxDances)
+	}
+
+	return sb.String()
+}
+
+// builtSide is a random side turned into the model.
+type builtSide struct {
+	dancers []*model.Dancer
+	dances  []*model.Dance
+	dps     []*model.DancerPosition
+	// dancer -> dance -> position -> preference
+	preferences map[*model.Dancer]map[*model.Dance]map[*model.Position]model.DancePreference
+}
+
+func (s randomSide) build() builtSide {
+	var b builtSide
+
+	for i, active := range s.dancers {
+		b.dancers = append(b.dancers, &model.Dancer{ID: i + 1, Name: fmt.Sprintf("Dancer %d", i+1), Active: active})
+	}
+
+	for i, positions := range s.positions {
+		dance := &model.Dance{ID: i + 1, Name: fmt.Sprintf("Dance %d", i+1), Active: true}
+		for p := 1; p <= positions; p++ {
+			dance.Positions = append(dance.Positions, &model.Position{PositionID: p, Name: fmt.Sprint(p), DanceID: dance.ID, Dance: dance})
+		}
+		b.dances = append(b.dances, dance)
+	}
+
+	b.preferences = make(map[*model.Dancer]map[*model.Dance]map[*model.Position]model.DancePreference)
+	for _, p := range s.preferences {
+		dancer, dance := b.dancers[p.dancer], b.dances[p.dance]
+		position := dance.Positions[p.position]
+
+		b.dps = append(b.dps, &model.DancerPosition{
+			DancerID:   dancer.ID,
+			DanceID:    dance.ID,
+			PositionID: position.PositionID,
+			Dancer:     dancer,
+			Dance:      dance,
+			Position:   position,
+			Preference: p.preference,
+		})
+
+		if b.preferences[dancer] == nil {
+			b.preferences[dancer] = make(map[*model.Dance]map[*model.Position]model.DancePreference)
+		}
+		if b.preferences[dancer][dance] == nil {
+			b.preferences[dancer][dance] = make(map[*model.Position]model.DancePreference)
+		}
+		b.preferences[dancer][dance][position] = p.preference
+	}
+
+	return b
+}
+
+// check returns what's wrong with `set`, if anything.
+func (b builtSide) check(set model.AssignmentSet, maxDances int) error {
+	for _, dance := range b.dances {
+		seen := make(map[*model.Dancer]*model.Position)
+		filled := 0
+
+		for _, position := range dance.Positions {
+			dancer := set.DancerFor(dance, position)
+			if dancer == nil {
+				continue
+			}
+			filled++
+
+			if other, ok := seen[dancer]; ok {
+				return fmt.Errorf("%s dances both %s and %s in %s", dancer.Name, other, position, dance.Name)
+			}
+			seen[dancer] = position
+
+			if !dancer.Active {
+				return fmt.Errorf("%s is inactive, but dances %s in %s", dancer.Name, position, dance.Name)
+			}
+
+			// no preference at all is the same as "no"
+			if b.preferences[dancer][dance][position] == model.PreferenceNo {
+				return fmt.Errorf("%s said no to %s in %s", dancer.Name, position, dance.Name)
+			}
+		}
+
+		danced := dance.IsDanced(set)
+		if danced != (filled == len(dance.Positions)) {
+			return fmt.Errorf("%s has %d of %d positions filled, but danced is %t", dance.Name, filled, len(dance.Positions), danced)
+		}
+	}
+
+	if maxDances > 0 && set.NumDancesDanced() > maxDances {
+		return fmt.Errorf("%d dances danced, but at most %d were allowed", set.NumDancesDanced(), maxDances)
+	}
+
+	return nil
+}
+
+// shrinkSide returns sides which are each a little smaller than `s`.
+func shrinkSide(s randomSide) []randomSide {
+	var smaller []randomSide
+
+	if s.maxDances > 0 {
+		next := s.clone()
+		next.maxDances = 0
+		smaller = append(smaller, next)
+	}
+
+	for dance := range s.positions {
+		next := s.clone()
+		next.positions = append(next.positions[:dance], next.positions[dance+1:]...)
+		next.preferences = next.filter(func(p randomPreference) bool { return p.dance == dance }, func(p *randomPreference) {
+			if p.dance > dance {
+				p.dance--
+			}
+		})
+		if next.maxDances > len(next.positions) {
+			next.maxDances = len(next.positions)
+		}
+		smaller = append(smaller, next)
+	}
+
+	for dancer := range s.dancers {
+		next := s.clone()
+		next.dancers = append(next.dancers[:dancer], next.dancers[dancer+1:]...)
+		next.preferences = next.filter(func(p randomPreference) bool { return p.dancer == dancer }, func(p *randomPreference) {
+			if p.dancer > dancer {
+				p.dancer--
+			}
+		})
+		smaller = append(smaller, next)
+	}
+
+	for dance, positions := range s.positions {
+		if positions == 1 {
+			continue
+		}
+		next := s.clone()
+		next.positions[dance]--
+		next.preferences = next.filter(func(p randomPreference) bool {
+			return p.dance == dance && p.position == positions-1
+		}, func(*randomPreference) {})
+		smaller = append(smaller, next)
+	}
+
+	for i := range s.preferences {
+		next := s.clone()
+		next.preferences = append(next.preferences[:i], next.preferences[i+1:]...)
+		smaller = append(smaller, next)
+	}
+
+	return smaller
+}
+
+func (s randomSide) clone() randomSide {
+	return randomSide{
+		dancers:     append([]bool(nil), s.dancers...),
+		positions:   append([]int(nil), s.positions...),
+		preferences: append([]randomPreference(nil), s.preferences...),
+		maxDances:   s.maxDances,
+	}
+}
+
+// filter drops the preferences that `drop` says to, then renumbers the rest
+// with `renumber`.
+func (s randomSide) filter(drop func(randomPreference) bool, renumber func(*randomPreference)) []randomPreference {
+	var kept []randomPreference
+	for _, p := range s.preferences {
+		if drop(p) {
+			continue
+		}
+		renumber(&p)
+		kept = append(kept, p)
+	}
+
+	return kept
+}
+
+// shrink keeps taking the first smaller side which still fails, until none of
+// them do. The result is a side where removing anything makes the failure go
+// away.
+func shrink(side randomSide, err error, fails func(randomSide) error) (randomSide, error) {
+	for {
+		shrunk := false
+		for _, smaller := range shrinkSide(side) {
+			if smallerErr := fails(smaller); smallerErr != nil {
+				side, err, shrunk = smaller, smallerErr, true
+				break
+			}
+		}
+
+		if !shrunk {
+			return side, err
+		}
+	}
+}
+
+// checkProperty runs `property` on lots of random sides, and reports the
+// smallest failing side it can find.
+func checkProperty(t *testing.T, property func(randomSide) error) {
+	t.Helper()
+
+	seed := *propertySeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(seed))
+
+	runs := 200
+	if testing.Short() {
+		runs = 20
+	}
+
+	for i := 0; i < runs; i++ {
+		side := generateSide(r)
+
+		if err := property(side); err != nil {
+			side, err = shrink(side, err, property)
+			t.Fatalf("failed with -property-seed=%d: %v\nshrunk to:\n%s", seed, err, side)
+		}
+	}
+}
+
+func TestShrink(t *testing.T) {
+	t.Parallel()
+
+	// a property which is broken whenever somebody's favourite position is in
+	// a dance with more than one position
+	favourite := func(side randomSide) error {
+		for _, p := range side.preferences {
+			if p.preference == model.PreferenceFavourite && side.positions[p.dance] > 1 {
+				return fmt.Errorf("dancer %d's favourite is in dance %d", p.dancer+1, p.dance+1)
+			}
+		}
+
+		return nil
+	}
+
+	t.Run("known side", func(t *testing.T) {
+		side := randomSide{
+			dancers:   []bool{true, false, true},
+			positions: []int{1, 3, 2},
+			preferences: []randomPreference{
+				{dancer: 0, dance: 0, position: 0, preference: model.PreferenceFavourite},
+				{dancer: 1, dance: 1, position: 1, preference: model.PreferenceYes},
+				{dancer: 2, dance: 1, position: 0, preference: model.PreferenceFavourite},
+				{dancer: 2, dance: 2, position: 1, preference: model.PreferenceMaybe},
+			},
+			maxDances: 2,
+		}
+
+		shrunk, err := shrink(side, favourite(side), favourite)
+		require.Equal(t, randomSide{
+			dancers:     []bool{true},
+			positions:   []int{2},
+			preferences: []randomPreference{{dancer: 0, dance: 0, position: 0, preference: model.PreferenceFavourite}},
+		}, shrunk)
+		require.EqualError(t, err, "dancer 1's favourite is in dance 1")
+	})
+
+	t.Run("random sides", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1))
+
+		for i := 0; i < 200; i++ {
+			side := generateSide(r)
+			err := favourite(side)
+			if err == nil {
+				continue
+			}
+
+			shrunk, err := shrink(side, err, favourite)
+			require.Error(t, err)
+			require.Len(t, shrunk.dancers, 1, "shrunk to:\n%s", shrunk)
+			require.Len(t, shrunk.positions, 1, "shrunk to:\n%s", shrunk)
+			require.Len(t, shrunk.preferences, 1, "shrunk to:\n%s", shrunk)
+			require.Zero(t, shrunk.maxDances, "shrunk to:\n%s", shrunk)
+
+			// and nothing smaller still fails
+			for _, smaller := range shrinkSide(shrunk) {
+				require.NoError(t, favourite(smaller), "%s shrinks to:\n%s", shrunk, smaller)
+			}
+		}
+	})
+}
+
+func TestSolverProperties(t *testing.T) {
+	for _, backend := range Backends() {
+		backend := backend
+		t.Run(backend, func(t *testing.T) {
+			t.Parallel()
+
+			solver, err := New(backend, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			logger := logrus.WithField("test-name", t.Name())
+
+			t.Run("solve", func(t *testing.T) {
+				checkProperty(t, func(side randomSide) error {
+					b := side.build()
+					result, err := solver.Solve(context.Background(), logger, b.dps, Constraints{MaxDances: side.maxDances})
+					if err != nil {
+						return err
+					}
+
+					if err := b.check(result.Set, side.maxDances); err != nil {
+						return err
+					}
+
+					// `Validate` should agree
+					if violations := Validate(result.Set, b.dps, Constraints{MaxDances: side.maxDances}); len(violations) > 0 {
+						return ViolationsError(violations)
+					}
+
+					return nil
+				})
+			})
+
+			t.Run("alternatives", func(t *testing.T) {
+				// backends which can't find more than one set (the Go one) can
+				// still be asked for one, which should be the same as solving
+				n := 3
+				if _, err := solver.SolveAlternatives(context.Background(), logger, nil, Constraints{}, n, 1, AlternativeDifferenceAssignments); errors.Is(err, ErrUnsupported) {
+					t.Logf("only asking for one set: %v", err)
+					n = 1
+				}
+
+				checkProperty(t, func(side randomSide) error {
+					b := side.build()
+					results, err := solver.SolveAlternatives(context.Background(), logger, b.dps, Constraints{MaxDances: side.maxDances}, n, 1, AlternativeDifferenceAssignments)
+					if err != nil {
+						return err
+					}
+
+					for i, result := range results {
+						if err := b.check(result.Set, side.maxDances); err != nil {
+							return fmt.Errorf("alternative %d: %w", i+1, err)
+						}
+					}
+
+					return nil
+				})
+			})
+		})
+	}
+}
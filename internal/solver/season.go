@@ -0,0 +1,25 @@
+package solver
+
+import (
+	"errors"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// SeasonEvent is one of the events in a season, along with the positions of
+// the dancers who are at it.
+type SeasonEvent struct {
+	Name            string
+	DancerPositions []*model.DancerPosition
+}
+
+// checkSeason makes sure the constraints only use what a season can do: the
+// number of dances, and who can call them, which applies at every event they're
+// at. The rest are about individual events.
+func (c Constraints) checkSeason() error {
+	if len(c.Include) > 0 || len(c.Exclude) > 0 || len(c.Pins) > 0 || len(c.DancerLimits) > 0 || len(c.Pairs) > 0 || len(c.Weights) > 0 || c.Repair != nil {
+		return errors.New("only the number of dances and the callers can be constrained when solving a season")
+	}
+
+	return nil
+}
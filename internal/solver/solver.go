@@ -1,76 +1,200 @@
 package solver
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/iainlane/who-dances-what/internal/model"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/exp/maps"
 )
 
-// This is a wrapper around the C solver. It takes in the data from the model
-// and converts it into the format the C solver expects.
-// It then converts the output from the C solver back into the format the model
-// expects.
-func Solve(logger *logrus.Entry, dps []*model.DancerPosition) model.AssignmentSet {
-	// Convert the model data into the format the C solver expects
-	dancers := make(map[*model.Dancer]rawDancer)
-	dancersById := make(map[int]*model.Dancer)
-	dances := make(map[*model.Dance]rawDance)
-	dancerPositions := make([]rawDancerPosition, 0, len(dps))
-
-	// check if the dancer is already in the map and if not, add it
+// SolveResult is what comes back from the solver: the set itself, and how
+// sure the solver is about it.
+type SolveResult struct {
+	Set    model.AssignmentSet
+	Status SolverStatus
+	// Objective is how good the solver thinks the set is. Higher is better. It
+	// is only meaningful when comparing sets from the same call.
+	Objective int64
+	// Explanations say why each dance that somebody here has a preference for
+	// isn't in the set.
+	Explanations []Explanation
+}
+
+// Solver makes dance sets. There's one implementation per backend: see
+// `Backends`.
+//
+// Solving stops early if the context is cancelled. The results then have
+// the status `SolverStatusCancelled`, and hold the best sets found so far.
+type Solver interface {
+	// Solve finds the best set it can for the dancers in `dps`.
+	Solve(ctx context.Context, logger *logrus.Entry, dps []*model.DancerPosition, constraints Constraints) (SolveResult, error)
+
+	// SolveAlternatives finds up to `n` different sets, best first. Each set
+	// differs from every one before it by at least `minDifference`, counted as
+	// `difference` says. There might be fewer than `n` if there aren't enough
+	// different sets to be had.
+	SolveAlternatives(
+		ctx context.Context,
+		logger *logrus.Entry,
+		dps []*model.DancerPosition,
+		constraints Constraints,
+		n int,
+		minDifference int,
+		difference AlternativeDifference,
+	) ([]SolveResult, error)
+
+	// SolveSeason makes the sets for several events at once, returning one
+	// result per event in the same order. As well as making each set as good
+	// as it can be, it shares dances and favourite positions fairly over the
+	// whole season, allowing for how many events each dancer is at, and avoids
+	// dancing the same dances at consecutive events. The events should be in
+	// the order they happen. Every result has the objective for the whole
+	// season.
+	SolveSeason(ctx context.Context, logger *logrus.Entry, events []SeasonEvent, constraints Constraints) ([]SolveResult, error)
+}
+
+// ErrUnsupported is returned when a backend can't do what it's been asked.
+var ErrUnsupported = errors.New("not supported by this solver")
+
+const (
+	// BackendCpp is the OR-tools CP-SAT solver in `cppsolver`. It's only
+	// there when built with cgo and without the `nocppsolver` tag.
+	BackendCpp = "cpp"
+	// BackendGo is written in Go, so it's always there. It finds good sets,
+	// but can't promise they're the best.
+	BackendGo = "go"
+)
+
+// Options change how a backend goes about solving, rather than what it solves.
+type Options struct {
+	// Dump writes out the model the solver builds and its response, so a
+	// strange set can be reproduced later with `ReplayDump`.
+	Dump *Dump
+	// Progress is called with each set the solver finds which is better than
+	// the ones before it, while it carries on looking. It's called from the
+	// solver's thread, so it should return quickly. Calls for one solve never
+	// overlap, but they can come from different threads. Finding alternatives
+	// reports the sets found while looking for each one. Seasons don't report
+	// progress.
+	Progress func(SolveResult)
+	// Workers is how many threads a backend searches with, for those that
+	// search in parallel. 0 lets the backend choose, which for CP-SAT is one
+	// per core. A `Pool` sets it for each solve.
+	Workers int
+}
+
+// Dump says where to write the model and the response. Empty paths aren't
+// written. Finding alternatives solves more than once, and each solve
+// overwrites the files.
+type Dump struct {
+	ModelPath    string
+	ResponsePath string
+	Format       DumpFormat
+}
+
+// ReplayResult is what comes back from solving a dumped model again.
+type ReplayResult struct {
+	Status    SolverStatus
+	Objective int64
+	// Stats is the solver's summary of how it went.
+	Stats string
+}
+
+var backends = map[string]func(Options) (Solver, error){
+	BackendGo: newGoSolver,
+}
+
+// Backends returns the names of the backends which were built in.
+func Backends() []string {
+	names := maps.Keys(backends)
+	sort.Strings(names)
+
+	return names
+}
+
+// New returns the backend called `name`. It returns an error wrapping
+// `ErrUnsupported` if the backend can't do what `options` asks. In debug
+// builds, every set it returns is checked with `Validate`.
+func New(name string, options Options) (Solver, error) {
+	newSolver, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown solver %q, expected one of %s", name, strings.Join(Backends(), ", "))
+	}
+
+	solver, err := newSolver(options)
+	if err != nil || !debugBuild {
+		return solver, err
+	}
+
+	return checkedSolver{solver}, nil
+}
+
+func defaultSolver() Solver {
+	// every backend supports the default options
+	solver, _ := New(DefaultBackend, Options{})
+
+	return solver
+}
+
+// Solve finds the best set it can with the default backend.
+func Solve(ctx context.Context, logger *logrus.Entry, dps []*model.DancerPosition, constraints Constraints) (SolveResult, error) {
+	return defaultSolver().Solve(ctx, logger, dps, constraints)
+}
+
+// SolveAlternatives finds alternative sets with the default backend.
+func SolveAlternatives(
+	ctx context.Context,
+	logger *logrus.Entry,
+	dps []*model.DancerPosition,
+	constraints Constraints,
+	n int,
+	minDifference int,
+	difference AlternativeDifference,
+) ([]SolveResult, error) {
+	return defaultSolver().SolveAlternatives(ctx, logger, dps, constraints, n, minDifference, difference)
+}
+
+// SolveSeason solves a season with the default backend.
+func SolveSeason(ctx context.Context, logger *logrus.Entry, events []SeasonEvent, constraints Constraints) ([]SolveResult, error) {
+	return defaultSolver().SolveSeason(ctx, logger, events, constraints)
+}
+
+// danceList returns the dances in `dps`, ordered by name.
+func danceList(dps []*model.DancerPosition) []*model.Dance {
+	seen := make(map[*model.Dance]struct{})
+	var dances []*model.Dance
 	for _, dp := range dps {
-		dancer := dp.Dancer
-		if _, ok := dancers[dancer]; !ok {
-			dancers[dancer] = rawDancer{
-				Active: dancer.Active,
-				ID:     int(dancer.ID),
-			}
-			dancersById[dancer.ID] = dancer
+		if _, ok := seen[dp.Dance]; ok {
+			continue
 		}
+		seen[dp.Dance] = struct{}{}
+		dances = append(dances, dp.Dance)
+	}
 
-		dance := dp.Dance
-		if _, ok := dances[dance]; !ok {
-			positions := make([]rawPosition, 0, len(dance.Positions))
-			for _, position := range dance.Positions {
-				positions = append(positions, rawPosition{PositionID: int(position.PositionID)})
-			}
-			dances[dance] = rawDance{
-				ID:        int(dance.ID),
-				Positions: positions,
-			}
+	sort.Slice(dances, func(i, j int) bool {
+		if dances[i].Name != dances[j].Name {
+			return dances[i].Name < dances[j].Name
 		}
+		return dances[i].ID < dances[j].ID
+	})
+
+	return dances
+}
 
-		dancerPosition := dp
-		dancerPositions = append(dancerPositions, rawDancerPosition{
-			DancerID:   int(dancer.ID),
-			PositionID: int(dancerPosition.Position.PositionID),
-			DanceID:    int(dance.ID),
-			Preference: DancePreference(dancerPosition.Preference),
-		})
+// checkAlternatives checks the arguments to `SolveAlternatives`.
+func checkAlternatives(n int, minDifference int) error {
+	if n < 1 {
+		return fmt.Errorf("need to ask for at least one set, not %d", n)
 	}
 
-	solver := newCDanceSolver(logger, maps.Values(dancers), maps.Values(dances), dancerPositions)
-	defer solver.freeCDanceSolver()
-	solution := solver.getPossibleDances()
-	defer solution.freeCDanceSolution()
-
-	// Convert the output from the C solver back into the format the model expects
-	as := make(model.Assignments)
-	dd := make(model.DancesDanced)
-	assignments := model.NewAssignmentSet(as, dd)
-	for dance, rawDance := range dances {
-		danceID := rawDance.ID
-		as[dance] = make(map[*model.Position]*model.Dancer)
-		if solution.isDancePerformed(dance.ID) {
-			dd[dance] = struct{}{}
-		}
-		for _, position := range dance.Positions {
-			positionID := position.PositionID
-			idOfDancer := solution.getDancerDancePosition(danceID, positionID)
-			dancer := dancersById[idOfDancer]
-			as[dance][position] = dancer
-		}
+	if minDifference < 1 {
+		return fmt.Errorf("sets need to differ by at least one, not %d", minDifference)
 	}
 
-	return assignments
+	return nil
 }
@@ -1,6 +1,13 @@
+//go:build cgo && !nocppsolver
+
 package solver
 
 import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/iainlane/who-dances-what/internal/model"
@@ -31,8 +38,457 @@ func TestSolver(t *testing.T) {
 		Preference: model.PreferenceYes,
 	}
 
-	set := Solve(logrus.WithField("test-name", t.Name()), []*model.DancerPosition{&dancerPosition})
+	result, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), []*model.DancerPosition{&dancerPosition}, Constraints{})
+	require.NoError(t, err)
+	require.Equal(t, SolverStatusOptimal, result.Status)
+
+	set := result.Set
 	require.Equal(t, 1, set.NumDancesDanced())
 	require.True(t, dance.IsDanced(set))
 	require.Equal(t, dancer, set.DancerFor(dance, dance.Positions[0]))
 }
+
+func TestSolverPinConflictingWithNoPreference(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	dance := &model.Dance{
+		ID:   1,
+		Name: "Bean Setting",
+		Positions: []*model.Position{
+			{PositionID: 1, Name: "1"},
+			{PositionID: 2, Name: "2"},
+		},
+	}
+	dps := []*model.DancerPosition{
+		{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceNo},
+		{Dancer: alice, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceYes},
+		{Dancer: bob, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes},
+		{Dancer: bob, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceYes},
+	}
+
+	_, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{
+		Pins: []Pin{{Dancer: alice, Dance: dance, Position: dance.Positions[0]}},
+	})
+	require.ErrorContains(t, err, `Alice has said "no" to 1 in "Bean Setting"`)
+}
+
+func TestSolverWeights(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+
+	var dances []*model.Dance
+	var dps []*model.DancerPosition
+	for _, name := range []string{"Bean Setting", "Constant Billy", "Shepherd's Hey"} {
+		dance := &model.Dance{ID: len(dances) + 1, Name: name, Positions: []*model.Position{{PositionID: 1, Name: "1"}}}
+		dances = append(dances, dance)
+		dps = append(dps,
+			&model.DancerPosition{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes},
+			&model.DancerPosition{Dancer: bob, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes},
+		)
+	}
+
+	result, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{
+		Weights: map[*model.Dancer]float64{bob: 2},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Set.NumDancesDanced())
+
+	bobs := 0
+	for _, dance := range dances {
+		if result.Set.DancerFor(dance, dance.Positions[0]) == bob {
+			bobs++
+		}
+	}
+	require.Equal(t, 2, bobs)
+}
+
+func TestSolverPairs(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dance := &model.Dance{
+		ID:   1,
+		Name: "Constant Billy",
+		Positions: []*model.Position{
+			{PositionID: 1, Name: "1", FacingID: 2},
+			{PositionID: 2, Name: "2"},
+			{PositionID: 3, Name: "3"},
+		},
+	}
+	dps := []*model.DancerPosition{
+		{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceFavourite},
+		{Dancer: carol, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceFavourite},
+		{Dancer: carol, Dance: dance, Position: dance.Positions[2], Preference: model.PreferenceYes},
+		{Dancer: bob, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceYes},
+		{Dancer: bob, Dance: dance, Position: dance.Positions[2], Preference: model.PreferenceFavourite},
+	}
+
+	pairs := []Pair{{Dancer: alice, Other: carol, Rule: model.PairNotFacing}}
+	result, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{Pairs: pairs})
+	require.NoError(t, err)
+
+	set := result.Set
+	require.Equal(t, alice, set.DancerFor(dance, dance.Positions[0]))
+	require.Equal(t, bob, set.DancerFor(dance, dance.Positions[1]))
+	require.Equal(t, carol, set.DancerFor(dance, dance.Positions[2]))
+	require.Empty(t, Validate(set, dps, Constraints{Pairs: pairs}))
+}
+
+func TestSolverCallers(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dance := &model.Dance{
+		ID:        1,
+		Name:      "Constant Billy",
+		Caller:    model.CallerStandingOut,
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}, {PositionID: 2, Name: "2"}},
+	}
+	dps := []*model.DancerPosition{
+		{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceFavourite},
+		{Dancer: bob, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceFavourite},
+		{Dancer: carol, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceYes},
+	}
+
+	constraints := Constraints{Callers: []Caller{{Dancer: bob, Dance: dance}}}
+	result, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, constraints)
+	require.NoError(t, err)
+
+	set := result.Set
+	require.Equal(t, alice, set.DancerFor(dance, dance.Positions[0]))
+	require.Equal(t, carol, set.DancerFor(dance, dance.Positions[1]))
+	require.Equal(t, bob, set.CallerFor(dance))
+	require.Empty(t, Validate(set, dps, constraints))
+}
+
+func TestSolverVariants(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	full := &model.Dance{
+		ID:        1,
+		Name:      "Bean Setting",
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}, {PositionID: 2, Name: "2"}, {PositionID: 3, Name: "3"}},
+	}
+	small := &model.Dance{
+		ID:        2,
+		Name:      "Bean Setting (2)",
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}, {PositionID: 2, Name: "2"}},
+		VariantOf: full.ID,
+	}
+	dps := []*model.DancerPosition{
+		{Dancer: alice, Dance: full, Position: full.Positions[0], Preference: model.PreferenceYes},
+		{Dancer: bob, Dance: full, Position: full.Positions[1], Preference: model.PreferenceYes},
+		{Dancer: carol, Dance: full, Position: full.Positions[2], Preference: model.PreferenceYes},
+		{Dancer: alice, Dance: small, Position: small.Positions[0], Preference: model.PreferenceFavourite},
+		{Dancer: bob, Dance: small, Position: small.Positions[1], Preference: model.PreferenceFavourite},
+	}
+
+	result, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+	require.NoError(t, err)
+
+	require.True(t, full.IsDanced(result.Set))
+	require.False(t, small.IsDanced(result.Set))
+	require.Empty(t, Validate(result.Set, dps, Constraints{}))
+}
+
+func TestSolverPinAndExclude(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	beanSetting := &model.Dance{
+		ID:        1,
+		Name:      "Bean Setting",
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}, {PositionID: 2, Name: "2"}},
+	}
+	constant := &model.Dance{
+		ID:        2,
+		Name:      "Constant Billy",
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}},
+	}
+
+	var dps []*model.DancerPosition
+	for _, dancer := range []*model.Dancer{alice, bob} {
+		for _, dance := range []*model.Dance{beanSetting, constant} {
+			for _, position := range dance.Positions {
+				dps = append(dps, &model.DancerPosition{
+					Dancer:     dancer,
+					Dance:      dance,
+					Position:   position,
+					Preference: model.PreferenceYes,
+				})
+			}
+		}
+	}
+
+	result, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{
+		Exclude: []*model.Dance{constant},
+		Pins:    []Pin{{Dancer: bob, Dance: beanSetting, Position: beanSetting.Positions[0]}},
+	})
+	require.NoError(t, err)
+
+	set := result.Set
+	require.Equal(t, 1, set.NumDancesDanced())
+	require.False(t, constant.IsDanced(set))
+	require.True(t, beanSetting.IsDanced(set))
+	require.Equal(t, bob, set.DancerFor(beanSetting, beanSetting.Positions[0]))
+	require.Equal(t, alice, set.DancerFor(beanSetting, beanSetting.Positions[1]))
+}
+
+func TestSolverRepair(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dance := &model.Dance{
+		ID:   1,
+		Name: "Bean Setting",
+		Positions: []*model.Position{
+			{PositionID: 1, Name: "1"},
+			{PositionID: 2, Name: "2"},
+		},
+	}
+
+	// Bob has gone home, so he's only in the saved set
+	var saved bytes.Buffer
+	err := model.NewAssignmentSet(
+		model.Assignments{dance: {dance.Positions[0]: alice, dance.Positions[1]: {ID: 2, Name: "Bob"}}},
+		model.DancesDanced{dance: {}},
+	).Save(&saved)
+	require.NoError(t, err)
+
+	previous, err := model.LoadAssignmentSet(&saved, []*model.Dance{dance}, []*model.Dancer{alice, carol})
+	require.NoError(t, err)
+
+	// Carol would rather dance position 1, but that would move Alice
+	dps := []*model.DancerPosition{
+		{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes},
+		{Dancer: alice, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceYes},
+		{Dancer: carol, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceFavourite},
+		{Dancer: carol, Dance: dance, Position: dance.Positions[1], Preference: model.PreferenceYes},
+	}
+
+	result, err := Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{Repair: &previous})
+	require.NoError(t, err)
+	require.Equal(t, SolverStatusOptimal, result.Status)
+
+	set := result.Set
+	require.True(t, dance.IsDanced(set))
+	require.Equal(t, alice, set.DancerFor(dance, dance.Positions[0]))
+	require.Equal(t, carol, set.DancerFor(dance, dance.Positions[1]))
+}
+
+func TestSolveSeason(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	dance := &model.Dance{
+		ID:        1,
+		Name:      "Constant Billy",
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}},
+	}
+	aliceDP := &model.DancerPosition{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes}
+	bobDP := &model.DancerPosition{Dancer: bob, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes}
+
+	results, err := SolveSeason(context.Background(), logrus.WithField("test-name", t.Name()), []SeasonEvent{
+		{Name: "Week 1", DancerPositions: []*model.DancerPosition{aliceDP, bobDP}},
+		{Name: "Week 2", DancerPositions: []*model.DancerPosition{aliceDP, bobDP}},
+		{Name: "Week 3", DancerPositions: []*model.DancerPosition{aliceDP}},
+	}, Constraints{})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	for _, result := range results {
+		require.Equal(t, SolverStatusOptimal, result.Status)
+		require.True(t, dance.IsDanced(result.Set))
+	}
+
+	// Alice dances in week 3 as she's the only one there, so Bob should get
+	// one of the other two
+	require.Equal(t, alice, results[2].Set.DancerFor(dance, dance.Positions[0]))
+	require.NotEqual(t,
+		results[0].Set.DancerFor(dance, dance.Positions[0]),
+		results[1].Set.DancerFor(dance, dance.Positions[0]),
+	)
+}
+
+func TestSolveSeasonCallers(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	dance := &model.Dance{
+		ID:        1,
+		Name:      "Constant Billy",
+		Caller:    model.CallerStandingOut,
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}},
+	}
+	aliceDP := &model.DancerPosition{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes}
+	bobDP := &model.DancerPosition{Dancer: bob, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceNo}
+
+	results, err := SolveSeason(context.Background(), logrus.WithField("test-name", t.Name()), []SeasonEvent{
+		{Name: "Week 1", DancerPositions: []*model.DancerPosition{aliceDP, bobDP}},
+		{Name: "Week 2", DancerPositions: []*model.DancerPosition{aliceDP}},
+	}, Constraints{Callers: []Caller{{Dancer: bob, Dance: dance}}})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	// Bob calls it in week 1, and isn't there to call it in week 2
+	require.True(t, dance.IsDanced(results[0].Set))
+	require.Equal(t, bob, results[0].Set.CallerFor(dance))
+	require.False(t, dance.IsDanced(results[1].Set))
+}
+
+func TestSolveSeasonOnlyLimitsTheNumberOfDances(t *testing.T) {
+	dance := &model.Dance{ID: 1, Name: "Constant Billy"}
+
+	_, err := SolveSeason(context.Background(), logrus.WithField("test-name", t.Name()), []SeasonEvent{
+		{Name: "Week 1"},
+	}, Constraints{Exclude: []*model.Dance{dance}})
+	require.Error(t, err)
+}
+
+func TestDumpAndReplay(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	dance := &model.Dance{
+		ID:        1,
+		Name:      "Constant Billy",
+		Positions: []*model.Position{{PositionID: 1, Name: "1"}},
+	}
+	dps := []*model.DancerPosition{
+		{Dancer: alice, Dance: dance, Position: dance.Positions[0], Preference: model.PreferenceYes},
+	}
+
+	logger := logrus.WithField("test-name", t.Name())
+
+	for _, format := range []DumpFormat{DumpFormatText, DumpFormatBinary} {
+		t.Run(format.String(), func(t *testing.T) {
+			dir := t.TempDir()
+			dump := Dump{
+				ModelPath:    filepath.Join(dir, "model"),
+				ResponsePath: filepath.Join(dir, "response"),
+				Format:       format,
+			}
+
+			solver, err := New(BackendCpp, Options{Dump: &dump})
+			require.NoError(t, err)
+
+			result, err := solver.Solve(context.Background(), logger, dps, Constraints{})
+			require.NoError(t, err)
+			require.FileExists(t, dump.ModelPath)
+			require.FileExists(t, dump.ResponsePath)
+
+			replayPath := filepath.Join(dir, "replay")
+			replay, err := ReplayDump(logger, dump.ModelPath, replayPath, format)
+			require.NoError(t, err)
+			require.Equal(t, result.Status, replay.Status)
+			require.Equal(t, result.Objective, replay.Objective)
+			require.FileExists(t, replayPath)
+		})
+	}
+
+	_, err := ReplayDump(logger, filepath.Join(t.TempDir(), "missing"), "", DumpFormatText)
+	require.Error(t, err)
+}
+
+func TestSolveProgress(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	dances := []*model.Dance{
+		{ID: 1, Name: "Constant Billy", Positions: []*model.Position{{PositionID: 1, Name: "1"}, {PositionID: 2, Name: "2"}}},
+		{ID: 2, Name: "Shepherd's Hey", Positions: []*model.Position{{PositionID: 1, Name: "1"}}},
+	}
+	var dps []*model.DancerPosition
+	for _, dance := range dances {
+		for _, position := range dance.Positions {
+			for _, dancer := range []*model.Dancer{alice, bob} {
+				dps = append(dps, &model.DancerPosition{Dancer: dancer, Dance: dance, Position: position, Preference: model.PreferenceYes})
+			}
+		}
+	}
+
+	var progress []SolveResult
+	solver, err := New(BackendCpp, Options{Progress: func(result SolveResult) {
+		progress = append(progress, result)
+	}})
+	require.NoError(t, err)
+
+	result, err := solver.Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+	require.NoError(t, err)
+	require.Equal(t, SolverStatusOptimal, result.Status)
+
+	require.NotEmpty(t, progress)
+	for i := 1; i < len(progress); i++ {
+		require.GreaterOrEqual(t, progress[i].Objective, progress[i-1].Objective)
+	}
+	last := progress[len(progress)-1]
+	require.Equal(t, result.Objective, last.Objective)
+	require.Equal(t, result.Set.NumDancesDanced(), last.Set.NumDancesDanced())
+}
+
+func TestSolveCancelled(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	dances := []*model.Dance{
+		{ID: 1, Name: "Constant Billy", Positions: []*model.Position{{PositionID: 1, Name: "1"}, {PositionID: 2, Name: "2"}}},
+		{ID: 2, Name: "Shepherd's Hey", Positions: []*model.Position{{PositionID: 1, Name: "1"}}},
+	}
+	var dps []*model.DancerPosition
+	for _, dance := range dances {
+		for _, position := range dance.Positions {
+			for _, dancer := range []*model.Dancer{alice, bob} {
+				dps = append(dps, &model.DancerPosition{Dancer: dancer, Dance: dance, Position: position, Preference: model.PreferenceYes})
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// stop as soon as there's a set
+	solver, err := New(BackendCpp, Options{Progress: func(SolveResult) { cancel() }})
+	require.NoError(t, err)
+
+	result, err := solver.Solve(ctx, logrus.WithField("test-name", t.Name()), dps, Constraints{})
+	require.NoError(t, err)
+
+	// it might have finished before it noticed
+	require.Contains(t, []SolverStatus{SolverStatusCancelled, SolverStatusOptimal}, result.Status)
+	require.NotZero(t, result.Set.NumDancesDanced())
+}
+
+func TestSolveConcurrently(t *testing.T) {
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	dances := []*model.Dance{
+		{ID: 1, Name: "Constant Billy", Positions: []*model.Position{{PositionID: 1, Name: "1"}, {PositionID: 2, Name: "2"}}},
+		{ID: 2, Name: "Shepherd's Hey", Positions: []*model.Position{{PositionID: 1, Name: "1"}}},
+	}
+	var dps []*model.DancerPosition
+	for _, dance := range dances {
+		for _, position := range dance.Positions {
+			for _, dancer := range []*model.Dancer{alice, bob} {
+				dps = append(dps, &model.DancerPosition{Dancer: dancer, Dance: dance, Position: position, Preference: model.PreferenceYes})
+			}
+		}
+	}
+
+	// progress comes from the C++ threads, for every solve at once
+	var progress atomic.Int32
+	pool, err := NewPool(BackendCpp, Options{Progress: func(SolveResult) { progress.Add(1) }}, 4)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	results := make([]SolveResult, 32)
+	errs := make([]error, len(results))
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// the logger is shared too
+			results[i], errs[i] = pool.Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		require.NoError(t, errs[i])
+		require.Equal(t, SolverStatusOptimal, result.Status)
+		require.Equal(t, 2, result.Set.NumDancesDanced())
+	}
+	require.GreaterOrEqual(t, progress.Load(), int32(len(results)))
+}
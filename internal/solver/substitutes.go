@@ -0,0 +1,140 @@
+package solver
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// FindSubstitutes works out who could step into each position of `set` if
+// whoever is dancing it can't, without breaking any of the rules or
+// `constraints`. `dps` are the preferences of the dancers who are here.
+// Substitutes are ranked by how much they like the position, then by how few
+// dances they're already doing. Like Validate, everything is matched by ID.
+//
+// Somebody who is already in the dance isn't a substitute, as that would take
+// more than one swap. Pair rules involving the dancer who's dropping out are
+// broken whoever steps in, so they're ignored.
+func FindSubstitutes(set model.AssignmentSet, dps []*model.DancerPosition, constraints Constraints) model.Substitutes {
+	type key struct {
+		danceID    int
+		positionID int
+	}
+
+	candidates := make(map[key][]*model.DancerPosition)
+	for _, dp := range dps {
+		if canDance(dp) {
+			k := key{dp.Dance.ID, dp.Position.PositionID}
+			candidates[k] = append(candidates[k], dp)
+		}
+	}
+
+	dances := set.Dances()
+
+	loads := make(map[int]int)
+	for _, dance := range dances {
+		for _, position := range dance.Positions {
+			if dancer := set.DancerFor(dance, position); dancer != nil {
+				loads[dancer.ID]++
+			}
+		}
+	}
+
+	limits := make(map[int]DancerLimit, len(constraints.DancerLimits))
+	for dancer, limit := range constraints.DancerLimits {
+		limits[dancer.ID] = limit
+	}
+
+	pairs := activePairs(constraints.Pairs, dps, dancerID)
+	callers := activeCallers(constraints.Callers, dps, dancerID)
+
+	substitutes := make(model.Substitutes, len(dances))
+	for _, dance := range dances {
+		substitutes[dance] = make(map[*model.Position][]model.Substitute, len(dance.Positions))
+
+		assignment := make([]int, len(dance.Positions))
+		for i, position := range dance.Positions {
+			assignment[i] = -1
+			if dancer := set.DancerFor(dance, position); dancer != nil {
+				assignment[i] = dancer.ID
+			}
+		}
+		facing := facingPositions(dance)
+
+		for i, position := range dance.Positions {
+			out := assignment[i]
+			if out == -1 {
+				continue
+			}
+
+			var subs []model.Substitute
+			for _, dp := range candidates[key{dance.ID, position.PositionID}] {
+				in := dp.Dancer.ID
+				if slices.Contains(assignment, in) {
+					continue
+				}
+
+				if limit := limits[in]; !limit.Soft && limit.MaxDances > 0 && loads[in] >= limit.MaxDances {
+					continue
+				}
+
+				swapped := slices.Clone(assignment)
+				swapped[i] = in
+
+				if breaksPair(pairs, swapped, facing, out) {
+					continue
+				}
+
+				if dance.Caller != model.CallerNone && !stillCalled(dance.Caller, set.CallerFor(dance), callers[dance.ID], out, swapped) {
+					continue
+				}
+
+				subs = append(subs, model.Substitute{Dancer: dp.Dancer, Preference: dp.Preference, Dances: loads[in]})
+			}
+
+			sort.SliceStable(subs, func(a, b int) bool {
+				if subs[a].Preference != subs[b].Preference {
+					return subs[a].Preference > subs[b].Preference
+				}
+				if subs[a].Dances != subs[b].Dances {
+					return subs[a].Dances < subs[b].Dances
+				}
+				return subs[a].Dancer.Name < subs[b].Dancer.Name
+			})
+
+			substitutes[dance][position] = subs
+		}
+	}
+
+	return substitutes
+}
+
+// breaksPair says whether `assignment` breaks any of the pair rules which
+// don't involve `out`.
+func breaksPair(pairs []pairCheck, assignment []int, facing [][2]int, out int) bool {
+	for _, check := range pairs {
+		if check.a == out || check.b == out {
+			continue
+		}
+		if _, _, broken := check.broken(assignment, facing, true); broken {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stillCalled says whether a dance with `assignment` can still be called once
+// `out` has dropped out. Whoever was calling it carries on if they can;
+// otherwise somebody else from `callers` has to.
+func stillCalled(rule model.CallerRule, caller *model.Dancer, callers []int, out int, assignment []int) bool {
+	if caller != nil && caller.ID != out && callerAllowed(rule, caller.ID, assignment) {
+		return true
+	}
+
+	others := slices.DeleteFunc(slices.Clone(callers), func(c int) bool { return c == out })
+	_, ok := chooseCaller(rule, others, assignment)
+
+	return ok
+}
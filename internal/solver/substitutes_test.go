@@ -0,0 +1,98 @@
+package solver
+
+import (
+	"testing"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindSubstitutes(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: false}
+	erin := &model.Dancer{ID: 5, Name: "Erin", Active: true}
+	frank := &model.Dancer{ID: 6, Name: "Frank", Active: true}
+
+	dances := goTestDances(2, 1)
+	a, b := dances[0], dances[1]
+
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceYes),
+		goTestDP(bob, a, 2, model.PreferenceYes),
+		// Bob is already in A, so can't step in for Alice
+		goTestDP(bob, a, 1, model.PreferenceFavourite),
+		goTestDP(carol, a, 1, model.PreferenceMaybe),
+		goTestDP(dave, a, 1, model.PreferenceFavourite),
+		goTestDP(erin, a, 1, model.PreferenceYes),
+		goTestDP(frank, a, 1, model.PreferenceYes),
+		goTestDP(frank, a, 2, model.PreferenceNo),
+		goTestDP(erin, b, 1, model.PreferenceYes),
+	}
+
+	set := model.NewAssignmentSet(
+		model.Assignments{
+			a: {a.Positions[0]: alice, a.Positions[1]: bob},
+			b: {b.Positions[0]: erin},
+		},
+		model.DancesDanced{a: {}, b: {}},
+	)
+
+	names := func(substitutes []model.Substitute) []string {
+		var n []string
+		for _, substitute := range substitutes {
+			n = append(n, substitute.Dancer.Name)
+		}
+		return n
+	}
+
+	t.Run("ranked", func(t *testing.T) {
+		substitutes := FindSubstitutes(set, dps, Constraints{})
+
+		// Erin and Frank both said yes, but Erin is already dancing B
+		subs := substitutes[a][a.Positions[0]]
+		require.Equal(t, []string{"Frank", "Erin", "Carol"}, names(subs))
+		require.Equal(t, model.Substitute{Dancer: erin, Preference: model.PreferenceYes, Dances: 1}, subs[1])
+
+		require.Empty(t, substitutes[a][a.Positions[1]])
+		require.Empty(t, substitutes[b][b.Positions[0]])
+	})
+
+	t.Run("constraints", func(t *testing.T) {
+		substitutes := FindSubstitutes(set, dps, Constraints{
+			DancerLimits: map[*model.Dancer]DancerLimit{
+				erin:  {MaxDances: 1},
+				carol: {MaxDances: 0},
+			},
+			Pairs: []Pair{
+				{Dancer: frank, Other: bob, Rule: model.PairApart},
+				// Alice is the one dropping out, so this can't be kept
+				{Dancer: alice, Other: bob, Rule: model.PairTogether},
+			},
+		})
+
+		require.Equal(t, []string{"Carol"}, names(substitutes[a][a.Positions[0]]))
+	})
+
+	t.Run("callers", func(t *testing.T) {
+		a.Caller = model.CallerFromDancers
+		defer func() { a.Caller = model.CallerNone }()
+
+		// Alice is calling A, so whoever steps in for her has to call it
+		// instead
+		called := set.WithCallers(model.Callers{a: alice})
+		substitutes := FindSubstitutes(called, dps, Constraints{Callers: []Caller{
+			{Dancer: alice, Dance: a},
+			{Dancer: carol, Dance: a},
+		}})
+		require.Equal(t, []string{"Carol"}, names(substitutes[a][a.Positions[0]]))
+
+		// but if somebody else is calling it, anybody can
+		called = set.WithCallers(model.Callers{a: bob})
+		substitutes = FindSubstitutes(called, dps, Constraints{Callers: []Caller{{Dancer: bob, Dance: a}}})
+		require.Equal(t, []string{"Frank", "Erin", "Carol"}, names(substitutes[a][a.Positions[0]]))
+	})
+}
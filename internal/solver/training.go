@@ -0,0 +1,186 @@
+package solver
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// Training is one dancer learning one position.
+type Training struct {
+	Dancer   *model.Dancer
+	Position *model.Position
+	// Dances are the dances which some of the turnouts can't dance now, but
+	// could if the dancer learnt the position. There's more than one if the
+	// dance has other versions with a position of the same name.
+	Dances []*model.Dance
+	// Turnouts is how many of the turnouts it would unlock a dance for.
+	Turnouts int
+}
+
+// RecommendTraining suggests which dancer should learn which position of
+// `dances`. `dps` are everybody's preferences, and each of `turnouts` is a
+// group of dancers who might be there on the day, such as the people who came
+// to a past event.
+//
+// For each turnout, every dancer in it is tried in every position of the
+// dances it can't dance, as if they'd learnt it. Learning a position means
+// learning it in every version of the dance. The suggestions are ranked by
+// how many turnouts they'd unlock a dance for, and ones which wouldn't unlock
+// anything are left out. Musicians aren't suggested, and like WhatIf, pair
+// rules aren't looked at.
+func RecommendTraining(
+	dances []*model.Dance,
+	dps []*model.DancerPosition,
+	turnouts [][]*model.Dancer,
+	constraints Constraints,
+) []Training {
+	type ability struct {
+		dancerID   int
+		danceID    int
+		positionID int
+	}
+
+	able := make(map[ability]struct{})
+	for _, dp := range dps {
+		if canDance(dp) {
+			able[ability{dp.Dancer.ID, dp.Dance.ID, dp.Position.PositionID}] = struct{}{}
+		}
+	}
+
+	var versions []int
+	byVersion := make(map[int][]*model.Dance)
+	for _, dance := range dances {
+		version := dance.VersionOf()
+		if _, ok := byVersion[version]; !ok {
+			versions = append(versions, version)
+		}
+		byVersion[version] = append(byVersion[version], dance)
+	}
+
+	type key struct {
+		dancerID int
+		version  int
+		position string
+	}
+
+	suggestions := make(map[key]*Training)
+	var order []key
+
+	for _, turnout := range turnouts {
+		here := make(map[int]struct{}, len(turnout))
+		for _, dancer := range turnout {
+			here[dancer.ID] = struct{}{}
+		}
+
+		var theirs []*model.DancerPosition
+		for _, dp := range dps {
+			if _, ok := here[dp.Dancer.ID]; ok {
+				theirs = append(theirs, dp)
+			}
+		}
+
+		// worked out once per turnout, then each dance is checked with what
+		// somebody would learn added
+		e := newEligibility(theirs, constraints)
+		now := e.danceableDances(theirs)
+
+		for _, version := range versions {
+			// the versions this turnout can't dance, and the positions in them
+			var lacking []*model.Dance
+			var names []string
+			first := make(map[string]*model.Position)
+			for _, dance := range byVersion[version] {
+				if _, ok := now[dance.ID]; ok {
+					continue
+				}
+
+				lacking = append(lacking, dance)
+				for _, position := range dance.Positions {
+					if _, ok := first[position.Name]; !ok {
+						first[position.Name] = position
+						names = append(names, position.Name)
+					}
+				}
+			}
+
+			for _, name := range names {
+				for _, dancer := range turnout {
+					if !dancer.Active || dancer.Type == model.RoleMusician {
+						continue
+					}
+
+					var unlocked []*model.Dance
+					for _, dance := range lacking {
+						var learnt []*model.DancerPosition
+						for _, position := range dance.Positions {
+							if _, ok := able[ability{dancer.ID, dance.ID, position.PositionID}]; ok || position.Name != name {
+								continue
+							}
+
+							learnt = append(learnt, &model.DancerPosition{
+								DancerID:   dancer.ID,
+								DanceID:    dance.ID,
+								PositionID: position.PositionID,
+								Dancer:     dancer,
+								Dance:      dance,
+								Position:   position,
+								Preference: model.PreferenceMaybe,
+							})
+						}
+						if len(learnt) == 0 {
+							continue
+						}
+
+						// everybody in the turnout, not just those who can
+						// dance it, in case the caller is standing out
+						if e.danceable(dance, learnt) {
+							unlocked = append(unlocked, dance)
+						}
+					}
+
+					if len(unlocked) == 0 {
+						continue
+					}
+
+					k := key{dancer.ID, version, name}
+					training, ok := suggestions[k]
+					if !ok {
+						training = &Training{Dancer: dancer, Position: first[name]}
+						suggestions[k] = training
+						order = append(order, k)
+					}
+					training.Turnouts++
+					for _, dance := range unlocked {
+						if !slices.Contains(training.Dances, dance) {
+							training.Dances = append(training.Dances, dance)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	trainings := make([]Training, 0, len(order))
+	for _, k := range order {
+		training := *suggestions[k]
+		sort.Slice(training.Dances, func(i, j int) bool { return training.Dances[i].Name < training.Dances[j].Name })
+		trainings = append(trainings, training)
+	}
+
+	sort.SliceStable(trainings, func(i, j int) bool {
+		if trainings[i].Turnouts != trainings[j].Turnouts {
+			return trainings[i].Turnouts > trainings[j].Turnouts
+		}
+		if trainings[i].Dancer.Name != trainings[j].Dancer.Name {
+			return trainings[i].Dancer.Name < trainings[j].Dancer.Name
+		}
+		if trainings[i].Dances[0].Name != trainings[j].Dances[0].Name {
+			return trainings[i].Dances[0].Name < trainings[j].Dances[0].Name
+		}
+		return trainings[i].Position.Name < trainings[j].Position.Name
+	})
+
+	return trainings
+}
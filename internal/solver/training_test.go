@@ -0,0 +1,84 @@
+package solver
+
+import (
+	"testing"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecommendTraining(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true, Type: model.RoleMusician}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: true}
+
+	dances := goTestDances(2, 2, 2)
+	a, b, c := dances[0], dances[1], dances[2]
+	c.VariantOf = a.ID
+
+	// nobody can dance the second position of A or C
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceYes),
+		goTestDP(bob, a, 1, model.PreferenceYes),
+		goTestDP(dave, a, 1, model.PreferenceMaybe),
+		goTestDP(alice, c, 1, model.PreferenceYes),
+		goTestDP(alice, b, 1, model.PreferenceYes),
+		goTestDP(bob, b, 2, model.PreferenceYes),
+		goTestDP(dave, b, 2, model.PreferenceYes),
+	}
+
+	turnouts := [][]*model.Dancer{
+		{alice, bob},
+		{alice, bob, carol},
+		{alice, dave},
+	}
+
+	trainings := RecommendTraining(dances, dps, turnouts, Constraints{})
+
+	type suggestion struct {
+		dancer   string
+		position string
+		dances   []*model.Dance
+		turnouts int
+	}
+
+	var got []suggestion
+	for _, training := range trainings {
+		got = append(got, suggestion{training.Dancer.Name, training.Position.Name, training.Dances, training.Turnouts})
+	}
+
+	require.Equal(t, []suggestion{
+		{"Alice", "2", []*model.Dance{a}, 3},
+		// learning it covers both versions
+		{"Bob", "2", []*model.Dance{a, c}, 2},
+		{"Dave", "2", []*model.Dance{a, c}, 1},
+	}, got)
+
+	// excluded dances aren't worth learning
+	trainings = RecommendTraining(dances, dps, turnouts, Constraints{Exclude: []*model.Dance{a, c}})
+	require.Empty(t, trainings)
+
+	t.Run("caller standing out", func(t *testing.T) {
+		erin := &model.Dancer{ID: 5, Name: "Erin", Active: true, Type: model.RoleMusician}
+
+		dances := goTestDances(1, 1)
+		d, e := dances[0], dances[1]
+		d.Caller = model.CallerStandingOut
+
+		// Erin plays for D and calls it, but doesn't dance it
+		dps := []*model.DancerPosition{
+			goTestDP(alice, e, 1, model.PreferenceYes),
+			goTestDP(erin, e, 1, model.PreferenceYes),
+		}
+
+		trainings := RecommendTraining(dances, dps, [][]*model.Dancer{{alice, erin}}, Constraints{
+			Callers: []Caller{{Dancer: erin, Dance: d}},
+		})
+		require.Len(t, trainings, 1)
+		require.Equal(t, alice, trainings[0].Dancer)
+		require.Equal(t, []*model.Dance{d}, trainings[0].Dances)
+	})
+}
@@ -0,0 +1,127 @@
+package solver
+
+import "fmt"
+
+type SolverStatus int
+
+// These mirror the SolverStatus enum in the C library
+const (
+	SolverStatusUnknown      SolverStatus = 0
+	SolverStatusModelInvalid SolverStatus = 1
+	SolverStatusFeasible     SolverStatus = 2
+	SolverStatusInfeasible   SolverStatus = 3
+	SolverStatusOptimal      SolverStatus = 4
+	// SolverStatusCancelled means the solve was stopped before it finished.
+	// The set is the best found so far, which might be empty.
+	SolverStatusCancelled SolverStatus = 5
+)
+
+func (s SolverStatus) String() string {
+	switch s {
+	case SolverStatusUnknown:
+		return "Unknown"
+	case SolverStatusModelInvalid:
+		return "ModelInvalid"
+	case SolverStatusFeasible:
+		return "Feasible"
+	case SolverStatusInfeasible:
+		return "Infeasible"
+	case SolverStatusOptimal:
+		return "Optimal"
+	case SolverStatusCancelled:
+		return "Cancelled"
+	default:
+		return fmt.Sprintf("Unknown SolverStatus: %d", s)
+	}
+}
+
+type DancePreference int
+
+// These mirror the DancePreference enum in the C library
+const (
+	PreferenceNo        DancePreference = 0
+	PreferenceMaybe     DancePreference = 1
+	PreferenceYes       DancePreference = 2
+	PreferenceFavourite DancePreference = 3
+)
+
+func (p DancePreference) String() string {
+	switch p {
+	case PreferenceNo:
+		return "No"
+	case PreferenceMaybe:
+		return "Maybe"
+	case PreferenceYes:
+		return "Yes"
+	case PreferenceFavourite:
+		return "Favourite"
+	default:
+		return fmt.Sprintf("Unknown DancePreference: %d", p)
+	}
+}
+
+// AlternativeDifference says how alternative sets have to differ from each
+// other.
+type AlternativeDifference int
+
+const (
+	// AlternativeDifferenceDances counts the dances which are in one set but
+	// not the other.
+	AlternativeDifferenceDances AlternativeDifference = 0
+	// AlternativeDifferenceAssignments counts the positions which are danced by
+	// somebody different.
+	AlternativeDifferenceAssignments AlternativeDifference = 1
+)
+
+func (d AlternativeDifference) String() string {
+	switch d {
+	case AlternativeDifferenceDances:
+		return "dances"
+	case AlternativeDifferenceAssignments:
+		return "assignments"
+	default:
+		return fmt.Sprintf("Unknown AlternativeDifference: %d", d)
+	}
+}
+
+type DancerPositionStatus int
+
+const (
+	DancerPositionStatusUnknown DancerPositionStatus = 0
+	DancerPositionStatusNo      DancerPositionStatus = 1
+	DancerPositionStatusYes     DancerPositionStatus = 2
+)
+
+func (dps DancerPositionStatus) String() string {
+	switch dps {
+	case DancerPositionStatusUnknown:
+		return "Unknown"
+	case DancerPositionStatusNo:
+		return "No"
+	case DancerPositionStatusYes:
+		return "Yes"
+	default:
+		return fmt.Sprintf("Unknown DancerPositionStatus: %d", dps)
+	}
+}
+
+// DumpFormat is how models and responses are written out by `Dump`.
+type DumpFormat int
+
+const (
+	// DumpFormatText is protobuf text format, which can be read and edited.
+	DumpFormatText DumpFormat = 0
+	// DumpFormatBinary is binary protobuf, which is smaller.
+	DumpFormatBinary DumpFormat = 1
+)
+
+func (f DumpFormat) String() string {
+	switch f {
+	case DumpFormatText:
+		return "text"
+	case DumpFormatBinary:
+		return "binary"
+	default:
+		return fmt.Sprintf("Unknown DumpFormat: %d", f)
+	}
+}
@@ -0,0 +1,355 @@
+package solver
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+)
+
+// ViolationKind is which rule a set breaks.
+type ViolationKind int
+
+const (
+	// ViolationIncomplete means a dance is danced, but one of its positions
+	// has nobody in it.
+	ViolationIncomplete ViolationKind = iota + 1
+	// ViolationNotDanced means a dance has people in it, but isn't danced.
+	ViolationNotDanced
+	// ViolationDancerTwice means somebody is in two positions of the same
+	// dance.
+	ViolationDancerTwice
+	// ViolationInactive means somebody who isn't active, or isn't here, is
+	// dancing.
+	ViolationInactive
+	// ViolationPreferenceNo means somebody is dancing a position they've said
+	// "no" to, or haven't said anything about.
+	ViolationPreferenceNo
+	// ViolationExcluded means an excluded dance is danced.
+	ViolationExcluded
+	// ViolationNotIncluded means an included dance isn't danced.
+	ViolationNotIncluded
+	// ViolationPinBroken means a pinned dancer isn't in their position.
+	ViolationPinBroken
+	// ViolationTooFewDances and ViolationTooManyDances mean the set is
+	// shorter or longer than it's allowed to be.
+	ViolationTooFewDances
+	ViolationTooManyDances
+	// ViolationDancerTooFewDances and ViolationDancerTooManyDances mean
+	// somebody is dancing fewer or more dances than their hard limit.
+	ViolationDancerTooFewDances
+	ViolationDancerTooManyDances
+	// ViolationPair means a dance breaks a pair rule.
+	ViolationPair
+	// ViolationNoCaller means a dance which needs a caller hasn't got one.
+	ViolationNoCaller
+	// ViolationCaller means a dance is called by somebody who can't call it
+	// or isn't here.
+	ViolationCaller
+	// ViolationCallerRule means a dance's caller is dancing it when they
+	// should be standing out, or the other way round.
+	ViolationCallerRule
+	// ViolationVersions means more than one version of a dance is danced.
+	ViolationVersions
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case ViolationIncomplete:
+		return "incomplete"
+	case ViolationNotDanced:
+		return "not danced"
+	case ViolationDancerTwice:
+		return "dancer twice"
+	case ViolationInactive:
+		return "inactive"
+	case ViolationPreferenceNo:
+		return "preference no"
+	case ViolationExcluded:
+		return "excluded"
+	case ViolationNotIncluded:
+		return "not included"
+	case ViolationPinBroken:
+		return "pin broken"
+	case ViolationTooFewDances:
+		return "too few dances"
+	case ViolationTooManyDances:
+		return "too many dances"
+	case ViolationDancerTooFewDances:
+		return "dancer too few dances"
+	case ViolationDancerTooManyDances:
+		return "dancer too many dances"
+	case ViolationPair:
+		return "pair"
+	case ViolationNoCaller:
+		return "no caller"
+	case ViolationCaller:
+		return "caller"
+	case ViolationCallerRule:
+		return "caller rule"
+	case ViolationVersions:
+		return "versions"
+	default:
+		return fmt.Sprintf("Unknown ViolationKind: %d", k)
+	}
+}
+
+// Violation is one way a set breaks the rules. Only the fields which make sense
+// for the kind are set.
+type Violation struct {
+	Kind     ViolationKind
+	Dance    *model.Dance
+	Position *model.Position
+	Dancer   *model.Dancer
+	Pair     *Pair
+	// Version is another version of Dance which is danced too.
+	Version *model.Dance
+
+	// Count is how many dances there are in the set, or that Dancer is in,
+	// and Limit is what it should have been at least or at most.
+	Count int
+	Limit int
+}
+
+func (v Violation) String() string {
+	switch v.Kind {
+	case ViolationIncomplete:
+		return fmt.Sprintf("%s: nobody is dancing %s", v.Dance.Name, v.Position.Name)
+	case ViolationNotDanced:
+		return fmt.Sprintf("%s: %s is dancing %s, but the dance isn't in the set", v.Dance.Name, v.Dancer.Name, v.Position.Name)
+	case ViolationDancerTwice:
+		return fmt.Sprintf("%s: %s is dancing %s as well as another position", v.Dance.Name, v.Dancer.Name, v.Position.Name)
+	case ViolationInactive:
+		return fmt.Sprintf("%s: %s is dancing %s, but isn't here", v.Dance.Name, v.Dancer.Name, v.Position.Name)
+	case ViolationPreferenceNo:
+		return fmt.Sprintf("%s: %s is dancing %s, but has said no to it", v.Dance.Name, v.Dancer.Name, v.Position.Name)
+	case ViolationExcluded:
+		return fmt.Sprintf("%s: excluded, but danced", v.Dance.Name)
+	case ViolationNotIncluded:
+		return fmt.Sprintf("%s: included, but not danced", v.Dance.Name)
+	case ViolationPinBroken:
+		return fmt.Sprintf("%s: %s is pinned to %s, but isn't dancing it", v.Dance.Name, v.Dancer.Name, v.Position.Name)
+	case ViolationTooFewDances:
+		return fmt.Sprintf("%d dances, but the set needs at least %d", v.Count, v.Limit)
+	case ViolationTooManyDances:
+		return fmt.Sprintf("%d dances, but the set can have at most %d", v.Count, v.Limit)
+	case ViolationDancerTooFewDances:
+		return fmt.Sprintf("%s is dancing %d dances, but should dance at least %d", v.Dancer.Name, v.Count, v.Limit)
+	case ViolationDancerTooManyDances:
+		return fmt.Sprintf("%s is dancing %d dances, but should dance at most %d", v.Dancer.Name, v.Count, v.Limit)
+	case ViolationPair:
+		return fmt.Sprintf("%s: breaks %s", v.Dance.Name, v.Pair)
+	case ViolationNoCaller:
+		return fmt.Sprintf("%s: nobody is calling it", v.Dance.Name)
+	case ViolationCaller:
+		return fmt.Sprintf("%s: %s is calling it, but can't", v.Dance.Name, v.Dancer.Name)
+	case ViolationCallerRule:
+		return fmt.Sprintf("%s: %s is calling it, but its caller should be %s", v.Dance.Name, v.Dancer.Name, v.Dance.Caller)
+	case ViolationVersions:
+		return fmt.Sprintf("%s: %s is danced too, but they're versions of the same dance", v.Dance.Name, v.Version.Name)
+	default:
+		return v.Kind.String()
+	}
+}
+
+// ViolationsError is returned when a solver comes back with a set which breaks
+// the rules.
+type ViolationsError []Violation
+
+func (e ViolationsError) Error() string {
+	s := make([]string, 0, len(e))
+	for _, v := range e {
+		s = append(s, v.String())
+	}
+
+	return "set breaks the rules: " + strings.Join(s, "; ")
+}
+
+// Validate checks `set` against the rules which every set must follow and
+// against `constraints`, independently of any solver. `dps` are the
+// preferences of the dancers who are here. It's for sets which have been
+// edited by hand or come from somewhere else, and for checking the solvers
+// themselves. Everything is matched by ID, so a set which was saved and loaded
+// again can be checked against freshly fetched preferences.
+//
+// Soft dancer limits and repairs aren't rules, so they're not checked, and
+// like the solvers, limits for anybody who isn't here are ignored.
+func Validate(set model.AssignmentSet, dps []*model.DancerPosition, constraints Constraints) []Violation {
+	type key struct {
+		dancerID   int
+		danceID    int
+		positionID int
+	}
+
+	preferences := make(map[key]model.DancePreference, len(dps))
+	dances := make(map[int]*model.Dance)
+	// anybody without any preferences isn't here
+	here := make(map[int]struct{})
+	for _, dp := range dps {
+		preferences[key{dp.Dancer.ID, dp.Dance.ID, dp.Position.PositionID}] = dp.Preference
+		dances[dp.Dance.ID] = dp.Dance
+		here[dp.Dancer.ID] = struct{}{}
+	}
+	callers := activeCallers(constraints.Callers, dps, dancerID)
+	for _, dance := range set.Dances() {
+		dances[dance.ID] = dance
+	}
+
+	// check in a stable order, so the violations are too
+	ordered := make([]*model.Dance, 0, len(dances))
+	for _, dance := range dances {
+		ordered = append(ordered, dance)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].Name != ordered[j].Name {
+			return ordered[i].Name < ordered[j].Name
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	var violations []Violation
+	danced := make(map[int]struct{})
+	// version -> the dance danced for it
+	versions := make(map[int]*model.Dance)
+	dancerDances := make(map[int]int)
+	pairs := activePairs(constraints.Pairs, dps, dancerID)
+
+	for _, dance := range ordered {
+		isDanced := dance.IsDanced(set)
+		if isDanced {
+			danced[dance.ID] = struct{}{}
+
+			if other, ok := versions[dance.VersionOf()]; ok {
+				violations = append(violations, Violation{Kind: ViolationVersions, Dance: dance, Version: other})
+			} else {
+				versions[dance.VersionOf()] = dance
+			}
+		}
+
+		inDance := make(map[int]struct{})
+		assignment := make([]int, len(dance.Positions))
+		for i, position := range dance.Positions {
+			dancer := set.DancerFor(dance, position)
+
+			assignment[i] = -1
+			if dancer != nil && isDanced {
+				assignment[i] = dancer.ID
+			}
+
+			switch {
+			case dancer == nil && isDanced:
+				violations = append(violations, Violation{Kind: ViolationIncomplete, Dance: dance, Position: position})
+				continue
+			case dancer == nil:
+				continue
+			case !isDanced:
+				violations = append(violations, Violation{Kind: ViolationNotDanced, Dance: dance, Position: position, Dancer: dancer})
+				continue
+			}
+
+			if _, ok := inDance[dancer.ID]; ok {
+				violations = append(violations, Violation{Kind: ViolationDancerTwice, Dance: dance, Position: position, Dancer: dancer})
+			} else {
+				inDance[dancer.ID] = struct{}{}
+				dancerDances[dancer.ID]++
+			}
+
+			_, isHere := here[dancer.ID]
+			switch {
+			case !dancer.Active || !isHere:
+				violations = append(violations, Violation{Kind: ViolationInactive, Dance: dance, Position: position, Dancer: dancer})
+			case preferences[key{dancer.ID, dance.ID, position.PositionID}] == model.PreferenceNo:
+				violations = append(violations, Violation{Kind: ViolationPreferenceNo, Dance: dance, Position: position, Dancer: dancer})
+			}
+		}
+
+		if !isDanced {
+			continue
+		}
+
+		facing := facingPositions(dance)
+		for _, check := range pairs {
+			if _, _, broken := check.broken(assignment, facing, true); broken {
+				pair := check.pair
+				violations = append(violations, Violation{Kind: ViolationPair, Dance: dance, Pair: &pair})
+			}
+		}
+
+		if dance.Caller == model.CallerNone {
+			continue
+		}
+
+		caller := set.CallerFor(dance)
+		switch {
+		case caller == nil:
+			violations = append(violations, Violation{Kind: ViolationNoCaller, Dance: dance})
+		case !slices.Contains(callers[dance.ID], caller.ID):
+			violations = append(violations, Violation{Kind: ViolationCaller, Dance: dance, Dancer: caller})
+		case !callerAllowed(dance.Caller, caller.ID, assignment):
+			violations = append(violations, Violation{Kind: ViolationCallerRule, Dance: dance, Dancer: caller})
+		}
+	}
+
+	for _, dance := range constraints.Exclude {
+		if _, ok := danced[dance.ID]; ok {
+			violations = append(violations, Violation{Kind: ViolationExcluded, Dance: dance})
+		}
+	}
+
+	for _, dance := range constraints.Include {
+		if _, ok := danced[dance.ID]; !ok {
+			violations = append(violations, Violation{Kind: ViolationNotIncluded, Dance: dance})
+		}
+	}
+
+	for _, pin := range constraints.Pins {
+		dance, ok := dances[pin.Dance.ID]
+		if !ok {
+			dance = pin.Dance
+		}
+
+		var dancer *model.Dancer
+		for _, position := range dance.Positions {
+			if position.PositionID == pin.Position.PositionID {
+				dancer = set.DancerFor(dance, position)
+				break
+			}
+		}
+
+		if _, ok := danced[dance.ID]; !ok || dancer == nil || dancer.ID != pin.Dancer.ID {
+			violations = append(violations, Violation{Kind: ViolationPinBroken, Dance: pin.Dance, Position: pin.Position, Dancer: pin.Dancer})
+		}
+	}
+
+	count := len(danced)
+	if constraints.MinDances > 0 && count < constraints.MinDances {
+		violations = append(violations, Violation{Kind: ViolationTooFewDances, Count: count, Limit: constraints.MinDances})
+	}
+	if constraints.MaxDances > 0 && count > constraints.MaxDances {
+		violations = append(violations, Violation{Kind: ViolationTooManyDances, Count: count, Limit: constraints.MaxDances})
+	}
+
+	limited := make([]*model.Dancer, 0, len(constraints.DancerLimits))
+	for dancer, limit := range constraints.DancerLimits {
+		if _, isHere := here[dancer.ID]; isHere && !limit.Soft && !limit.IsZero() {
+			limited = append(limited, dancer)
+		}
+	}
+	sort.Slice(limited, func(i, j int) bool { return limited[i].ID < limited[j].ID })
+
+	for _, dancer := range limited {
+		limit := constraints.DancerLimits[dancer]
+		n := dancerDances[dancer.ID]
+
+		if limit.MinDances > 0 && n < limit.MinDances {
+			violations = append(violations, Violation{Kind: ViolationDancerTooFewDances, Dancer: dancer, Count: n, Limit: limit.MinDances})
+		}
+		if limit.MaxDances > 0 && n > limit.MaxDances {
+			violations = append(violations, Violation{Kind: ViolationDancerTooManyDances, Dancer: dancer, Count: n, Limit: limit.MaxDances})
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,229 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: false}
+
+	dances := goTestDances(2, 1)
+	a, b := dances[0], dances[1]
+
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceYes),
+		goTestDP(bob, a, 2, model.PreferenceYes),
+		goTestDP(bob, a, 1, model.PreferenceNo),
+		goTestDP(carol, b, 1, model.PreferenceMaybe),
+		goTestDP(dave, b, 1, model.PreferenceYes),
+	}
+
+	good := model.NewAssignmentSet(
+		model.Assignments{
+			a: {a.Positions[0]: alice, a.Positions[1]: bob},
+			b: {b.Positions[0]: carol},
+		},
+		model.DancesDanced{a: {}, b: {}},
+	)
+
+	kinds := func(violations []Violation) []ViolationKind {
+		var k []ViolationKind
+		for _, v := range violations {
+			k = append(k, v.Kind)
+		}
+		return k
+	}
+
+	t.Run("good set", func(t *testing.T) {
+		require.Empty(t, Validate(good, dps, Constraints{}))
+	})
+
+	t.Run("empty set", func(t *testing.T) {
+		require.Empty(t, Validate(model.NewAssignmentSet(model.Assignments{}, model.DancesDanced{}), dps, Constraints{}))
+	})
+
+	t.Run("hand edited", func(t *testing.T) {
+		// Bob has said no to position 1, Alice hasn't said anything about B,
+		// and Dave isn't here
+		set := model.NewAssignmentSet(
+			model.Assignments{
+				a: {a.Positions[0]: bob, a.Positions[1]: bob},
+				b: {b.Positions[0]: dave},
+			},
+			model.DancesDanced{a: {}, b: {}},
+		)
+
+		violations := Validate(set, dps, Constraints{})
+		require.Equal(t, []ViolationKind{ViolationPreferenceNo, ViolationDancerTwice, ViolationInactive}, kinds(violations))
+		require.Equal(t, "A: Bob is dancing 1, but has said no to it", violations[0].String())
+		require.Equal(t, "A: Bob is dancing 2 as well as another position", violations[1].String())
+		require.Equal(t, "B: Dave is dancing 1, but isn't here", violations[2].String())
+	})
+
+	t.Run("not here", func(t *testing.T) {
+		// Erin is active, but has no preferences because they aren't here
+		erin := &model.Dancer{ID: 5, Name: "Erin", Active: true}
+		set := model.NewAssignmentSet(
+			model.Assignments{b: {b.Positions[0]: erin}},
+			model.DancesDanced{b: {}},
+		)
+
+		violations := Validate(set, dps, Constraints{})
+		require.Equal(t, []ViolationKind{ViolationInactive}, kinds(violations))
+		require.Equal(t, "B: Erin is dancing 1, but isn't here", violations[0].String())
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		set := model.NewAssignmentSet(
+			model.Assignments{
+				a: {a.Positions[0]: alice},
+				b: {b.Positions[0]: carol},
+			},
+			model.DancesDanced{a: {}},
+		)
+
+		violations := Validate(set, dps, Constraints{})
+		require.Equal(t, []ViolationKind{ViolationIncomplete, ViolationNotDanced}, kinds(violations))
+		require.Equal(t, a.Positions[1], violations[0].Position)
+		require.Equal(t, carol, violations[1].Dancer)
+	})
+
+	t.Run("matched by ID", func(t *testing.T) {
+		// as if the set had been saved and loaded again
+		loadedAlice := &model.Dancer{ID: alice.ID, Name: alice.Name, Active: true}
+		set := model.NewAssignmentSet(
+			model.Assignments{a: {a.Positions[0]: loadedAlice, a.Positions[1]: bob}},
+			model.DancesDanced{a: {}},
+		)
+
+		require.Empty(t, Validate(set, dps, Constraints{}))
+	})
+
+	t.Run("constraints", func(t *testing.T) {
+		violations := Validate(good, dps, Constraints{
+			MinDances: 3,
+			Exclude:   []*model.Dance{b},
+			Pins:      []Pin{{Dancer: bob, Dance: b, Position: b.Positions[0]}},
+			DancerLimits: map[*model.Dancer]DancerLimit{
+				alice: {MinDances: 2},
+				bob:   {MaxDances: 0, MinDances: 2, Soft: true},
+				carol: {MaxDances: 0},
+			},
+		})
+		require.Equal(t, []ViolationKind{
+			ViolationExcluded,
+			ViolationPinBroken,
+			ViolationTooFewDances,
+			ViolationDancerTooFewDances,
+		}, kinds(violations))
+		require.Equal(t, "2 dances, but the set needs at least 3", violations[2].String())
+		require.Equal(t, "Alice is dancing 1 dances, but should dance at least 2", violations[3].String())
+
+		violations = Validate(good, dps, Constraints{
+			MaxDances:    1,
+			Include:      []*model.Dance{a},
+			DancerLimits: map[*model.Dancer]DancerLimit{bob: {MaxDances: 1}, carol: {MaxDances: 1}},
+		})
+		require.Equal(t, []ViolationKind{ViolationTooManyDances}, kinds(violations))
+	})
+
+	t.Run("limits for somebody not here", func(t *testing.T) {
+		// the solvers ignore them, so Validate does too
+		erin := &model.Dancer{ID: 5, Name: "Erin", Active: true}
+		require.Empty(t, Validate(good, dps, Constraints{
+			DancerLimits: map[*model.Dancer]DancerLimit{erin: {MinDances: 1}},
+		}))
+	})
+
+	t.Run("pairs", func(t *testing.T) {
+		violations := Validate(good, dps, Constraints{Pairs: []Pair{
+			{Dancer: alice, Other: bob, Rule: model.PairApart},
+			{Dancer: alice, Other: carol, Rule: model.PairTogether},
+			// Dave isn't here, so this doesn't apply
+			{Dancer: carol, Other: dave, Rule: model.PairTogether},
+		}})
+		require.Equal(t, []ViolationKind{ViolationPair, ViolationPair, ViolationPair}, kinds(violations))
+		require.Equal(t, "A: breaks Alice and Bob never in the same dance", violations[0].String())
+		require.Equal(t, "A: breaks Alice and Carol together", violations[1].String())
+		require.Equal(t, "B: breaks Alice and Carol together", violations[2].String())
+
+		a.Positions[0].FacingID = 2
+		defer func() { a.Positions[0].FacingID = 0 }()
+		violations = Validate(good, dps, Constraints{Pairs: []Pair{{Dancer: bob, Other: alice, Rule: model.PairNotFacing}}})
+		require.Equal(t, []ViolationKind{ViolationPair}, kinds(violations))
+		require.Equal(t, "A: breaks Bob and Alice not facing", violations[0].String())
+	})
+
+	t.Run("callers", func(t *testing.T) {
+		a.Caller = model.CallerStandingOut
+		b.Caller = model.CallerFromDancers
+		defer func() { a.Caller, b.Caller = model.CallerNone, model.CallerNone }()
+
+		constraints := Constraints{Callers: []Caller{
+			{Dancer: alice, Dance: a},
+			{Dancer: carol, Dance: a},
+			{Dancer: carol, Dance: b},
+		}}
+
+		violations := Validate(good, dps, constraints)
+		require.Equal(t, []ViolationKind{ViolationNoCaller, ViolationNoCaller}, kinds(violations))
+		require.Equal(t, "A: nobody is calling it", violations[0].String())
+
+		set := good.WithCallers(model.Callers{a: alice, b: bob})
+		violations = Validate(set, dps, constraints)
+		require.Equal(t, []ViolationKind{ViolationCallerRule, ViolationCaller}, kinds(violations))
+		require.Equal(t, "A: Alice is calling it, but its caller should be standing out", violations[0].String())
+		require.Equal(t, "B: Bob is calling it, but can't", violations[1].String())
+
+		require.Empty(t, Validate(good.WithCallers(model.Callers{a: carol, b: carol}), dps, constraints))
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		b.VariantOf = a.ID
+		defer func() { b.VariantOf = 0 }()
+
+		violations := Validate(good, dps, Constraints{})
+		require.Equal(t, []ViolationKind{ViolationVersions}, kinds(violations))
+		require.Equal(t, "B: A is danced too, but they're versions of the same dance", violations[0].String())
+	})
+}
+
+// brokenSolver always comes back with the same set.
+type brokenSolver struct {
+	Solver
+	set model.AssignmentSet
+}
+
+func (s brokenSolver) Solve(context.Context, *logrus.Entry, []*model.DancerPosition, Constraints) (SolveResult, error) {
+	return SolveResult{Set: s.set, Status: SolverStatusFeasible}, nil
+}
+
+func TestCheckedSolver(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	dances := goTestDances(1)
+	a := dances[0]
+	dps := []*model.DancerPosition{goTestDP(alice, a, 1, model.PreferenceNo)}
+
+	set := model.NewAssignmentSet(model.Assignments{a: {a.Positions[0]: alice}}, model.DancesDanced{a: {}})
+	solver := checkedSolver{brokenSolver{set: set}}
+
+	_, err := solver.Solve(context.Background(), logrus.WithField("test-name", t.Name()), dps, Constraints{})
+
+	var violations ViolationsError
+	require.True(t, errors.As(err, &violations))
+	require.Len(t, violations, 1)
+	require.Equal(t, ViolationPreferenceNo, violations[0].Kind)
+}
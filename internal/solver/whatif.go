@@ -0,0 +1,246 @@
+package solver
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+)
+
+// Unlock is what one dancer who isn't here would add if they came.
+type Unlock struct {
+	Dancer *model.Dancer
+	// Dances are the ones which can't be danced now, but could be with them
+	// as well.
+	Dances []*model.Dance
+
+	// ExtraDances is how many more dances the best set would have with them,
+	// and Gain is how much its objective would go up by. They're only worked
+	// out by SolveWhatIf.
+	ExtraDances int
+	Gain        int64
+}
+
+// eligibility is who can dance each position of the dances in some
+// preferences, and who can call them. It's worked out once, so single dances
+// can be checked with other dancers added without going over all of the
+// preferences again.
+type eligibility struct {
+	excluded map[int]struct{}
+	// dancer IDs, by dance and position
+	dancers map[eligibleKey][]int
+	// the dancers here who can call each dance, by dance ID
+	callers map[int][]int
+	// everybody who can call each dance, whether they're here or not
+	canCall map[callerKey]struct{}
+}
+
+type eligibleKey struct {
+	danceID    int
+	positionID int
+}
+
+type callerKey struct {
+	dancerID int
+	danceID  int
+}
+
+func newEligibility(dps []*model.DancerPosition, constraints Constraints) eligibility {
+	e := eligibility{
+		excluded: make(map[int]struct{}, len(constraints.Exclude)),
+		dancers:  make(map[eligibleKey][]int),
+		callers:  activeCallers(constraints.Callers, dps, dancerID),
+		canCall:  make(map[callerKey]struct{}, len(constraints.Callers)),
+	}
+
+	for _, dance := range constraints.Exclude {
+		e.excluded[dance.ID] = struct{}{}
+	}
+
+	for _, dp := range dps {
+		if canDance(dp) {
+			k := eligibleKey{dp.Dance.ID, dp.Position.PositionID}
+			e.dancers[k] = append(e.dancers[k], dp.Dancer.ID)
+		}
+	}
+
+	for _, caller := range constraints.Callers {
+		e.canCall[callerKey{caller.Dancer.ID, caller.Dance.ID}] = struct{}{}
+	}
+
+	return e
+}
+
+// danceable says whether `dance` could be danced by the dancers in the
+// preferences along with the ones in `extra`, as for `danceableDances`.
+func (e eligibility) danceable(dance *model.Dance, extra []*model.DancerPosition) bool {
+	if _, ok := e.excluded[dance.ID]; ok {
+		return false
+	}
+
+	positionEligible := make([][]int, 0, len(dance.Positions))
+	for _, position := range dance.Positions {
+		// clipped, so adding to it doesn't write over the shared slice
+		eligible := slices.Clip(e.dancers[eligibleKey{dance.ID, position.PositionID}])
+		for _, dp := range extra {
+			if dp.Dance.ID == dance.ID && dp.Position.PositionID == position.PositionID && canDance(dp) {
+				eligible = append(eligible, dp.Dancer.ID)
+			}
+		}
+		positionEligible = append(positionEligible, eligible)
+	}
+
+	callers := slices.Clip(e.callers[dance.ID])
+	for _, dp := range extra {
+		if _, ok := e.canCall[callerKey{dp.Dancer.ID, dance.ID}]; ok && dp.Dancer.Active && !slices.Contains(callers, dp.Dancer.ID) {
+			callers = append(callers, dp.Dancer.ID)
+		}
+	}
+
+	return newMatching(positionEligible).complete() && canBeCalled(dance, callers, positionEligible)
+}
+
+// danceableDances returns the IDs of the dances in `dps` which could be danced
+// by the dancers in it: every position can be filled at once, and somebody can
+// call it if it has to be called. Excluded dances aren't danceable. Pair rules
+// and the length of the set aren't looked at.
+func danceableDances(dps []*model.DancerPosition, constraints Constraints) map[int]struct{} {
+	return newEligibility(dps, constraints).danceableDances(dps)
+}
+
+// danceableDances is as for the function, where `dps` are the preferences `e`
+// was made from.
+func (e eligibility) danceableDances(dps []*model.DancerPosition) map[int]struct{} {
+	danceable := make(map[int]struct{})
+	for _, dance := range danceList(dps) {
+		if e.danceable(dance, nil) {
+			danceable[dance.ID] = struct{}{}
+		}
+	}
+
+	return danceable
+}
+
+// Danceable returns the dances in `dps` which could be danced by the dancers
+// in it, ordered by name. Like WhatIf, it only checks each dance on its own, so
+// they can't necessarily all be danced in one set.
+func Danceable(dps []*model.DancerPosition, constraints Constraints) []*model.Dance {
+	danceable := danceableDances(dps, constraints)
+
+	var dances []*model.Dance
+	for _, dance := range danceList(dps) {
+		if _, ok := danceable[dance.ID]; ok {
+			dances = append(dances, dance)
+		}
+	}
+
+	sort.SliceStable(dances, func(i, j int) bool { return dances[i].Name < dances[j].Name })
+
+	return dances
+}
+
+// candidateDancers splits `candidates` up by dancer, leaving out anybody who
+// is in `dps` already or isn't active.
+func candidateDancers(dps []*model.DancerPosition, candidates []*model.DancerPosition) ([]*model.Dancer, map[int][]*model.DancerPosition) {
+	here := make(map[int]struct{})
+	for _, dp := range dps {
+		here[dp.Dancer.ID] = struct{}{}
+	}
+
+	var dancers []*model.Dancer
+	byDancer := make(map[int][]*model.DancerPosition)
+	for _, dp := range candidates {
+		if _, ok := here[dp.Dancer.ID]; ok || !dp.Dancer.Active {
+			continue
+		}
+
+		if _, ok := byDancer[dp.Dancer.ID]; !ok {
+			dancers = append(dancers, dp.Dancer)
+		}
+		byDancer[dp.Dancer.ID] = append(byDancer[dp.Dancer.ID], dp)
+	}
+
+	return dancers, byDancer
+}
+
+// WhatIf estimates what each of the dancers in `candidates` would add if they
+// came as well as the dancers in `dps`: the dances which can't be danced now,
+// but could be with them. This is quick, as it only checks whether each dance
+// could be danced on its own, in the same way as Explain. The dancers are
+// ranked by how many dances they'd unlock, then by name.
+func WhatIf(dps []*model.DancerPosition, candidates []*model.DancerPosition, constraints Constraints) []Unlock {
+	dancers, byDancer := candidateDancers(dps, candidates)
+	e := newEligibility(dps, constraints)
+	now := e.danceableDances(dps)
+
+	unlocks := make([]Unlock, 0, len(dancers))
+	for _, dancer := range dancers {
+		theirs := byDancer[dancer.ID]
+
+		unlock := Unlock{Dancer: dancer}
+		for _, dance := range danceList(theirs) {
+			if _, before := now[dance.ID]; !before && e.danceable(dance, theirs) {
+				unlock.Dances = append(unlock.Dances, dance)
+			}
+		}
+		unlocks = append(unlocks, unlock)
+	}
+
+	sort.SliceStable(unlocks, func(i, j int) bool {
+		if len(unlocks[i].Dances) != len(unlocks[j].Dances) {
+			return len(unlocks[i].Dances) > len(unlocks[j].Dances)
+		}
+		return unlocks[i].Dancer.Name < unlocks[j].Dancer.Name
+	})
+
+	return unlocks
+}
+
+// SolveWhatIf is WhatIf using `s` to find the best set with each of the
+// dancers in `candidates`, to compare it with the best set without them. The
+// dancers are ranked by how many more dances the set would have, then by how
+// much better it would be. It solves once per dancer, so it's much slower
+// than WhatIf.
+func SolveWhatIf(
+	ctx context.Context,
+	logger *logrus.Entry,
+	s Solver,
+	dps []*model.DancerPosition,
+	candidates []*model.DancerPosition,
+	constraints Constraints,
+) ([]Unlock, error) {
+	base, err := s.Solve(ctx, logger, dps, constraints)
+	if err != nil {
+		return nil, err
+	}
+
+	_, byDancer := candidateDancers(dps, candidates)
+
+	unlocks := WhatIf(dps, candidates, constraints)
+	for i := range unlocks {
+		unlock := &unlocks[i]
+
+		with := append(append([]*model.DancerPosition(nil), dps...), byDancer[unlock.Dancer.ID]...)
+		result, err := s.Solve(ctx, logger.WithField("dancer", unlock.Dancer.Name), with, constraints)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		unlock.ExtraDances = result.Set.NumDancesDanced() - base.Set.NumDancesDanced()
+		unlock.Gain = result.Objective - base.Objective
+	}
+
+	sort.SliceStable(unlocks, func(i, j int) bool {
+		if unlocks[i].ExtraDances != unlocks[j].ExtraDances {
+			return unlocks[i].ExtraDances > unlocks[j].ExtraDances
+		}
+		return unlocks[i].Gain > unlocks[j].Gain
+	})
+
+	return unlocks, nil
+}
@@ -0,0 +1,79 @@
+package solver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iainlane/who-dances-what/internal/model"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhatIf(t *testing.T) {
+	t.Parallel()
+
+	alice := &model.Dancer{ID: 1, Name: "Alice", Active: true}
+	bob := &model.Dancer{ID: 2, Name: "Bob", Active: true}
+	carol := &model.Dancer{ID: 3, Name: "Carol", Active: true}
+	dave := &model.Dancer{ID: 4, Name: "Dave", Active: false}
+	erin := &model.Dancer{ID: 5, Name: "Erin", Active: true}
+
+	dances := goTestDances(2, 1, 1)
+	a, b, c := dances[0], dances[1], dances[2]
+
+	// nobody here can dance A's second position
+	dps := []*model.DancerPosition{
+		goTestDP(alice, a, 1, model.PreferenceYes),
+		goTestDP(bob, b, 1, model.PreferenceYes),
+	}
+
+	candidates := []*model.DancerPosition{
+		goTestDP(carol, a, 2, model.PreferenceMaybe),
+		goTestDP(carol, c, 1, model.PreferenceYes),
+		// Dave isn't active, so he isn't coming whatever happens
+		goTestDP(dave, a, 2, model.PreferenceFavourite),
+		// B can be danced already
+		goTestDP(erin, b, 1, model.PreferenceFavourite),
+		goTestDP(erin, a, 2, model.PreferenceNo),
+		// already here, so not a candidate
+		goTestDP(bob, a, 2, model.PreferenceYes),
+	}
+
+	names := func(unlocks []Unlock) []string {
+		var n []string
+		for _, unlock := range unlocks {
+			n = append(n, unlock.Dancer.Name)
+		}
+		return n
+	}
+
+	t.Run("danceable", func(t *testing.T) {
+		require.Equal(t, []*model.Dance{b}, Danceable(dps, Constraints{}))
+		require.Equal(t, []*model.Dance{a, b, c}, Danceable(append(append([]*model.DancerPosition(nil), dps...), candidates[:2]...), Constraints{}))
+	})
+
+	t.Run("estimate", func(t *testing.T) {
+		unlocks := WhatIf(dps, candidates, Constraints{})
+		require.Equal(t, []string{"Carol", "Erin"}, names(unlocks))
+		require.Equal(t, []*model.Dance{a, c}, unlocks[0].Dances)
+		require.Empty(t, unlocks[1].Dances)
+
+		// excluded dances aren't unlocked
+		unlocks = WhatIf(dps, candidates, Constraints{Exclude: []*model.Dance{c}})
+		require.Equal(t, []*model.Dance{a}, unlocks[0].Dances)
+	})
+
+	t.Run("solved", func(t *testing.T) {
+		solver, err := New(BackendGo, Options{})
+		require.NoError(t, err)
+
+		unlocks, err := SolveWhatIf(context.Background(), logrus.WithField("test-name", t.Name()), solver, dps, candidates, Constraints{})
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"Carol", "Erin"}, names(unlocks))
+		require.Equal(t, 2, unlocks[0].ExtraDances)
+		require.Positive(t, unlocks[0].Gain)
+		require.Equal(t, 0, unlocks[1].ExtraDances)
+		require.Equal(t, []*model.Dance{a, c}, unlocks[0].Dances)
+	})
+}